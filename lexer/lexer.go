@@ -171,6 +171,14 @@ func (l *Lexer) NextToken() (Token, error) {
 
 	// 检查是否为运算符
 	if tokenType, ok := operators[string(ch)]; ok {
+		// 优先匹配 ==、&& 这样的双字符运算符
+		nextCh, err := l.readRune()
+		if err == nil {
+			if tokenType, ok := operators[string(ch)+string(nextCh)]; ok {
+				return Token{Type: tokenType, Value: string(ch) + string(nextCh), Line: l.line, Column: l.column}, nil
+			}
+		}
+		l.unreadRune()
 		return Token{Type: tokenType, Value: string(ch), Line: l.line, Column: l.column}, nil
 	}
 
@@ -2,15 +2,45 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
 	"github.com/ozline/CoursePractice-GoCompiler/lexer"
 	"github.com/ozline/CoursePractice-GoCompiler/parser"
 )
 
+// GLR_TREES 表示 glr 子命令最多打印的语法树数量
+const GLR_TREES = 4
+
+// AMBIGUITY_LENGTH 表示 ambiguity 子命令省略长度时搜索的最大句子长度
+const AMBIGUITY_LENGTH = 16
+
+// AMBIGUITY_STEPS 表示 ambiguity 子命令每一次搜索最多尝试的组合数
+const AMBIGUITY_STEPS = 1 << 20
+
+// EQUIVALENCE_LENGTH 表示 equivalence 子命令搜索区分输入时输入的最大长度
+const EQUIVALENCE_LENGTH = 20
+
+// LRK_DEFAULT 表示 lrk 子命令省略 k 时使用的展望符个数
+const LRK_DEFAULT = 2
+
 func main() {
-	// 创建一个新的 parser 实例
-	parser := parser.NewParser()
+	// 子命令在构建课程文法的分析表之前处理，不需要这张分析表的子命令不会输出它的冲突
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
+	// 创建一个新的 parser 实例，文法检查发现错误时停止，不再构建分析表
+	parser, _, err := parser.NewParserChecked()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	// 构造并打印 First 集合
 	parser.InitFirstSet()
@@ -25,6 +55,8 @@ func main() {
 
 	// 构建状态集合并输出
 	parser.BuildStateCollection()
+	// 需要单独分析表达式、语句或声明时，把它们也作为入口，然后使用 parser.ParseAs("expr", lex)
+	// parser.BuildStateCollectionFor("stmt", "decl", "expr")
 	// parser.PrintStateCollection()
 
 	// 构建分析表
@@ -32,6 +64,54 @@ func main() {
 	// parser.PrintGoToTable()
 	// parser.PrintActionTable()
 
+	// 与另一张分析表比较（例如修改构建算法之前保存的分析表，或者手工计算的分析表），状态编号不同也可以比较，不同时给出最短的区分输入
+	// parser.PrintEquivalence(os.Stdout, other.Automaton(), 20)
+
+	// 分析冲突并给出反例
+	// parser.PrintConflicts()
+
+	// 在有限长度内搜索二义性，传入分析表的冲突时先从冲突的反例出发搜索
+	// parser.Grammar.PrintAmbiguity(os.Stdout, parser.AmbiguityOptions{MaxLength: 16, MaxSteps: 1 << 20, Conflicts: parser.AnalyzeConflicts()})
+
+	// 化简文法：消除空产生式和单位产生式、内联只使用一次的非终结符，语义动作会被组合起来，并比较化简前后分析表的规模
+	// simplified, report := parser.Grammar.NewSimplifyReport(parser.SimplifyOptions{Epsilon: true, Unit: true, Inline: true})
+	// report.WriteText(os.Stdout)
+	// simple := parser.NewParserWithGrammar(simplified)
+
+	// 使用 LL(1) 预测分析时，需要先把文法变换为无左递归、无左公因子的形式
+	// grammar, _ := parser.Grammar.PredictiveForm()
+	// ll1 := parser.NewParserWithGrammar(grammar)
+	// ll1.InitFirstSet()
+	// ll1.InitFollowSet()
+	// ll1.BuildLL1Table()
+	// ll1.ParseLL1(lex)
+
+	// 使用 GLR 分析时，保留所有冲突动作，得到包含所有推导的分析森林
+	// parser.BuildGLRTable()
+	// forest, _ := parser.ParseGLR(lex)
+	// tree, _ := forest.Disambiguate(nil)
+
+	// 使用 Earley 分析时不需要构建状态集合和分析表，处理函数按照与 Parse 相同的顺序调用
+	// ambiguities, _ := parser.ParseEarley(lex)
+
+	// 使用 LR(k) 分析时，每一步查看 k 个展望符，k = 1 时与 BuildTables 得到的分析表相同
+	// parser.BuildLRkTable(2)
+	// parser.ParseLRk(lex)
+
+	// 使用属性文法时，规约的同时建立语法树并计算属性，分析结束后通过 Root 得到带属性的语法树
+	// parser.UseAttributes(parser.TYPE_ATTRIBUTES)
+	// for _, reason := range parser.Attributes.Reasons {
+	// 	fmt.Printf("[属性文法] 不是 L 属性文法，将在分析结束之后计算属性：%s\n", reason)
+	// }
+	// parser.Parse(lex)
+	// root, _ := parser.Attributes.Root(parser)
+	// fmt.Println(root)
+
+	// 随机生成程序，经过词法分析后交给分析表识别，检查分析表是否正确；生成的程序也可以作为性能测试的输入
+	// gen := parser.NewProgramGenerator(parser.Grammar, time.Now().UnixNano(), parser.ProgramOptions{MinTokens: 100})
+	// parser.SelfCheck(gen, 100).WriteText(os.Stdout)
+	// sentences, uncovered := gen.Cover(1000)
+
 	// 初始化词法分析器
 	var lex *lexer.Lexer
 	if len(os.Args) > 1 {
@@ -46,6 +126,15 @@ func main() {
 		lex = lexer.NewLexer(file)
 	}
 
+	// 分析过程默认以文本输出到标准输出，作为库使用时可以不输出，或者输出为 JSON Lines，之后用 ReadTrace 读回、ReplayTrace 重放
+	// parser.Tracer = parser.SilentTracer{}
+	// parser.Tracer = parser.JSONTracer{W: traceFile}
+
+	// 错误产生式默认不在文法中，遇到第一个语法错误就停止；需要恢复并继续分析时，在构建状态集合之前加入错误产生式
+	// parser := parser.NewParserWithGrammar(parser.NewParser().Grammar.WithErrorProductions())
+	// 开启恐慌模式之后，错误产生式无法处理的语法错误也会恢复，所有错误记录在 parser.SyntaxErrors 中
+	// parser.EnablePanicMode(parser.DEFAULT_PANIC_MODE)
+
 	if err := parser.Parse(lex); err != nil {
 		fmt.Printf("%v", err)
 	}
@@ -53,3 +142,593 @@ func main() {
 	parser.PrintThreeAddress() // 打印三地址码
 	parser.SymbolTable.Print() // 打印符号表
 }
+
+// runCommand 执行 args 指定的子命令，没有对应的子命令时返回 false，由 main 分析标准输入
+func runCommand(args []string) bool {
+	// 检查课程文法，打印所有检查结果，存在错误级别的结果时以状态 1 退出：go run . lint
+	if len(args) > 0 && args[0] == "lint" {
+		runLint()
+		return true
+	}
+
+	// 打印每个测试输入的 Token 序列：go run . lex ['tests/*.in']
+	if len(args) > 0 && args[0] == "lex" {
+		runLex(args[1:])
+		return true
+	}
+
+	// 导入 yacc 文法并构建 LR(1) 分析表，用来与 Bison 的结果比较，不需要课程文法的分析表：go run . yacc grammar.y [input ...]
+	if len(args) > 1 && args[0] == "yacc" {
+		runYacc(args[1], args[2:])
+		return true
+	}
+
+	// 把文法变换为 LL(1) 文法，打印冲突，然后用预测分析程序分析每个测试输入：go run . ll1 ['tests/*.in']
+	if len(args) > 0 && args[0] == "ll1" {
+		runLL1(parser.NewParser().Grammar, args[1:])
+		return true
+	}
+
+	// 用 GLR 分析每个测试输入，打印存在二义性的节点和所有语法树：go run . glr ['tests/*.in']
+	if len(args) > 0 && args[0] == "glr" {
+		runGLR(courseParser(), args[1:])
+		return true
+	}
+
+	// 用 Earley 算法分析每个测试输入，打印二义性的位置和生成的三地址码：go run . earley ['tests/*.in']
+	if len(args) > 0 && args[0] == "earley" {
+		runEarley(args[1:])
+		return true
+	}
+
+	// 构建课程文法的规范 LR(k) 分析表，打印冲突，然后分析每个测试输入：go run . lrk [k] ['tests/*.in']
+	if len(args) > 0 && args[0] == "lrk" {
+		runLRk(args[1:])
+		return true
+	}
+
+	// 在课程文法中加入错误产生式，分析每个测试输入并打印恢复过的语法错误和错误节点：go run . recover ['tests/*.in']
+	if len(args) > 0 && args[0] == "recover" {
+		runRecover(args[1:], false)
+		return true
+	}
+
+	// 不加入错误产生式，只使用恐慌模式恢复语法错误：go run . panic ['tests/*.in']
+	if len(args) > 0 && args[0] == "panic" {
+		runRecover(args[1:], true)
+		return true
+	}
+
+	// 在长度不超过 n 的句子中搜索课程文法的二义性：go run . ambiguity [n]
+	if len(args) > 0 && args[0] == "ambiguity" {
+		runAmbiguity(args[1:])
+		return true
+	}
+
+	// 统计测试输入的覆盖率：go run . coverage ['tests/*.in'] [outs/coverage.html]
+	if len(args) > 0 && args[0] == "coverage" {
+		runCoverage(courseParser(), args[1:])
+		return true
+	}
+
+	// 化简课程文法，打印每一步变换和化简前后分析表的规模：go run . simplify
+	if len(args) > 0 && args[0] == "simplify" {
+		_, report := parser.NewParser().Grammar.NewSimplifyReport(parser.SimplifyOptions{Epsilon: true, Unit: true, Inline: true})
+		report.WriteText(os.Stdout)
+		return true
+	}
+
+	// 把课程文法的分析表与 LR(1) 分析表、以及冲突改为规约的分析表比较：go run . equivalence
+	if len(args) > 0 && args[0] == "equivalence" {
+		runEquivalence(courseParser())
+		return true
+	}
+
+	// 导出 LR(1) 自动机：go run . graph [outs/automaton.dot] [outs/automaton.html]
+	if len(args) > 0 && args[0] == "graph" {
+		runGraph(courseParser(), args[1:])
+		return true
+	}
+
+	// 以表格形式导出分析表，格式由扩展名决定：go run . table [outs/table.md|outs/table.csv|outs/table.html]
+	if len(args) > 0 && args[0] == "table" {
+		runTable(courseParser(), args[1:])
+		return true
+	}
+
+	// 单步调试分析过程，命令从标准输入读取：go run . debug tests/case1.in；也可以查看 JSONTracer 保存的记录：go run . debug trace.jsonl
+	if len(args) > 1 && args[0] == "debug" {
+		runDebug(courseParser(), args[1])
+		return true
+	}
+
+	// 为语法错误给出最小代价的修复建议，加上 --fix 时直接修改源文件：go run . repair tests/case3.in [--fix]
+	if len(args) > 1 && args[0] == "repair" {
+		runRepair(courseParser(), args[1], len(args) > 2 && args[2] == "--fix")
+		return true
+	}
+
+	return false
+}
+
+// runLex 依次对匹配 pattern 的每个输入文件做词法分析，打印每个 Token 的位置、类型和值
+func runLex(args []string) {
+	paths, err := inputPaths(args)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("Failed to open file: %v", err)
+			return
+		}
+		fmt.Printf("%s:\n", path)
+		lex := lexer.NewLexer(file)
+		for {
+			token, err := lex.NextToken()
+			if err != nil {
+				fmt.Printf("  %s\n", strings.TrimSpace(err.Error()))
+				break
+			}
+			if token.Type == lexer.EOF {
+				break
+			}
+			fmt.Printf("  %d:%d\t%s\t%s\n", token.Line, token.Column, lexer.TokenTypes[token.Type], token.Value)
+		}
+		file.Close()
+	}
+}
+
+// runLint 检查课程文法并打印所有检查结果，存在错误级别的结果时以状态 1 退出
+func runLint() {
+	grammar, _, err := parser.NewGrammarChecked(parser.PRODUCTIONS, parser.TERMINALS)
+	grammar.PrintLint()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// runLL1 把文法变换为 LL(1) 文法并构建预测分析表，然后依次分析匹配 pattern 的每个输入文件，省略时使用 tests/*.in
+func runLL1(g *parser.Grammar, args []string) {
+	paths, err := inputPaths(args)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	grammar, _ := g.PredictiveForm()
+	ll1 := parser.NewParserWithGrammar(grammar)
+	ll1.Tracer = parser.SilentTracer{}
+	conflicts := ll1.BuildLL1Table()
+	fmt.Printf("LL(1) 文法共有 %d 个产生式，%d 个冲突\n", len(grammar.Productions), len(conflicts))
+	for _, conflict := range conflicts {
+		fmt.Println(conflict)
+	}
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("Failed to open file: %v", err)
+			return
+		}
+		if err := ll1.ParseLL1(lexer.NewLexer(file)); err != nil {
+			fmt.Printf("%s: %s\n", path, strings.TrimSpace(err.Error()))
+		} else {
+			fmt.Printf("%s: 分析成功\n", path)
+		}
+		file.Close()
+	}
+}
+
+// courseParser 构建课程文法的状态集合和分析表，供需要分析表的子命令使用
+// 文法检查发现错误时打印错误并退出
+func courseParser() *parser.Parser {
+	p, _, err := parser.NewParserChecked()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	p.InitFirstSet()
+	p.BuildStateCollection()
+	p.BuildTables()
+	return p
+}
+
+// inputPaths 返回匹配 args[0] 的输入文件，省略时使用 tests/*.in
+func inputPaths(args []string) ([]string, error) {
+	pattern := "tests/*.in"
+	if len(args) > 0 {
+		pattern = args[0]
+	}
+	return filepath.Glob(pattern)
+}
+
+// runLRk 构建课程文法的规范 LR(k) 分析表，k 省略时为 LRK_DEFAULT，然后依次分析匹配 pattern 的每个输入文件
+func runLRk(args []string) {
+	k := LRK_DEFAULT
+	if len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			k, args = n, args[1:]
+		}
+	}
+	paths, err := inputPaths(args)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	p := parser.NewParser()
+	p.InitFirstSet()
+	conflicts := p.BuildLRkTable(k)
+	fmt.Printf("LR(%d) 分析表共有 %d 个状态，%d 个冲突\n", k, len(p.LRkTable.States), len(conflicts))
+	for _, conflict := range conflicts {
+		fmt.Println(conflict)
+	}
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("Failed to open file: %v", err)
+			return
+		}
+		// 分析表只与文法有关，每个文件使用一个新的 Parser，符号表互不影响
+		lrk := parser.NewParser()
+		lrk.LRkTable = p.LRkTable
+		lrk.Tracer = parser.SilentTracer{}
+		if err := lrk.ParseLRk(lexer.NewLexer(file)); err != nil {
+			fmt.Printf("%s: %s\n", path, strings.TrimSpace(err.Error()))
+		} else {
+			fmt.Printf("%s: 分析成功\n", path)
+		}
+		file.Close()
+	}
+}
+
+// runRecover 使用加入错误产生式的课程文法依次分析匹配 pattern 的每个输入文件，打印语法错误和错误节点，不输出分析过程
+// panicMode 为 true 时不加入错误产生式，改为开启 DEFAULT_PANIC_MODE；每个文件使用一个新的 Parser，上一个文件的错误不会计入下一个文件
+func runRecover(args []string, panicMode bool) {
+	paths, err := inputPaths(args)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	grammar := parser.NewParser().Grammar
+	if !panicMode {
+		grammar = grammar.WithErrorProductions()
+	}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("Failed to open file: %v", err)
+			return
+		}
+		p := parser.NewParserWithGrammar(grammar)
+		p.InitFirstSet()
+		p.BuildStateCollection()
+		p.BuildTables()
+		p.Tracer = parser.SilentTracer{}
+		if panicMode {
+			p.EnablePanicMode(parser.DEFAULT_PANIC_MODE)
+		}
+		err = p.Parse(lexer.NewLexer(file))
+		file.Close()
+		if err != nil {
+			fmt.Printf("%s: %s\n", path, strings.TrimSpace(err.Error()))
+		} else {
+			fmt.Printf("%s: 分析成功\n", path)
+		}
+		for _, syntaxErr := range p.SyntaxErrors {
+			// 语法错误的第二行是期望的符号，与错误一起缩进
+			fmt.Printf("  %s\n", strings.ReplaceAll(strings.TrimSpace(syntaxErr.Error()), "\n", "\n  "))
+		}
+		for _, node := range p.ErrorNodes {
+			fmt.Printf("  %v\n", node)
+		}
+		p.PrintThreeAddress()
+		fmt.Println()
+	}
+}
+
+// runAmbiguity 在长度不超过 args[0] 的句子中搜索课程文法的二义性，省略时为 AMBIGUITY_LENGTH
+// 先从分析表的冲突出发搜索，所以需要课程文法的分析表
+func runAmbiguity(args []string) {
+	length := AMBIGUITY_LENGTH
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Printf("句子长度应该是整数：%s\n", args[0])
+			return
+		}
+		length = n
+	}
+	p := courseParser()
+	p.Grammar.PrintAmbiguity(os.Stdout, parser.AmbiguityOptions{MaxLength: length, MaxSteps: AMBIGUITY_STEPS, Conflicts: p.AnalyzeConflicts()})
+}
+
+// runEquivalence 比较课程文法的分析表与另外两个自动机，打印比较结果
+// 1. BuildLRkTable(1) 得到的分析表，两者应该同构
+// 2. 把每个冲突的位置改为被舍弃的动作（例如悬空 else 改为规约）得到的分析表，两者不同构，打印最短的区分输入
+func runEquivalence(p *parser.Parser) {
+	lr1 := parser.NewParserWithGrammar(p.Grammar)
+	lr1.InitFirstSet()
+	lr1.BuildLRkTable(1)
+	action := make(parser.ActionTable)
+	for state, row := range lr1.LRkTable.Action {
+		action[state] = make(map[consts.Terminal]parser.ActionEntry)
+		for lookahead, entry := range row {
+			action[state][consts.Terminal(lookahead)] = entry
+		}
+	}
+	fmt.Println("与 BuildLRkTable(1) 得到的分析表比较：")
+	p.PrintEquivalence(os.Stdout, parser.Automaton{Action: action, Goto: lr1.LRkTable.Goto}, EQUIVALENCE_LENGTH)
+
+	rejected := make(parser.ActionTable)
+	for state, row := range p.ActionTable {
+		rejected[state] = maps.Clone(row)
+	}
+	for _, c := range p.Conflicts {
+		rejected[c.State][c.Lookahead] = c.Rejected
+	}
+	fmt.Println("与冲突处选择被舍弃的动作的分析表比较：")
+	p.PrintEquivalence(os.Stdout, parser.Automaton{Action: rejected, Goto: p.GotoTable}, EQUIVALENCE_LENGTH)
+}
+
+// runGLR 用 GLR 分析匹配 pattern 的每个输入文件，存在二义性时打印有多种推导的节点和最多 GLR_TREES 棵语法树
+func runGLR(p *parser.Parser, args []string) {
+	paths, err := inputPaths(args)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	p.BuildGLRTable()
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("Failed to open file: %v", err)
+			return
+		}
+		forest, err := p.ParseGLR(lexer.NewLexer(file))
+		file.Close()
+		if err != nil {
+			fmt.Printf("%s: %s\n", path, strings.TrimSpace(err.Error()))
+			continue
+		}
+		ambiguities := forest.Ambiguities()
+		if len(ambiguities) == 0 {
+			fmt.Printf("%s: 分析成功，没有二义性\n", path)
+			continue
+		}
+		fmt.Printf("%s: 分析成功，%d 个节点有多种推导\n", path, len(ambiguities))
+		for _, node := range ambiguities {
+			fmt.Printf("  %s 覆盖第 %d 到 %d 个符号，%d 种推导\n", node.Symbol, node.Start+1, node.End, len(node.Families))
+		}
+		for _, tree := range forest.Trees(GLR_TREES) {
+			fmt.Printf("  %v\n", tree)
+		}
+	}
+}
+
+// runEarley 用 Earley 算法分析匹配 pattern 的每个输入文件，打印二义性的位置和生成的三地址码
+// Earley 分析不需要分析表，每个文件使用一个新的 Parser，三地址码和符号表互不影响
+func runEarley(args []string) {
+	paths, err := inputPaths(args)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("Failed to open file: %v", err)
+			return
+		}
+		p := parser.NewParser()
+		ambiguities, err := p.ParseEarley(lexer.NewLexer(file))
+		file.Close()
+		if err != nil {
+			fmt.Printf("%s: %s\n", path, strings.TrimSpace(err.Error()))
+			continue
+		}
+		fmt.Printf("%s: 分析成功\n", path)
+		for _, node := range ambiguities {
+			fmt.Printf("  二义性：%s 在第 %d 到第 %d 个符号之间有 %d 种推导，使用第一种\n", node.Symbol, node.Start+1, node.End, len(node.Families))
+		}
+		p.PrintThreeAddress()
+		fmt.Println()
+	}
+}
+
+// runCoverage 分析所有测试输入，打印覆盖率报告并写出 HTML 版本
+// args 依次是输入文件的模式和 HTML 报告的路径，省略时使用 tests/*.in 和 outs/coverage.html
+func runCoverage(p *parser.Parser, args []string) {
+	pattern, output := "tests/*.in", "outs/coverage.html"
+	if len(args) > 0 {
+		pattern = args[0]
+	}
+	if len(args) > 1 {
+		output = args[1]
+	}
+	// 模式没有加引号时会被 shell 展开成多个文件，这时第二个参数是测试输入，不能覆盖它
+	if !strings.HasSuffix(output, ".html") {
+		fmt.Printf("HTML 报告的路径应该以 .html 结尾，模式需要加引号，例如 'tests/*.in'\n")
+		return
+	}
+
+	p.EnableCoverage()
+	if err := p.CoverCorpus(pattern); err != nil {
+		fmt.Println(err)
+		return
+	}
+	report := p.CoverageReport()
+	report.WriteText(os.Stdout)
+
+	file, err := os.Create(output)
+	if err != nil {
+		fmt.Printf("Failed to create file: %v", err)
+		return
+	}
+	defer file.Close()
+	if err := report.WriteHTML(file); err != nil {
+		fmt.Printf("Failed to write report: %v", err)
+		return
+	}
+	fmt.Println("HTML 报告已写入", output)
+}
+
+// runGraph 把自动机导出为 DOT 图和 HTML 页面
+// args 依次是 DOT 文件和 HTML 文件的路径，省略时使用 outs/automaton.dot 和 outs/automaton.html
+func runGraph(p *parser.Parser, args []string) {
+	outputs := []string{"outs/automaton.dot", "outs/automaton.html"}
+	copy(outputs, args)
+	view := p.AutomatonView()
+	writers := []func(io.Writer) error{view.WriteDOT, view.WriteHTML}
+	for i, output := range outputs {
+		file, err := os.Create(output)
+		if err != nil {
+			fmt.Printf("Failed to create file: %v", err)
+			return
+		}
+		err = writers[i](file)
+		file.Close()
+		if err != nil {
+			fmt.Printf("Failed to write %s: %v", output, err)
+			return
+		}
+		fmt.Println("自动机已写入", output)
+	}
+}
+
+// runTable 把分析表写成 Markdown、CSV 或 HTML 表格，省略路径时写入 outs/table.md
+func runTable(p *parser.Parser, args []string) {
+	output := "outs/table.md"
+	if len(args) > 0 {
+		output = args[0]
+	}
+	write, err := p.ParseTableGrid().WriterFor(output)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		fmt.Printf("Failed to create file: %v", err)
+		return
+	}
+	defer file.Close()
+	if err := write(file); err != nil {
+		fmt.Printf("Failed to write table: %v", err)
+		return
+	}
+	fmt.Println("分析表已写入", output)
+}
+
+// runDebug 在调试器中分析输入文件，扩展名为 .jsonl 时查看保存的跟踪记录
+func runDebug(p *parser.Parser, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("Failed to open file: %v", err)
+		return
+	}
+	defer file.Close()
+
+	debugger := parser.NewDebugger(p, os.Stdin, os.Stdout)
+	if strings.HasSuffix(path, ".jsonl") {
+		events, err := parser.ReadTrace(file)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		debugger.View(events)
+		return
+	}
+	if err := debugger.Run(lexer.NewLexer(file)); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// runRepair 打印输入文件中每一处语法错误的修复建议和修复之后的源代码，fix 为 true 时把修复写回文件
+func runRepair(p *parser.Parser, path string, fix bool) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Failed to open file: %v", err)
+		return
+	}
+	repairs, err := p.SuggestRepairs(lexer.NewLexer(strings.NewReader(string(source))), parser.DEFAULT_REPAIR_OPTIONS)
+	parser.PrintRepairs(os.Stdout, repairs)
+	if err != nil {
+		fmt.Printf("无法修复：%v", err)
+		// 还有没有修复的错误时，写回文件只会留下一个仍然有错误、而且与原文不同的文件
+		if fix {
+			fmt.Println("存在无法修复的语法错误，没有写入文件")
+		}
+		return
+	}
+	if len(repairs) == 0 {
+		fmt.Println("没有发现语法错误")
+		return
+	}
+	repaired := parser.ApplyRepairs(string(source), repairs)
+	if err := p.CheckRepaired(repaired); err != nil {
+		fmt.Printf("修复之后的源代码仍然无法通过分析，没有写入文件：%v", err)
+		return
+	}
+	// 不加 --fix 时只打印修复之后的源代码
+	if !fix {
+		fmt.Printf("修复之后的源代码：\n%s", repaired)
+		return
+	}
+	if err := os.WriteFile(path, []byte(repaired), 0644); err != nil {
+		fmt.Printf("Failed to write file: %v", err)
+		return
+	}
+	fmt.Printf("已将 %d 处修复写入 %s\n", len(repairs), path)
+}
+
+// runYacc 导入 yacc 文法，构建 LR(1) 分析表并打印冲突，然后用这张表依次分析 inputs 中的文件，打印成功或者带位置的语法错误
+func runYacc(path string, inputs []string) {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("Failed to open file: %v", err)
+		return
+	}
+	defer file.Close()
+	grammar, err := parser.ImportYacc(file)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	p := parser.NewParserWithGrammar(grammar)
+	p.InitFirstSet()
+	p.InitFollowSet()
+	p.BuildStateCollection()
+	p.BuildTables()
+	fmt.Printf("%d 个产生式，%d 个状态，%d 个冲突通过优先级解决\n", len(grammar.Productions), len(p.StateCollection), len(p.Resolved))
+	for _, c := range p.Resolved {
+		fmt.Printf("使用优先级解决冲突：状态 %d 展望符 '%s'，保留 %s，舍弃 %s\n", c.State, c.Lookahead, c.Chosen.Short(), c.Rejected.Short())
+	}
+	p.PrintConflicts()
+
+	p.Tracer = parser.SilentTracer{}
+	for _, input := range inputs {
+		file, err := os.Open(input)
+		if err != nil {
+			fmt.Printf("Failed to open file: %v", err)
+			return
+		}
+		err = p.Parse(lexer.NewLexer(file))
+		file.Close()
+		if err != nil {
+			fmt.Printf("%s: %s\n", input, strings.TrimSpace(err.Error()))
+		} else {
+			fmt.Printf("%s: 分析成功\n", input)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+// ambiguity.go
+// 二义性检查：在有限长度内搜索同一个句子的两棵不同的语法树
+
+package parser
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// AmbiguityOptions 表示二义性搜索的范围
+// 搜索量只由长度和数量决定，与运行时间无关，同样的选项总是得到同样的结果
+type AmbiguityOptions struct {
+	MaxLength int           // 句子的最大长度
+	MaxSteps  int           // 每一次搜索最多尝试多少种组合，为 0 时不限制
+	MaxTrees  int           // 最多建立多少棵语法树，用于限制内存，为 0 时不限制
+	Start     consts.Symbol // 从这个符号出发搜索，只检查它能到达的非终结符，为空时使用文法的开始符号
+	Conflicts []Conflict    // 分析表中的冲突，需要先调用 AnalyzeConflicts；不为空时先从冲突的反例出发搜索，见 ambiguityTargets
+}
+
+// Ambiguity 表示找到的一处二义性：Symbol 可以用两种不同的最左推导得到同一个句子
+type Ambiguity struct {
+	Symbol   consts.Symbol
+	Sentence []consts.Terminal
+	Trees    [2]*ParseTree
+}
+
+// String 返回二义性的可读描述，包括句子和两棵语法树
+func (a *Ambiguity) String() string {
+	sentence := make([]string, len(a.Sentence))
+	for i, terminal := range a.Sentence {
+		sentence[i] = string(terminal)
+	}
+	return fmt.Sprintf("%s 存在二义性，句子 %q 有两棵不同的语法树：\n1: %s\n2: %s",
+		a.Symbol, strings.Join(sentence, " "), a.Trees[0], a.Trees[1])
+}
+
+// ambiguityNode 表示搜索中建立的语法树节点，子节点用编号表示，相同的子树只建立一次
+type ambiguityNode struct {
+	symbol     consts.Symbol
+	production int   // 叶子节点为 -1
+	children   []int // 子节点的编号
+}
+
+// ambiguityItem 表示某个符号推导出的一个句子和它的第一棵语法树
+type ambiguityItem struct {
+	sentence []consts.Terminal
+	tree     int
+}
+
+// ambiguityCell 保存某个符号推导出的所有长度相同的句子
+type ambiguityCell struct {
+	items []ambiguityItem
+	index map[string]int // 句子 → 第一棵语法树的编号
+}
+
+// ambiguitySearch 保存一次二义性搜索的状态
+type ambiguitySearch struct {
+	grammar *Grammar
+	options AmbiguityOptions
+	nodes   []ambiguityNode
+	keys    map[string]int                     // 产生式和子节点编号 → 节点编号，用来判断两棵树是否相同
+	cells   map[consts.Symbol][]*ambiguityCell // cells[A][n] 保存 A 推导出的长度为 n 的句子
+	steps   int                                // 已经尝试的组合数
+	symbols []consts.Symbol                    // 需要检查的非终结符
+	used    []int                              // 参与搜索的产生式编号
+	opaque  map[consts.Symbol]bool             // 只保留最短的一个句子、不检查二义性的非终结符
+	found   *Ambiguity
+}
+
+// ambiguityTarget 表示由冲突确定的一次搜索：从 start 出发，只有 expanded 中的非终结符保留所有句子
+type ambiguityTarget struct {
+	start    consts.Symbol
+	expanded map[consts.Symbol]bool
+}
+
+// errAmbiguityBudget 表示搜索超出了组合数或语法树数量的限制
+var errAmbiguityBudget = fmt.Errorf("超出了组合数或语法树数量的限制")
+
+// FindAmbiguity 在长度不超过 MaxLength 的句子中搜索二义性，返回最短的一处
+/*
+	按照句子长度 n 从小到大，对每个非终结符 A 求出它推导出的所有长度为 n 的句子，每个句子记住第一棵语法树：
+	1. 对于产生式 A → X1 X2 ... Xk，把 n 拆分为 k 个长度，用各个符号已经求出的句子拼接出 A 的句子
+	2. 同一个句子出现第二棵不同的语法树时，就找到了二义性。两棵树的最左推导一定不同，所以 A 是二义的
+	3. 单位产生式和空产生式会让 A 依赖同样长度的其他符号，所以同一个长度要反复计算，直到不再有新的语法树
+	这个方法不依赖分析表，对于不是 LR(k) 的文法同样适用。找不到二义性只能说明在给定长度内没有二义性。
+	句子的个数随长度指数增长，为了减少搜索量，可以互相替换的终结符（例如 < 和 <=）只使用其中一个，见 interchangeable。
+	即使这样，课程文法中表达式的句子也太多，完整搜索到不了悬挂 else 所需的长度。提供了 Conflicts 时先按冲突搜索（见 ambiguityTargets），
+	这时只有冲突的推导经过的非终结符保留所有句子，其他非终结符只保留最短的一个句子，找到的二义性仍然是原文法中真实的二义性。
+	所有冲突都没有找到二义性时，再从 Start 出发完整搜索。
+	返回 nil, nil 表示在 MaxLength 以内没有二义性，超出组合数或语法树数量的限制时返回错误，说明已经完整检查了多长的句子。
+*/
+func (g *Grammar) FindAmbiguity(options AmbiguityOptions) (*Ambiguity, error) {
+	var shortest *Ambiguity
+	for _, target := range ambiguityTargets(options.Conflicts) {
+		// 按冲突搜索只是为了尽快找到反例，超出限制时继续尝试其他冲突和完整搜索
+		found, _ := g.searchAmbiguity(options, target.start, target.expanded)
+		if found != nil && (shortest == nil || len(found.Sentence) < len(shortest.Sentence)) {
+			shortest = found
+		}
+	}
+	if shortest != nil {
+		return shortest, nil
+	}
+
+	start := options.Start
+	if start == "" {
+		start = g.Start
+	}
+	return g.searchAmbiguity(options, start, nil)
+}
+
+// ambiguityTargets 根据冲突的反例确定搜索的起点和需要保留所有句子的非终结符
+/*
+	反例的两条推导从最近的公共祖先出发，在下一层选择了同一个非终结符的不同产生式，例如悬挂 else 的两条推导在 stmt 处分开：
+	stmt → if ( bool ) stmt 中的 stmt 展开为 if-else 语句，或者 stmt → if ( bool ) stmt else stmt 中的 stmt 展开为 if 语句。
+	如果冲突对应一处二义性，两棵语法树就在这个非终结符处不同，所以从它出发搜索，
+	只有两条推导经过的非终结符需要所有句子，其他非终结符（例如 bool）换成任何一个句子都不会改变两棵树的差别。
+	两个冲突得到相同的搜索时只保留一个。
+*/
+func ambiguityTargets(conflicts []Conflict) []ambiguityTarget {
+	var targets []ambiguityTarget
+	seen := make(map[string]bool)
+	for _, c := range conflicts {
+		if c.Example == nil {
+			continue
+		}
+		first, second := c.Example.Derivations[0].Frames, c.Example.Derivations[1].Frames
+		level := 0
+		if len(first) > 1 && len(second) > 1 && first[1].Production.Head == second[1].Production.Head {
+			level = 1
+		}
+		target := ambiguityTarget{start: first[level].Production.Head, expanded: make(map[consts.Symbol]bool)}
+		var names []string
+		for _, frames := range [][]DerivationFrame{first[level:], second[level:]} {
+			for _, frame := range frames {
+				if !target.expanded[frame.Production.Head] {
+					target.expanded[frame.Production.Head] = true
+					names = append(names, string(frame.Production.Head))
+				}
+			}
+		}
+		slices.Sort(names)
+		key := string(target.start) + "#" + strings.Join(names, " ")
+		if !seen[key] {
+			seen[key] = true
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// searchAmbiguity 从 start 出发按长度搜索二义性，expanded 为 nil 时所有非终结符都保留所有句子
+func (g *Grammar) searchAmbiguity(options AmbiguityOptions, start consts.Symbol, expanded map[consts.Symbol]bool) (*Ambiguity, error) {
+	s := &ambiguitySearch{
+		grammar: g,
+		options: options,
+		keys:    make(map[string]int),
+		cells:   make(map[consts.Symbol][]*ambiguityCell),
+		opaque:  make(map[consts.Symbol]bool),
+	}
+	s.scope(start)
+	if expanded != nil {
+		for _, sym := range s.symbols {
+			s.opaque[sym] = !expanded[sym]
+		}
+	}
+
+	for n := 0; n <= options.MaxLength; n++ {
+		for _, sym := range s.symbols {
+			s.cells[sym] = append(s.cells[sym], &ambiguityCell{index: make(map[string]int)})
+		}
+		for changed := true; changed; {
+			changed = false
+			for _, index := range s.used {
+				added, err := s.expand(index, g.Productions[index], n)
+				if err != nil {
+					return nil, fmt.Errorf("二义性搜索%v，已经完整检查了长度不超过 %d 的句子", err, n-1)
+				}
+				changed = changed || added
+				if s.found != nil {
+					return s.found, nil
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// scope 求出从 start 出发能够到达的非终结符和需要使用的产生式
+// 终结符不会继续展开，所以即使某个终结符同时是产生式的头部，也不会到达它的产生式
+func (s *ambiguitySearch) scope(start consts.Symbol) {
+	representative := s.grammar.interchangeable()
+	reachable := map[consts.Symbol]bool{start: true}
+	s.symbols = []consts.Symbol{start}
+	for i := 0; i < len(s.symbols); i++ {
+		for _, prod := range s.grammar.Productions {
+			if prod.Head != s.symbols[i] {
+				continue
+			}
+			for _, sym := range rhs(prod.Body) {
+				if !s.grammar.IsTerminal(sym) && !reachable[sym] {
+					reachable[sym] = true
+					s.symbols = append(s.symbols, sym)
+				}
+			}
+		}
+	}
+	for index, prod := range s.grammar.Productions {
+		if !reachable[prod.Head] {
+			continue
+		}
+		replaced := false
+		for _, sym := range rhs(prod.Body) {
+			if r, ok := representative[consts.Terminal(sym)]; ok && s.grammar.IsTerminal(sym) && r != consts.Terminal(sym) {
+				replaced = true
+			}
+		}
+		if !replaced {
+			s.used = append(s.used, index)
+		}
+	}
+}
+
+// interchangeable 返回可以互相替换的终结符的代表，例如 < <= > >= 的代表都是 <
+/*
+	如果终结符 a 在某个产生式中的任意一次出现换成 b 之后，得到的产生式仍然在文法中（反过来也一样），
+	那么任何句子中的 a 换成 b 之后，语法树一一对应，二义性也就完全相同。
+	只保留代表出现的产生式，得到的句子和语法树都是原文法中的句子和语法树。
+	判断的方法是把每次出现替换为占位符，两个终结符得到的产生式集合相同时，它们就可以互相替换。
+*/
+func (g *Grammar) interchangeable() map[consts.Terminal]consts.Terminal {
+	signatures := make(map[consts.Terminal][]string)
+	for _, prod := range g.Productions {
+		body := rhs(prod.Body)
+		for i, sym := range body {
+			if !g.IsTerminal(sym) {
+				continue
+			}
+			placeholder := slices.Clone(body)
+			placeholder[i] = "□"
+			signatures[consts.Terminal(sym)] = append(signatures[consts.Terminal(sym)], fmt.Sprintf("%s → %v", prod.Head, placeholder))
+		}
+	}
+
+	representative := make(map[consts.Terminal]consts.Terminal)
+	first := make(map[string]consts.Terminal)
+	for _, terminal := range g.Terminals {
+		signature, ok := signatures[terminal]
+		if !ok {
+			continue
+		}
+		slices.Sort(signature)
+		key := strings.Join(signature, "\n")
+		if _, exists := first[key]; !exists {
+			first[key] = terminal
+		}
+		representative[terminal] = first[key]
+	}
+	return representative
+}
+
+// expand 用产生式组合出长度为 n 的句子，返回是否建立了新的语法树
+func (s *ambiguitySearch) expand(index int, prod Production, n int) (bool, error) {
+	body := rhs(prod.Body)
+	added := false
+	var combine func(i, remaining int, sentence []consts.Terminal, children []int) error
+	combine = func(i, remaining int, sentence []consts.Terminal, children []int) error {
+		if i == len(body) {
+			if remaining != 0 {
+				return nil
+			}
+			if s.steps++; s.options.MaxSteps > 0 && s.steps > s.options.MaxSteps {
+				return errAmbiguityBudget
+			}
+			isNew, err := s.add(prod.Head, index, sentence, children)
+			added = added || isNew
+			return err
+		}
+		sym := body[i]
+		if s.grammar.IsTerminal(sym) {
+			if remaining == 0 {
+				return nil
+			}
+			leaf := s.node(ambiguityNode{symbol: sym, production: -1})
+			return combine(i+1, remaining-1, append(sentence, consts.Terminal(sym)), append(children, leaf))
+		}
+		cells := s.cells[sym]
+		for length := 0; length <= remaining && length < len(cells); length++ {
+			for _, item := range cells[length].items {
+				if err := combine(i+1, remaining-length, append(sentence, item.sentence...), append(children, item.tree)); err != nil {
+					return err
+				}
+				if s.found != nil {
+					return nil
+				}
+			}
+		}
+		return nil
+	}
+	return added, combine(0, n, nil, nil)
+}
+
+// node 返回节点的编号，相同的节点只建立一次
+func (s *ambiguitySearch) node(node ambiguityNode) int {
+	key := fmt.Sprintf("%s#%d#%v", node.symbol, node.production, node.children)
+	if id, ok := s.keys[key]; ok {
+		return id
+	}
+	s.nodes = append(s.nodes, ambiguityNode{symbol: node.symbol, production: node.production, children: append([]int{}, node.children...)})
+	s.keys[key] = len(s.nodes) - 1
+	return len(s.nodes) - 1
+}
+
+// add 把 head 的一棵语法树加入对应长度的句子中，返回是否是新的语法树
+// 同一个句子已经有另一棵语法树时记录二义性；opaque 中的非终结符已经有一个句子时直接忽略
+func (s *ambiguitySearch) add(head consts.Symbol, production int, sentence []consts.Terminal, children []int) (bool, error) {
+	if s.opaque[head] && s.derives(head) {
+		return false, nil
+	}
+	before := len(s.nodes)
+	tree := s.node(ambiguityNode{symbol: head, production: production, children: children})
+	if len(s.nodes) == before {
+		return false, nil
+	}
+	if s.options.MaxTrees > 0 && len(s.nodes) > s.options.MaxTrees {
+		return false, errAmbiguityBudget
+	}
+
+	cell := s.cells[head][len(sentence)]
+	key := fmt.Sprint(sentence)
+	if first, ok := cell.index[key]; ok {
+		s.found = &Ambiguity{Symbol: head, Sentence: append([]consts.Terminal{}, sentence...), Trees: [2]*ParseTree{s.tree(first), s.tree(tree)}}
+		return true, nil
+	}
+	cell.index[key] = tree
+	cell.items = append(cell.items, ambiguityItem{sentence: append([]consts.Terminal{}, sentence...), tree: tree})
+	return true, nil
+}
+
+// derives 判断已经求出 sym 推导出的至少一个句子
+func (s *ambiguitySearch) derives(sym consts.Symbol) bool {
+	for _, cell := range s.cells[sym] {
+		if len(cell.items) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// tree 把节点编号转换为语法树，叶子节点的 Token 字面值就是终结符
+func (s *ambiguitySearch) tree(id int) *ParseTree {
+	node := s.nodes[id]
+	if node.production < 0 {
+		return &ParseTree{Symbol: node.symbol, Production: -1, Token: &lexer.Token{Value: string(node.symbol)}}
+	}
+	tree := &ParseTree{Symbol: node.symbol, Production: node.production}
+	for _, child := range node.children {
+		tree.Children = append(tree.Children, s.tree(child))
+	}
+	return tree
+}
+
+// PrintAmbiguity 搜索文法的二义性，把结果写入 w
+func (g *Grammar) PrintAmbiguity(w io.Writer, options AmbiguityOptions) {
+	ambiguity, err := g.FindAmbiguity(options)
+	switch {
+	case err != nil:
+		fmt.Fprintln(w, err)
+	case ambiguity == nil:
+		fmt.Fprintf(w, "在长度不超过 %d 的句子中没有发现二义性\n", options.MaxLength)
+	default:
+		fmt.Fprintln(w, ambiguity)
+	}
+}
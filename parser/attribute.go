@@ -0,0 +1,457 @@
+// attribute.go
+// 属性文法：声明综合属性和继承属性，在分析过程中或分析结束后计算属性值
+
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// LEXEME_ATTR 表示终结符固有的属性，值是 Token 的字面值（string），不需要声明
+const LEXEME_ATTR = "lexeme"
+
+// AttrKind 表示属性的种类
+type AttrKind int
+
+const (
+	Synthesized AttrKind = iota // 综合属性，由产生式头部所在的产生式计算
+	Inherited                   // 继承属性，由符号出现在产生式体中的那个产生式计算
+)
+
+// String 返回属性种类的名字
+func (k AttrKind) String() string {
+	if k == Inherited {
+		return "继承属性"
+	}
+	return "综合属性"
+}
+
+// AttrDecl 声明一个符号的属性
+type AttrDecl struct {
+	Symbol consts.Symbol // 属性所属的符号
+	Name   string        // 属性名
+	Kind   AttrKind      // 综合属性或继承属性
+	Type   reflect.Type  // 属性值的类型，计算得到的值必须可以赋值给这个类型
+}
+
+// SynAttr 声明一个类型为 T 的综合属性
+func SynAttr[T any](symbol consts.Symbol, name string) AttrDecl {
+	return AttrDecl{Symbol: symbol, Name: name, Kind: Synthesized, Type: reflect.TypeOf((*T)(nil)).Elem()}
+}
+
+// InhAttr 声明一个类型为 T 的继承属性
+func InhAttr[T any](symbol consts.Symbol, name string) AttrDecl {
+	return AttrDecl{Symbol: symbol, Name: name, Kind: Inherited, Type: reflect.TypeOf((*T)(nil)).Elem()}
+}
+
+// AttrRef 引用产生式中某个符号的属性
+// Index 为 0 表示产生式头部，i 表示产生式体中第 i 个符号（从 1 开始，不计算 EPSILON 和中间动作的标记）
+type AttrRef struct {
+	Index int
+	Name  string
+}
+
+// Attr 返回属性引用，例如 Attr(0, "type") 表示产生式头部的 type 属性
+func Attr(index int, name string) AttrRef {
+	return AttrRef{Index: index, Name: name}
+}
+
+// String 返回属性引用的可读形式，例如 $0.type
+func (r AttrRef) String() string {
+	return fmt.Sprintf("$%d.%s", r.Index, r.Name)
+}
+
+// AttrRule 表示产生式中的一条属性计算规则 Target = Compute(Args...)
+type AttrRule struct {
+	Production int                                      // 产生式在文法中的编号
+	Target     AttrRef                                  // 被计算的属性
+	Args       []AttrRef                                // 计算依赖的属性
+	Compute    func(p *Parser, args []any) (any, error) // 计算函数，args 与 Args 一一对应
+}
+
+// AttributeGrammar 表示附加在文法上的属性声明和计算规则
+type AttributeGrammar struct {
+	Attrs []AttrDecl // 属性声明
+	Rules []AttrRule // 属性计算规则
+}
+
+// decl 查找符号的属性声明
+func (ag *AttributeGrammar) decl(symbol consts.Symbol, name string) (AttrDecl, bool) {
+	for _, decl := range ag.Attrs {
+		if decl.Symbol == symbol && decl.Name == name {
+			return decl, true
+		}
+	}
+	return AttrDecl{}, false
+}
+
+// attrBody 返回属性规则中使用的产生式体，即去掉 EPSILON 和中间动作标记后的符号串
+func attrBody(prod Production) []consts.Symbol {
+	var body []consts.Symbol
+	for _, sym := range rhs(prod.Body) {
+		if !IsMarker(sym) {
+			body = append(body, sym)
+		}
+	}
+	return body
+}
+
+// Check 检查属性文法在 g 上是否良定义
+/*
+	1. 终结符只能有继承属性和固有的 lexeme 属性，开始符号不能有继承属性
+	2. 规则只能计算产生式头部的综合属性或产生式体中符号的继承属性
+	3. 每个产生式中，头部的每个综合属性、产生式体中每个符号的每个继承属性都恰好有一条规则
+	4. 规则引用的属性必须已经声明
+*/
+func (ag *AttributeGrammar) Check(g *Grammar) []error {
+	var errs []error
+	for _, decl := range ag.Attrs {
+		if g.IsTerminal(decl.Symbol) && decl.Kind == Synthesized {
+			errs = append(errs, fmt.Errorf("终结符 %s 不能声明综合属性 %s", decl.Symbol, decl.Name))
+		}
+		if decl.Symbol == g.Start && decl.Kind == Inherited {
+			errs = append(errs, fmt.Errorf("开始符号 %s 不能声明继承属性 %s", decl.Symbol, decl.Name))
+		}
+	}
+
+	defined := make(map[string]int)
+	for _, rule := range ag.Rules {
+		if rule.Production < 0 || rule.Production >= len(g.Productions) {
+			errs = append(errs, fmt.Errorf("属性规则 %s 引用了不存在的产生式 %d", rule.Target, rule.Production))
+			continue
+		}
+		prod := g.Productions[rule.Production]
+		body := attrBody(prod)
+		symbolAt := func(ref AttrRef) (consts.Symbol, bool) {
+			if ref.Index == 0 {
+				return prod.Head, true
+			}
+			if ref.Index < 0 || ref.Index > len(body) {
+				return "", false
+			}
+			return body[ref.Index-1], true
+		}
+
+		sym, ok := symbolAt(rule.Target)
+		if !ok {
+			errs = append(errs, fmt.Errorf("产生式 %d 中的属性 %s 超出了产生式体的范围", rule.Production, rule.Target))
+			continue
+		}
+		decl, ok := ag.decl(sym, rule.Target.Name)
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("产生式 %d 计算了未声明的属性 %s.%s", rule.Production, sym, rule.Target.Name))
+		case rule.Target.Index == 0 && decl.Kind != Synthesized:
+			errs = append(errs, fmt.Errorf("产生式 %d 不能计算头部 %s 的继承属性 %s", rule.Production, sym, decl.Name))
+		case rule.Target.Index > 0 && decl.Kind != Inherited:
+			errs = append(errs, fmt.Errorf("产生式 %d 不能计算产生式体中 %s 的综合属性 %s", rule.Production, sym, decl.Name))
+		}
+		defined[fmt.Sprintf("%d#%s", rule.Production, rule.Target)]++
+
+		for _, arg := range rule.Args {
+			argSym, ok := symbolAt(arg)
+			if !ok {
+				errs = append(errs, fmt.Errorf("产生式 %d 中的属性 %s 超出了产生式体的范围", rule.Production, arg))
+				continue
+			}
+			if arg.Name == LEXEME_ATTR && g.IsTerminal(argSym) {
+				continue
+			}
+			if _, ok := ag.decl(argSym, arg.Name); !ok {
+				errs = append(errs, fmt.Errorf("产生式 %d 引用了未声明的属性 %s.%s", rule.Production, argSym, arg.Name))
+			}
+		}
+	}
+
+	// 每个需要计算的属性恰好有一条规则
+	for index, prod := range g.Productions {
+		var required []AttrRef
+		for _, decl := range ag.Attrs {
+			if decl.Symbol == prod.Head && decl.Kind == Synthesized {
+				required = append(required, Attr(0, decl.Name))
+			}
+		}
+		for i, sym := range attrBody(prod) {
+			for _, decl := range ag.Attrs {
+				if decl.Symbol == sym && decl.Kind == Inherited {
+					required = append(required, Attr(i+1, decl.Name))
+				}
+			}
+		}
+		for _, ref := range required {
+			switch count := defined[fmt.Sprintf("%d#%s", index, ref)]; {
+			case count == 0:
+				errs = append(errs, fmt.Errorf("产生式 %d（%s → %s）没有计算属性 %s", index, prod.Head, formatBody(prod.Body), ref))
+			case count > 1:
+				errs = append(errs, fmt.Errorf("产生式 %d（%s → %s）对属性 %s 给出了 %d 条规则", index, prod.Head, formatBody(prod.Body), ref, count))
+			}
+		}
+	}
+	return errs
+}
+
+// LAttributed 判断属性文法是否是 L 属性的，返回不满足条件的规则
+// L 属性文法中，产生式体中第 i 个符号的继承属性只能依赖头部的继承属性和它左边的符号的属性
+// 终结符的 lexeme 在移入时就已经知道，所以第 i 个符号是终结符时也可以依赖它自己的 lexeme
+func (ag *AttributeGrammar) LAttributed(g *Grammar) (bool, []string) {
+	var reasons []string
+	for _, rule := range ag.Rules {
+		if rule.Target.Index == 0 || rule.Production < 0 || rule.Production >= len(g.Productions) {
+			continue
+		}
+		head := g.Productions[rule.Production].Head
+		for _, arg := range rule.Args {
+			if arg.Index == 0 {
+				if decl, ok := ag.decl(head, arg.Name); ok && decl.Kind == Synthesized {
+					reasons = append(reasons, fmt.Sprintf("产生式 %d 中 %s 依赖头部的综合属性 %s", rule.Production, rule.Target, arg))
+				}
+				continue
+			}
+			if body := attrBody(g.Productions[rule.Production]); arg.Index == rule.Target.Index && arg.Index <= len(body) &&
+				arg.Name == LEXEME_ATTR && g.IsTerminal(body[arg.Index-1]) {
+				continue
+			}
+			if arg.Index >= rule.Target.Index {
+				reasons = append(reasons, fmt.Sprintf("产生式 %d 中 %s 依赖右边的属性 %s", rule.Production, rule.Target, arg))
+			}
+		}
+	}
+	return len(reasons) == 0, reasons
+}
+
+// AttrNode 表示带有属性的语法树节点
+type AttrNode struct {
+	Symbol     consts.Symbol // 文法符号
+	Production int           // 内部节点使用的产生式编号，叶子节点为 -1
+	Lexeme     string        // 叶子节点的字面值
+	Children   []*AttrNode   // 子节点，不包括中间动作的标记
+	attrs      map[string]*attrInstance
+}
+
+// Attr 返回节点的属性值，属性还没有计算时返回 false
+func (n *AttrNode) Attr(name string) (any, bool) {
+	if name == LEXEME_ATTR && n.Production < 0 {
+		return n.Lexeme, true
+	}
+	instance, ok := n.attrs[name]
+	if !ok || !instance.done {
+		return nil, false
+	}
+	return instance.value, true
+}
+
+// String 以括号形式输出节点及其属性，例如 (decl[name=x type=int] ...)
+func (n *AttrNode) String() string {
+	var sb strings.Builder
+	n.write(&sb)
+	return sb.String()
+}
+
+// write 将节点写入 sb
+func (n *AttrNode) write(sb *strings.Builder) {
+	if n.Production < 0 {
+		sb.WriteString(n.Lexeme)
+	} else {
+		sb.WriteString("(")
+		sb.WriteString(string(n.Symbol))
+	}
+	var attrs []string
+	for name, instance := range n.attrs {
+		if instance.done {
+			attrs = append(attrs, fmt.Sprintf("%s=%v", name, instance.value))
+		}
+	}
+	if len(attrs) > 0 {
+		slices.Sort(attrs)
+		sb.WriteString("[" + strings.Join(attrs, " ") + "]")
+	}
+	if n.Production < 0 {
+		return
+	}
+	for _, child := range n.Children {
+		sb.WriteString(" ")
+		child.write(sb)
+	}
+	sb.WriteString(")")
+}
+
+// attrInstance 表示语法树中某个节点的一个属性
+type attrInstance struct {
+	node       *AttrNode
+	decl       AttrDecl
+	value      any
+	done       bool
+	rule       *AttrRule       // 计算这个属性的规则，所在的产生式规约之后才会设置
+	args       []*attrInstance // 规则依赖的属性
+	dependents []*attrInstance // 依赖这个属性的属性
+	waiting    int             // 还没有计算出来的依赖个数
+}
+
+// AttrEvaluator 在 LR 分析过程中建立语法树并计算属性
+/*
+	每次规约时建立一个新的节点，为产生式中的每条规则建立依赖关系：
+	1. L 属性文法：依赖都计算完成的属性立即计算，所以综合属性在规约时就能得到，
+	   依赖继承属性的部分会在父节点规约、继承属性得到之后继续计算
+	2. 其他文法：分析结束之后在依赖图上按照拓扑顺序计算，存在循环依赖时报错
+*/
+type AttrEvaluator struct {
+	Grammar   *AttributeGrammar
+	Eager     bool        // 是否在分析过程中计算属性，只有 L 属性文法才会在分析过程中计算
+	Reasons   []string    // 不是 L 属性文法的原因，Eager 为 false 时由调用者决定是否报告
+	stack     []*AttrNode // 与符号栈对应的节点栈
+	instances []*attrInstance
+	types     map[string]string // 属性规则记录的变量类型，例如 TYPE_ATTRIBUTES 中声明的变量
+}
+
+// UseAttributes 在分析过程中使用属性文法，属性文法不是良定义的时候返回错误
+// 不是 L 属性文法时属性在分析结束之后计算，原因记录在 p.Attributes.Reasons 中
+func (p *Parser) UseAttributes(ag *AttributeGrammar) error {
+	if errs := ag.Check(p.Grammar); len(errs) > 0 {
+		messages := make([]string, len(errs))
+		for i, err := range errs {
+			messages[i] = err.Error()
+		}
+		return fmt.Errorf("属性文法不是良定义的：\n%s\n", strings.Join(messages, "\n"))
+	}
+	eager, reasons := ag.LAttributed(p.Grammar)
+	p.Attributes = &AttrEvaluator{Grammar: ag, Eager: eager, Reasons: reasons, types: make(map[string]string)}
+	return nil
+}
+
+// reset 在新的分析开始时清空节点栈
+func (e *AttrEvaluator) reset() {
+	e.stack, e.instances, e.types = nil, nil, make(map[string]string)
+}
+
+// declare 为节点的符号声明的每个属性建立属性实例
+func (e *AttrEvaluator) declare(node *AttrNode) {
+	for _, decl := range e.Grammar.Attrs {
+		if decl.Symbol == node.Symbol {
+			instance := &attrInstance{node: node, decl: decl}
+			node.attrs[decl.Name] = instance
+			e.instances = append(e.instances, instance)
+		}
+	}
+}
+
+// reduce 在规约时调用，建立节点并设置规则
+func (e *AttrEvaluator) reduce(p *Parser, index int) error {
+	// 错误恢复会直接弹出符号栈，移入只会压入终结符，这里让节点栈与符号栈重新对齐
+	if len(e.stack) > len(p.TokenStack) {
+		e.stack = e.stack[:len(p.TokenStack)]
+	}
+	for i := len(e.stack); i < len(p.TokenStack); i++ {
+		e.stack = append(e.stack, &AttrNode{Production: -1, Lexeme: string(p.TokenStack[i]), attrs: make(map[string]*attrInstance)})
+	}
+
+	production := p.Grammar.Productions[index]
+	body := rhs(production.Body)
+	children := e.stack[len(e.stack)-len(body):]
+	node := &AttrNode{Symbol: production.Head, Production: index, attrs: make(map[string]*attrInstance)}
+	e.declare(node)
+	for i, child := range children {
+		if IsMarker(body[i]) {
+			continue
+		}
+		// 叶子节点移入时只知道字面值，这里补上终结符本身和终结符的继承属性
+		if child.Production < 0 && child.Symbol == "" {
+			child.Symbol = body[i]
+			e.declare(child)
+		}
+		node.Children = append(node.Children, child)
+	}
+	e.stack = append(e.stack[:len(e.stack)-len(body)], node)
+
+	for i := range e.Grammar.Rules {
+		rule := &e.Grammar.Rules[i]
+		if rule.Production != index {
+			continue
+		}
+		target := node.instance(rule.Target)
+		target.rule = rule
+		for _, ref := range rule.Args {
+			arg := node.instance(ref)
+			target.args = append(target.args, arg)
+			if !arg.done {
+				arg.dependents = append(arg.dependents, target)
+				target.waiting++
+			}
+		}
+		if e.Eager && target.waiting == 0 {
+			if err := e.evaluate(p, target); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// instance 返回属性引用在节点中对应的属性实例，lexeme 属性在第一次使用时建立
+func (n *AttrNode) instance(ref AttrRef) *attrInstance {
+	node := n
+	if ref.Index > 0 {
+		node = n.Children[ref.Index-1]
+	}
+	if ref.Name == LEXEME_ATTR && node.Production < 0 {
+		return &attrInstance{node: node, value: node.Lexeme, done: true}
+	}
+	return node.attrs[ref.Name]
+}
+
+// evaluate 计算一个属性，然后继续计算所有因此可以计算的属性
+func (e *AttrEvaluator) evaluate(p *Parser, instance *attrInstance) error {
+	queue := []*attrInstance{instance}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		args := make([]any, len(current.args))
+		for i, arg := range current.args {
+			args[i] = arg.value
+		}
+		value, err := current.rule.Compute(p, args)
+		if err != nil {
+			return fmt.Errorf("计算属性 %s.%s 时出错：%v", current.node.Symbol, current.decl.Name, err)
+		}
+		if value == nil || !reflect.TypeOf(value).AssignableTo(current.decl.Type) {
+			return fmt.Errorf("属性 %s.%s 的类型应该是 %s，实际是 %T", current.node.Symbol, current.decl.Name, current.decl.Type, value)
+		}
+		current.value, current.done = value, true
+
+		for _, dependent := range current.dependents {
+			dependent.waiting--
+			if dependent.waiting == 0 && dependent.rule != nil {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+	return nil
+}
+
+// Root 返回分析得到的语法树的根节点，并计算剩余的属性
+// 不是 L 属性文法时，在这里按照依赖图的拓扑顺序计算所有属性
+func (e *AttrEvaluator) Root(p *Parser) (*AttrNode, error) {
+	if len(e.stack) == 0 {
+		return nil, fmt.Errorf("还没有分析任何输入")
+	}
+	if !e.Eager {
+		for _, instance := range e.instances {
+			if !instance.done && instance.rule != nil && instance.waiting == 0 {
+				if err := e.evaluate(p, instance); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	for _, instance := range e.instances {
+		if !instance.done && instance.rule != nil {
+			return nil, fmt.Errorf("属性 %s.%s 存在循环依赖，无法计算", instance.node.Symbol, instance.decl.Name)
+		}
+	}
+	return e.stack[len(e.stack)-1], nil
+}
@@ -0,0 +1,382 @@
+// conflict.go
+// 分析表冲突的结构化记录与反例推导
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// Conflict 表示分析表中的一次冲突
+type Conflict struct {
+	State     int             // 发生冲突的状态
+	Lookahead consts.Terminal // 发生冲突的展望符
+	Kind      string          // 冲突类型，例如 "shift/reduce"、"reduce/reduce"
+	Chosen    ActionEntry     // 表中保留的动作（先写入的动作）
+	Rejected  ActionEntry     // 被丢弃的动作
+	Items     [2]LR1Items     // 分别导致 Chosen 和 Rejected 的项
+	Example   *Counterexample // 反例，由 AnalyzeConflicts 填充
+
+	// 冲突记录在 buildActionTable 中产生，此时只知道状态、展望符和两个动作，
+	// 参与冲突的项和反例推导需要调用 AnalyzeConflicts 才会计算。
+}
+
+// Counterexample 表示一个冲突的反例
+// 它给出一个最短的可行前缀，以及在该前缀上分别执行两个动作时对应的推导过程。
+type Counterexample struct {
+	Prefix      []consts.Symbol // 从初始状态到达冲突状态的最短符号串
+	Lookahead   consts.Terminal // 冲突的展望符
+	Derivations [2]Derivation   // 分别对应 Chosen 和 Rejected 的推导
+
+	// 与 Bison 的 counterexample 类似，对于悬挂 else 会给出：
+	// if ( bool ) if ( bool ) stmt • else stmt
+	// 移入时 else 属于内层 if，规约时 else 属于外层 if
+}
+
+// Derivation 表示一条从公共祖先到冲突项的推导链
+type Derivation struct {
+	Action ActionEntry       // 推导对应的动作
+	Frames []DerivationFrame // 由外向内的产生式，每一层都展开了上一层点后面的非终结符
+}
+
+// DerivationFrame 表示推导链中的一层
+// 对于除最后一层以外的层，Position 指向被展开的非终结符；最后一层的 Position 就是冲突项的点位置。
+type DerivationFrame struct {
+	Production Production
+	Position   int
+}
+
+// addConflict 记录一次冲突
+func (p *Parser) addConflict(state int, lookahead consts.Terminal, chosen, rejected ActionEntry) {
+	for _, c := range p.Conflicts {
+		if c.State == state && c.Lookahead == lookahead && c.Chosen == chosen && c.Rejected == rejected {
+			return
+		}
+	}
+
+	p.Conflicts = append(p.Conflicts, Conflict{
+		State:     state,
+		Lookahead: lookahead,
+		Kind:      chosen.ActionType + "/" + rejected.ActionType,
+		Chosen:    chosen,
+		Rejected:  rejected,
+	})
+}
+
+// AnalyzeConflicts 分析所有冲突，补充冲突项和反例推导
+// 需要在 BuildTables 之后调用
+func (p *Parser) AnalyzeConflicts() []Conflict {
+	for i := range p.Conflicts {
+		c := &p.Conflicts[i]
+		c.Items[0] = p.conflictItems(c.State, c.Lookahead, c.Chosen)
+		c.Items[1] = p.conflictItems(c.State, c.Lookahead, c.Rejected)
+		c.Example = p.counterexample(c)
+	}
+	return p.Conflicts
+}
+
+// PrintConflicts 打印所有冲突及其反例
+func (p *Parser) PrintConflicts() {
+	conflicts := p.AnalyzeConflicts()
+	fmt.Println("冲突分析 - 共有", len(conflicts), "个冲突")
+	for _, c := range conflicts {
+		fmt.Println(c.String())
+	}
+}
+
+// String 返回冲突的可读描述
+func (c Conflict) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "状态 %d 在展望符 '%s' 上存在 %s 冲突，保留 %s，舍弃 %s\n", c.State, c.Lookahead, c.Kind, c.Chosen.Short(), c.Rejected.Short())
+	for i, items := range c.Items {
+		action := c.Chosen
+		if i == 1 {
+			action = c.Rejected
+		}
+		for _, item := range items {
+			fmt.Fprintf(&sb, "  [%s] %s\n", action.Short(), formatItem(item))
+		}
+	}
+	if c.Example != nil {
+		sb.WriteString(c.Example.String())
+	}
+	return sb.String()
+}
+
+// String 返回反例的可读描述
+func (ce *Counterexample) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "  可行前缀: %s\n", formatSymbols(ce.Prefix))
+	for _, d := range ce.Derivations {
+		fmt.Fprintf(&sb, "  %s 例子: %s\n", d.Action.Short(), d.Sentence())
+		fmt.Fprintf(&sb, "  %s 推导:\n", d.Action.Short())
+		for depth, frame := range d.Frames {
+			indent := strings.Repeat("  ", depth+2)
+			if depth > 0 {
+				indent += "↳ "
+			}
+			fmt.Fprintf(&sb, "%s%s\n", indent, frame.format(depth == len(d.Frames)-1))
+		}
+	}
+	return sb.String()
+}
+
+// Short 返回动作的简写，例如 s5、r12、acc
+func (e ActionEntry) Short() string {
+	switch e.ActionType {
+	case SHIFT:
+		return fmt.Sprintf("s%d", e.Number)
+	case REDUCE:
+		return fmt.Sprintf("r%d", e.Number)
+	case ACCEPT:
+		return "acc"
+	}
+	return e.ActionType
+}
+
+// Sentence 返回推导对应的句型，用 • 标出冲突发生的位置
+func (d Derivation) Sentence() string {
+	var left, right []consts.Symbol
+	for i, frame := range d.Frames {
+		body := frame.Production.Body
+		left = append(left, body[:frame.Position]...)
+		rest := frame.Position + 1 // 跳过被展开的非终结符
+		if i == len(d.Frames)-1 {
+			rest = frame.Position
+		}
+		right = append(append([]consts.Symbol{}, body[rest:]...), right...)
+	}
+	return strings.TrimSpace(formatSymbols(left) + " • " + formatSymbols(right))
+}
+
+// format 格式化推导链中的一层，last 表示是否为最内层
+func (f DerivationFrame) format(last bool) string {
+	if !last {
+		return fmt.Sprintf("%s → %s", f.Production.Head, formatSymbols(f.Production.Body))
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s → %s • %s", f.Production.Head,
+		formatSymbols(f.Production.Body[:f.Position]), formatSymbols(f.Production.Body[f.Position:])))
+}
+
+// formatItem 格式化一个 LR(1) 项，例如 [stmt → if ( bool ) stmt •, else]
+func formatItem(item LR1Item) string {
+	frame := DerivationFrame{Production: item.Production, Position: item.Position}
+	return fmt.Sprintf("[%s, %s]", frame.format(true), item.Lookahead)
+}
+
+// formatSymbols 以空格连接符号串，忽略 EPSILON
+func formatSymbols(symbols []consts.Symbol) string {
+	parts := make([]string, 0, len(symbols))
+	for _, sym := range symbols {
+		if sym == EPSILON {
+			continue
+		}
+		parts = append(parts, string(sym))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatBody 格式化产生式体，空串显示为 ε
+func formatBody(body []consts.Symbol) string {
+	if text := formatSymbols(body); text != "" {
+		return text
+	}
+	return "ε"
+}
+
+// isReduceItem 判断项的点是否已经到达产生式末尾（EPSILON 产生式的点在 EPSILON 之前也视为到达末尾）
+func isReduceItem(item LR1Item) bool {
+	body := item.Production.Body
+	return item.Position == len(body) || (item.Position == len(body)-1 && body[item.Position] == EPSILON)
+}
+
+// conflictItems 找出状态中导致某个动作的所有项
+func (p *Parser) conflictItems(state int, lookahead consts.Terminal, action ActionEntry) LR1Items {
+	var items LR1Items
+	for _, item := range p.StateCollection[state].Items {
+		switch action.ActionType {
+		case SHIFT:
+			if !isReduceItem(item) && item.Production.Body[item.Position] == consts.Symbol(lookahead) {
+				items = append(items, item)
+			}
+		case REDUCE, ACCEPT:
+			if isReduceItem(item) && item.Lookahead == lookahead && p.productionIndex(item.Production) == action.Number {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// productionIndex 返回产生式在文法中的编号，增广产生式返回 0（与 ACCEPT 动作的编号一致）
+func (p *Parser) productionIndex(prod Production) int {
+	if p.isAugmented(prod) {
+		return 0
+	}
+	for index, candidate := range p.Grammar.Productions {
+		if equalProductions(candidate, prod) {
+			return index
+		}
+	}
+	return -1
+}
+
+// shortestPrefix 在状态转移图上做广度优先搜索，返回到达目标状态的最短符号串及途经的状态
+func (p *Parser) shortestPrefix(target int) ([]consts.Symbol, []int) {
+	type node struct {
+		parent int
+		symbol consts.Symbol
+	}
+	visited := map[int]node{0: {parent: -1}}
+	queue := []int{0}
+	symbols := p.getAllSymbols()
+
+	for len(queue) > 0 && target != queue[0] {
+		state := queue[0]
+		queue = queue[1:]
+		// 按照固定的符号顺序遍历，保证结果是确定的
+		for _, sym := range symbols {
+			next, ok := p.Transitions[state][sym]
+			if !ok {
+				continue
+			}
+			if _, seen := visited[next]; !seen {
+				visited[next] = node{parent: state, symbol: sym}
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	if _, ok := visited[target]; !ok {
+		return nil, nil
+	}
+
+	var prefix []consts.Symbol
+	states := []int{target}
+	for state := target; visited[state].parent != -1; state = visited[state].parent {
+		prefix = append([]consts.Symbol{visited[state].symbol}, prefix...)
+		states = append([]int{visited[state].parent}, states...)
+	}
+	return prefix, states
+}
+
+// counterexample 为冲突构造反例
+func (p *Parser) counterexample(c *Conflict) *Counterexample {
+	if len(c.Items[0]) == 0 || len(c.Items[1]) == 0 {
+		return nil
+	}
+	prefix, states := p.shortestPrefix(c.State)
+	if states == nil {
+		return nil
+	}
+
+	example := &Counterexample{Prefix: prefix, Lookahead: c.Lookahead}
+	actions := [2]ActionEntry{c.Chosen, c.Rejected}
+	for i := range actions {
+		frames := p.derivationFrames(prefix, states, c.Items[i][0])
+		if frames == nil {
+			return nil
+		}
+		example.Derivations[i] = Derivation{Action: actions[i], Frames: frames}
+	}
+
+	// 去掉两条推导共同的外层，只保留最近的公共祖先
+	common := 0
+	first, second := example.Derivations[0].Frames, example.Derivations[1].Frames
+	for common < len(first)-1 && common < len(second)-1 &&
+		equalProductions(first[common].Production, second[common].Production) && first[common].Position == second[common].Position {
+		common++
+	}
+	if common > 0 {
+		example.Derivations[0].Frames = first[common-1:]
+		example.Derivations[1].Frames = second[common-1:]
+	}
+	return example
+}
+
+// derivationFrames 沿着可行前缀在项之间搜索，得到从增广产生式到目标项的推导链
+/*
+	搜索的节点是 (前缀位置 k, 状态 states[k] 中的某个项)，边有两种：
+	1. 闭包边：[A → α • B β, a] 到同一状态中的 [B → • γ, b]，其中 b ∈ FIRST(β a)
+	2. 移入边：[A → α • X β, a] 到下一个状态中的 [A → α X • β, a]，其中 X 是前缀的第 k+1 个符号
+	由于展望符也参与了边的判断，搜索得到的推导一定能在冲突的展望符上发生冲突。
+*/
+func (p *Parser) derivationFrames(prefix []consts.Symbol, states []int, target LR1Item) []DerivationFrame {
+	type node struct {
+		k    int
+		item LR1Item
+	}
+	type visit struct {
+		parent  string
+		closure bool // 是否经由闭包边到达
+		node    node
+	}
+
+	key := func(n node) string { return fmt.Sprintf("%d#%s", n.k, itemKey(n.item)) }
+	start := node{k: 0, item: LR1Item{Production: p.Grammar.AugmentedProduction(), Position: 0, Lookahead: TERMINATE_SYMBOL}}
+	visited := map[string]visit{key(start): {node: start}}
+	queue := []node{start}
+	last := len(prefix)
+
+	var found string
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.k == last && equalLR1Items(current.item, target) {
+			found = key(current)
+			break
+		}
+		if isReduceItem(current.item) {
+			continue
+		}
+
+		item := current.item
+		nextSym := item.Production.Body[item.Position]
+		enqueue := func(n node, closure bool) {
+			if _, seen := visited[key(n)]; !seen {
+				visited[key(n)] = visit{parent: key(current), closure: closure, node: n}
+				queue = append(queue, n)
+			}
+		}
+
+		// 移入边
+		if current.k < last && nextSym == prefix[current.k] {
+			advanced := LR1Item{Production: item.Production, Position: item.Position + 1, Lookahead: item.Lookahead}
+			if contains(p.StateCollection[states[current.k+1]].Items, advanced) {
+				enqueue(node{k: current.k + 1, item: advanced}, false)
+			}
+		}
+
+		// 闭包边
+		if !p.Grammar.IsTerminal(nextSym) {
+			lookaheads := p.computeLookahead(item.Production.Body[item.Position+1:], item.Lookahead)
+			for _, candidate := range p.StateCollection[states[current.k]].Items {
+				if candidate.Position == 0 && candidate.Production.Head == nextSym && lookaheads[candidate.Lookahead] {
+					enqueue(node{k: current.k, item: candidate}, true)
+				}
+			}
+		}
+	}
+
+	if found == "" {
+		return nil
+	}
+
+	// 回溯路径，闭包边对应新的一层，移入边对应当前层的点右移
+	var path []visit
+	for k := found; k != ""; k = visited[k].parent {
+		path = append([]visit{visited[k]}, path...)
+	}
+	frames := []DerivationFrame{{Production: path[0].node.item.Production, Position: 0}}
+	for _, v := range path[1:] {
+		if v.closure {
+			frames = append(frames, DerivationFrame{Production: v.node.item.Production, Position: 0})
+		} else {
+			frames[len(frames)-1].Position = v.node.item.Position
+		}
+	}
+	return frames
+}
@@ -25,7 +25,7 @@ var (
 		"if", "else", "while", "do", "break",
 		"true", "false",
 		"basic", "id", "num", "real",
-		"int", "bool", "string", "float", "byte",
+		"int", "string", "float", "byte",
 		EPSILON, TERMINATE_SYMBOL,
 	}
 
@@ -35,6 +35,11 @@ var (
 	// TERMINATE_SYMBOL 表示终结符
 	TERMINATE_SYMBOL = consts.Terminal("$")
 
+	// ERROR_TERMINAL 表示错误产生式中保留的 error 终结符
+	// 词法分析器不会产生这个终结符（标识符 error 会被转换为 id），只有在错误恢复时由分析程序插入
+	// 它不在 TERMINALS 中，WithErrorProductions 加入错误产生式时才把它加入终结符集合
+	ERROR_TERMINAL = consts.Terminal("error")
+
 	// PRODUCTIONS 表示所有产生式
 	// 每一个 PRODUCTION 表示一个产生式，其中 Head 表示产生式的头部，Body 表示产生式的体部
 	// 例如，对于产生式 E -> E + T，E 是头部，E + T 是体部
@@ -51,46 +56,96 @@ var (
 		8:  {"stmts", []consts.Symbol{"stmts", "stmt"}, genStmts},
 		9:  {"stmts", []consts.Symbol{EPSILON}, genStmtsEpsilon},
 		10: {"stmt", []consts.Symbol{"loc", "=", "bool", ";"}, genStmt},
-		11: {"stmt", []consts.Symbol{"loc", "=", "num", ";"}, genStmt},
 		// {"stmt", []consts.Symbol{"loc", "=", "id", ";"}},
-		12: {"stmt", []consts.Symbol{"if", "(", "bool", ")", "stmt"}, genStmtIf},
-		13: {"stmt", []consts.Symbol{"if", "(", "bool", ")", "stmt", "else", "stmt"}, genStmtIfElse},
-		14: {"stmt", []consts.Symbol{"while", "(", "bool", ")", "stmt"}, genStmtWhile},
-		15: {"stmt", []consts.Symbol{"do", "stmt", "while", "(", "bool", ")", ";"}, genStmtDoWhile},
-		16: {"stmt", []consts.Symbol{"break", ";"}, genStmtBreak},
-		17: {"stmt", []consts.Symbol{"block"}, genStmtBlock},
+		11: {"stmt", []consts.Symbol{"if", "(", "bool", ")", "stmt"}, genStmtIf},
+		12: {"stmt", []consts.Symbol{"if", "(", "bool", ")", "stmt", "else", "stmt"}, genStmtIfElse},
+		13: {"stmt", []consts.Symbol{"while", "(", "bool", ")", "stmt"}, genStmtWhile},
+		14: {"stmt", []consts.Symbol{"do", "stmt", "while", "(", "bool", ")", ";"}, genStmtDoWhile},
+		15: {"stmt", []consts.Symbol{"break", ";"}, genStmtBreak},
+		16: {"stmt", []consts.Symbol{"block"}, genStmtBlock},
 		// {"loc", []consts.Symbol{"loc[num]"}},
-		18: {"loc", []consts.Symbol{"loc_array"}, genLocArray},                       // 新增产生式
-		19: {"loc_array", []consts.Symbol{"loc", "[", "num", "]"}, genLocArrayFinal}, // 新增产生式
+		17: {"loc", []consts.Symbol{"loc_array"}, genLocArray},                       // 新增产生式
+		18: {"loc_array", []consts.Symbol{"loc", "[", "num", "]"}, genLocArrayFinal}, // 新增产生式
 		// {"loc", []consts.Symbol{"loc", "[", "num", "]"}},
-		20: {"loc", []consts.Symbol{"id"}, genLoc},
-		21: {"bool", []consts.Symbol{"bool", "||", "join"}, genBoolOr},
-		22: {"bool", []consts.Symbol{"join"}, genBool},
-		23: {"join", []consts.Symbol{"join", "&&", "equality"}, genJoinAnd},
-		24: {"join", []consts.Symbol{"equality"}, genJoin},
-		25: {"equality", []consts.Symbol{"equality", "==", "rel"}, genEqualityEqual},
-		26: {"equality", []consts.Symbol{"equality", "!=", "rel"}, genEqualityNotEqual},
-		27: {"equality", []consts.Symbol{"rel"}, genEquality},
-		28: {"rel", []consts.Symbol{"expr", "<", "expr"}, genRelLess},
-		29: {"rel", []consts.Symbol{"expr", "<=", "expr"}, genRelLessEqual},
-		30: {"rel", []consts.Symbol{"expr", ">=", "expr"}, genRelGreaterEqual},
-		31: {"rel", []consts.Symbol{"expr", ">", "expr"}, genRelGreater},
-		32: {"rel", []consts.Symbol{"expr"}, genRel},
-		33: {"expr", []consts.Symbol{"expr", "+", "term"}, genExprAdd},
-		34: {"expr", []consts.Symbol{"expr", "-", "term"}, genExprSub},
-		35: {"expr", []consts.Symbol{"term"}, genExpr},
-		36: {"term", []consts.Symbol{"term", "*", "unary"}, genTermMul},
-		37: {"term", []consts.Symbol{"term", "/", "unary"}, genTermDiv},
-		38: {"term", []consts.Symbol{"unary"}, genTerm},
-		39: {"unary", []consts.Symbol{"!", "unary"}, genUnaryNeg},
-		40: {"unary", []consts.Symbol{"-", "unary"}, genUnaryNot},
-		41: {"unary", []consts.Symbol{"factor"}, genUnary},
-		42: {"factor", []consts.Symbol{"(", "bool", ")"}, genFactorBool},
-		43: {"factor", []consts.Symbol{"loc"}, genFactorLoc},
-		44: {"factor", []consts.Symbol{"num"}, genFactorNum},
-		45: {"factor", []consts.Symbol{"real"}, genFactorReal},
-		46: {"factor", []consts.Symbol{"true"}, genFactorTrue},
-		47: {"factor", []consts.Symbol{"false"}, genFactorFalse},
+		19: {"loc", []consts.Symbol{"id"}, genLoc},
+		20: {"bool", []consts.Symbol{"bool", "||", "join"}, genBoolOr},
+		21: {"bool", []consts.Symbol{"join"}, genBool},
+		22: {"join", []consts.Symbol{"join", "&&", "equality"}, genJoinAnd},
+		23: {"join", []consts.Symbol{"equality"}, genJoin},
+		24: {"equality", []consts.Symbol{"equality", "==", "rel"}, genEqualityEqual},
+		25: {"equality", []consts.Symbol{"equality", "!=", "rel"}, genEqualityNotEqual},
+		26: {"equality", []consts.Symbol{"rel"}, genEquality},
+		27: {"rel", []consts.Symbol{"expr", "<", "expr"}, genRelLess},
+		28: {"rel", []consts.Symbol{"expr", "<=", "expr"}, genRelLessEqual},
+		29: {"rel", []consts.Symbol{"expr", ">=", "expr"}, genRelGreaterEqual},
+		30: {"rel", []consts.Symbol{"expr", ">", "expr"}, genRelGreater},
+		31: {"rel", []consts.Symbol{"expr"}, genRel},
+		32: {"expr", []consts.Symbol{"expr", "+", "term"}, genExprAdd},
+		33: {"expr", []consts.Symbol{"expr", "-", "term"}, genExprSub},
+		34: {"expr", []consts.Symbol{"term"}, genExpr},
+		35: {"term", []consts.Symbol{"term", "*", "unary"}, genTermMul},
+		36: {"term", []consts.Symbol{"term", "/", "unary"}, genTermDiv},
+		37: {"term", []consts.Symbol{"unary"}, genTerm},
+		38: {"unary", []consts.Symbol{"!", "unary"}, genUnaryNot},
+		39: {"unary", []consts.Symbol{"-", "unary"}, genUnaryNeg},
+		40: {"unary", []consts.Symbol{"factor"}, genUnary},
+		41: {"factor", []consts.Symbol{"(", "bool", ")"}, genFactorBool},
+		42: {"factor", []consts.Symbol{"loc"}, genFactorLoc},
+		43: {"factor", []consts.Symbol{"num"}, genFactorNum},
+		44: {"factor", []consts.Symbol{"real"}, genFactorReal},
+		45: {"factor", []consts.Symbol{"true"}, genFactorTrue},
+		46: {"factor", []consts.Symbol{"false"}, genFactorFalse},
+	}
+
+	// ERROR_PRODUCTIONS 表示用于语法错误恢复的错误产生式，默认不在文法中，需要时通过 WithErrorProductions 加入
+	ERROR_PRODUCTIONS = []Production{
+		{"stmt", []consts.Symbol{"error", ";"}, genStmtError},
+		{"block", []consts.Symbol{"{", "decls", "stmts", "error", "}"}, genBlockError}, // 也覆盖了 { error } 的情况，写成 { error } 会与 decls → ε 产生冲突
+	}
+
+	// MID_ACTIONS 表示产生式体中间的语义动作，键是产生式在 PRODUCTIONS 中的编号
+	// 控制流语句的标签必须在子语句的代码之前生成，所以这些代码不能等到整个语句规约时再生成
+	// if 语句和 if-else 语句的前缀相同，所以它们共享 @ifThen
+	MID_ACTIONS = map[int][]MidAction{
+		11: {{4, "ifThen", genIfThen}},                                         // if ( bool ) @ifThen stmt
+		12: {{4, "ifThen", genIfThen}, {6, "ifElse", genIfElse}},               // if ( bool ) @ifThen stmt else @ifElse stmt
+		13: {{1, "whileBegin", genWhileBegin}, {4, "whileBody", genWhileBody}}, // while @whileBegin ( bool ) @whileBody stmt
+		14: {{1, "doBegin", genDoBegin}},                                       // do @doBegin stmt while ( bool ) ;
+	}
+
+	// TYPE_ATTRIBUTES 表示声明语句的属性文法，用继承属性把类型传给 id，语义值栈只能向上传递综合属性
+	// type 和 type_array 的 type、width 是综合属性，id 的 type 是继承属性：
+	// 在 decl → type id ; 中由 type 传给 id，在 loc → id 中查出变量声明时的类型
+	// type → type [ num ] 是左递归的，最先规约的是最左边的维度，所以先用 base、dims 收集基本类型和各个维度，
+	// 再从右向左构造类型，int[3][4] 的类型是 array(3, array(4, int))
+	TYPE_ATTRIBUTES = &AttributeGrammar{
+		Attrs: []AttrDecl{
+			SynAttr[string]("type", "type"), SynAttr[int]("type", "width"),
+			SynAttr[string]("type", "base"), SynAttr[[]string]("type", "dims"),
+			SynAttr[string]("type_array", "type"), SynAttr[int]("type_array", "width"),
+			SynAttr[string]("type_array", "base"), SynAttr[[]string]("type_array", "dims"),
+			SynAttr[string]("decl", "name"), SynAttr[string]("decl", "type"), SynAttr[int]("decl", "width"),
+			InhAttr[string]("id", "type"),
+		},
+		Rules: []AttrRule{
+			{4, Attr(2, "type"), []AttrRef{Attr(1, "type")}, attrCopy},                                             // id.type = type.type
+			{4, Attr(0, "name"), []AttrRef{Attr(2, LEXEME_ATTR)}, attrCopy},                                        // decl.name = id.lexeme
+			{4, Attr(0, "type"), []AttrRef{Attr(2, LEXEME_ATTR), Attr(2, "type")}, attrDefineType},                 // decl.type = define(id.lexeme, id.type)
+			{4, Attr(0, "width"), []AttrRef{Attr(1, "width")}, attrCopy},                                           // decl.width = type.width
+			{5, Attr(0, "type"), []AttrRef{Attr(1, "type")}, attrCopy},                                             // type.type = type_array.type
+			{5, Attr(0, "width"), []AttrRef{Attr(1, "width")}, attrCopy},                                           // type.width = type_array.width
+			{5, Attr(0, "base"), []AttrRef{Attr(1, "base")}, attrCopy},                                             // type.base = type_array.base
+			{5, Attr(0, "dims"), []AttrRef{Attr(1, "dims")}, attrCopy},                                             // type.dims = type_array.dims
+			{6, Attr(0, "type"), []AttrRef{Attr(1, "base"), Attr(1, "dims"), Attr(3, LEXEME_ATTR)}, attrArrayType}, // type_array.type = fold(type.base, type.dims + num)
+			{6, Attr(0, "width"), []AttrRef{Attr(3, LEXEME_ATTR), Attr(1, "width")}, attrArrayWidth},               // type_array.width = num × type.width
+			{6, Attr(0, "base"), []AttrRef{Attr(1, "base")}, attrCopy},                                             // type_array.base = type.base
+			{6, Attr(0, "dims"), []AttrRef{Attr(1, "dims"), Attr(3, LEXEME_ATTR)}, attrAppendDim},                  // type_array.dims = type.dims + num
+			{7, Attr(0, "type"), []AttrRef{Attr(1, LEXEME_ATTR)}, attrCopy},                                        // type.type = basic.lexeme
+			{7, Attr(0, "width"), []AttrRef{Attr(1, LEXEME_ATTR)}, attrBasicWidth},                                 // type.width = width(basic)
+			{7, Attr(0, "base"), []AttrRef{Attr(1, LEXEME_ATTR)}, attrCopy},                                        // type.base = basic.lexeme
+			{7, Attr(0, "dims"), nil, attrNoDims},                                                                  // type.dims = []
+			{19, Attr(1, "type"), []AttrRef{Attr(1, LEXEME_ATTR)}, attrLookupType},                                 // id.type = lookup(id.lexeme)
+		},
 	}
 )
 
@@ -0,0 +1,230 @@
+// coverage.go
+// 产生式覆盖率：统计一组测试输入使用了哪些产生式和哪些 Action 表项
+
+package parser
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/intercoder"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// Coverage 记录分析过程中每个产生式的规约次数和每个 Action 表项的使用次数
+type Coverage struct {
+	Reductions map[int]int                     // 产生式编号 → 规约次数
+	Actions    map[int]map[consts.Terminal]int // 状态 → 终结符 → 使用次数
+	Inputs     []CoverageInput                 // 统计过的输入
+}
+
+// CoverageInput 表示统计过的一个输入文件和它的分析结果
+type CoverageInput struct {
+	File string
+	Err  error
+}
+
+// EnableCoverage 开启覆盖率统计，之后每次调用 Parse 都会累加到 p.Coverage 中
+func (p *Parser) EnableCoverage() {
+	p.Coverage = &Coverage{
+		Reductions: make(map[int]int),
+		Actions:    make(map[int]map[consts.Terminal]int),
+	}
+}
+
+// useAction 记录一次 Action 表项的使用
+func (c *Coverage) useAction(state int, terminal consts.Terminal) {
+	if c.Actions[state] == nil {
+		c.Actions[state] = make(map[consts.Terminal]int)
+	}
+	c.Actions[state][terminal]++
+}
+
+// CoverCorpus 依次分析匹配 pattern 的所有文件（例如 tests/*.in），累加覆盖率
+// 每个文件使用新的符号表和三地址码，分析失败的文件也会记录下来，不会中断统计
+// 统计期间不输出分析过程，结束后恢复原来的 Tracer；处理函数 panic 时留下的分析栈和标签栈由下一次 Parse 的 beginParse 清空
+func (p *Parser) CoverCorpus(pattern string) error {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("没有找到匹配 %s 的文件", pattern)
+	}
+	if p.Coverage == nil {
+		p.EnableCoverage()
+	}
+	tracer := p.Tracer
+	p.Tracer = SilentTracer{}
+	defer func() { p.Tracer = tracer }()
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		p.SymbolTable = *intercoder.NewSymbolTable()
+		p.ThreeAddress = nil
+		err = p.parseCovered(lexer.NewLexer(file))
+		file.Close()
+		p.Coverage.Inputs = append(p.Coverage.Inputs, CoverageInput{File: name, Err: err})
+	}
+	return nil
+}
+
+// parseCovered 分析一个输入，处理函数中的 panic 也作为这个输入的错误记录下来
+func (p *Parser) parseCovered(l *lexer.Lexer) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("处理函数发生 panic：%v", r)
+		}
+	}()
+	return p.Parse(l)
+}
+
+// CoverageReport 表示覆盖率报告
+type CoverageReport struct {
+	Inputs          []CoverageInput
+	Productions     []ProductionCoverage // 所有产生式的规约次数，按编号排列
+	NeverReduced    []ProductionCoverage // 从来没有规约过的产生式
+	Actions         []ActionCoverage     // 所有 Action 表项的使用次数，按状态和终结符排列
+	NeverUsed       []ActionCoverage     // 从来没有使用过的 Action 表项
+	ReducedCount    int                  // 至少规约过一次的产生式个数
+	UsedActionCount int                  // 至少使用过一次的 Action 表项个数
+}
+
+// ProductionCoverage 表示一个产生式的覆盖情况
+type ProductionCoverage struct {
+	Index int
+	Head  consts.Symbol
+	Body  string
+	Count int
+}
+
+// ActionCoverage 表示一个 Action 表项的覆盖情况
+type ActionCoverage struct {
+	State    int
+	Terminal consts.Terminal
+	Action   string
+	Count    int
+}
+
+// CoverageReport 根据 p.Coverage 和分析表生成覆盖率报告
+// 增广产生式不会被规约（遇到它时直接接受），不计入报告
+func (p *Parser) CoverageReport() CoverageReport {
+	c := p.Coverage
+	if c == nil {
+		c = &Coverage{}
+	}
+	report := CoverageReport{Inputs: c.Inputs}
+	for index, prod := range p.Grammar.Productions {
+		if p.isAugmented(prod) {
+			continue
+		}
+		entry := ProductionCoverage{Index: index, Head: prod.Head, Body: formatBody(prod.Body), Count: c.Reductions[index]}
+		report.Productions = append(report.Productions, entry)
+		if entry.Count == 0 {
+			report.NeverReduced = append(report.NeverReduced, entry)
+		} else {
+			report.ReducedCount++
+		}
+	}
+
+	states := make([]int, 0, len(p.ActionTable))
+	for state := range p.ActionTable {
+		states = append(states, state)
+	}
+	slices.Sort(states)
+	for _, state := range states {
+		terminals := make([]consts.Terminal, 0, len(p.ActionTable[state]))
+		for terminal := range p.ActionTable[state] {
+			terminals = append(terminals, terminal)
+		}
+		slices.Sort(terminals)
+		for _, terminal := range terminals {
+			entry := ActionCoverage{
+				State:    state,
+				Terminal: terminal,
+				Action:   p.ActionTable[state][terminal].Short(),
+				Count:    c.Actions[state][terminal],
+			}
+			report.Actions = append(report.Actions, entry)
+			if entry.Count == 0 {
+				report.NeverUsed = append(report.NeverUsed, entry)
+			} else {
+				report.UsedActionCount++
+			}
+		}
+	}
+	return report
+}
+
+// WriteText 以文本形式输出覆盖率报告
+func (r CoverageReport) WriteText(w io.Writer) {
+	fmt.Fprintln(w, "===============覆盖率报告===============")
+	for _, input := range r.Inputs {
+		status := "成功"
+		if input.Err != nil {
+			status = "失败：" + strings.TrimSpace(input.Err.Error())
+		}
+		fmt.Fprintf(w, "输入 %s：%s\n", input.File, status)
+	}
+	fmt.Fprintf(w, "\n产生式：%d / %d 至少规约过一次\n", r.ReducedCount, len(r.Productions))
+	for _, prod := range r.Productions {
+		fmt.Fprintf(w, "  %4d 次  %d: %s → %s\n", prod.Count, prod.Index, prod.Head, prod.Body)
+	}
+	fmt.Fprintf(w, "\n从来没有规约过的产生式（%d 个）：\n", len(r.NeverReduced))
+	for _, prod := range r.NeverReduced {
+		fmt.Fprintf(w, "  %d: %s → %s\n", prod.Index, prod.Head, prod.Body)
+	}
+	fmt.Fprintf(w, "\nAction 表项：%d / %d 至少使用过一次\n", r.UsedActionCount, len(r.Actions))
+	fmt.Fprintf(w, "从来没有使用过的 Action 表项（%d 个）：\n", len(r.NeverUsed))
+	for _, action := range r.NeverUsed {
+		fmt.Fprintf(w, "  状态 %d, 符号 %s: %s\n", action.State, action.Terminal, action.Action)
+	}
+}
+
+// coverageHTML 是 HTML 报告的模板
+var coverageHTML = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>覆盖率报告</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; }
+.miss { background: #fdd; }
+.hit { background: #dfd; }
+</style>
+</head>
+<body>
+<h1>覆盖率报告</h1>
+<h2>输入</h2>
+<table>
+<tr><th>文件</th><th>结果</th></tr>
+{{range .Inputs}}<tr class="{{if .Err}}miss{{else}}hit{{end}}"><td>{{.File}}</td><td>{{if .Err}}{{.Err}}{{else}}成功{{end}}</td></tr>
+{{end}}</table>
+<h2>产生式：{{.ReducedCount}} / {{len .Productions}} 至少规约过一次</h2>
+<table>
+<tr><th>编号</th><th>产生式</th><th>规约次数</th></tr>
+{{range .Productions}}<tr class="{{if .Count}}hit{{else}}miss{{end}}"><td>{{.Index}}</td><td>{{.Head}} → {{.Body}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+<h2>Action 表项：{{.UsedActionCount}} / {{len .Actions}} 至少使用过一次</h2>
+<table>
+<tr><th>状态</th><th>符号</th><th>动作</th><th>使用次数</th></tr>
+{{range .Actions}}<tr class="{{if .Count}}hit{{else}}miss{{end}}"><td>{{.State}}</td><td>{{.Terminal}}</td><td>{{.Action}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTML 以 HTML 形式输出覆盖率报告，没有覆盖的行标记为红色
+func (r CoverageReport) WriteHTML(w io.Writer) error {
+	return coverageHTML.Execute(w, r)
+}
@@ -0,0 +1,355 @@
+// debugger.go
+// LR 分析的单步调试器：作为 Tracer 接入 Parse，可以单步执行、设置断点、查看栈和项集，并通过记录的事件后退
+
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// Debugger 是交互式的分析调试器
+/*
+	调试器在读入 Token 之后暂停，此时已经知道栈顶状态和当前终结符；规约的断点在调用处理函数之前暂停。
+	分析过程中的每个事件都按照步骤记录下来，后退只是查看记录中更早的一步，不会撤销已经执行的处理函数，
+	在记录中前进到最新的一步之后，才会继续真正的分析。
+	同样的命令也可以用于查看 JSONTracer 保存的跟踪记录，见 View。
+*/
+type Debugger struct {
+	Parser *Parser // 用来查看项集，查看跟踪记录时可以为 nil
+	in     *bufio.Reader
+	out    io.Writer
+
+	breakStates      map[int]bool
+	breakProductions map[int]bool
+	breakTokens      map[string]bool // 终结符或 Token 的字面值
+
+	history [][]TraceEvent // 按照步骤分组的事件，每组的第一个事件是 step 事件
+	cursor  int            // 正在查看的步骤
+	running bool           // continue 之后一直执行到断点
+}
+
+// errDebuggerQuit 表示用户在调试器中停止了分析
+var errDebuggerQuit = fmt.Errorf("调试器：分析被用户停止")
+
+// NewDebugger 创建调试器，从 in 读取命令，向 out 输出
+func NewDebugger(p *Parser, in io.Reader, out io.Writer) *Debugger {
+	return &Debugger{
+		Parser:           p,
+		in:               bufio.NewReader(in),
+		out:              out,
+		breakStates:      make(map[int]bool),
+		breakProductions: make(map[int]bool),
+		breakTokens:      make(map[string]bool),
+	}
+}
+
+// Run 在调试器中分析输入，结束之后恢复原来的 Tracer
+func (d *Debugger) Run(l *lexer.Lexer) (err error) {
+	previous := d.Parser.Tracer
+	d.Parser.Tracer = d
+	defer func() {
+		d.Parser.Tracer = previous
+		if r := recover(); r != nil {
+			if r != errDebuggerQuit {
+				panic(r)
+			}
+			err = errDebuggerQuit
+		}
+	}()
+	fmt.Fprintln(d.out, "输入 help 查看命令")
+	return d.Parser.Parse(l)
+}
+
+// View 在调试器中查看 ReadTrace 读回的跟踪记录，命令与分析时相同
+func (d *Debugger) View(events []TraceEvent) {
+	for _, event := range events {
+		d.record(event)
+	}
+	if len(d.history) == 0 {
+		fmt.Fprintln(d.out, "跟踪记录是空的")
+		return
+	}
+	fmt.Fprintln(d.out, "输入 help 查看命令")
+	d.cursor = 0
+	d.show()
+	d.prompt(false)
+}
+
+// Trace 记录事件，需要暂停时读取命令
+func (d *Debugger) Trace(event TraceEvent) {
+	d.record(event)
+	if d.running && !d.breakHit(event) || !d.running && event.Kind != TRACE_TOKEN && !d.breakHit(event) {
+		return
+	}
+	d.running = false
+	d.cursor = len(d.history) - 1
+	d.show()
+	if d.prompt(true) {
+		panic(errDebuggerQuit)
+	}
+}
+
+// record 把事件加入历史记录
+func (d *Debugger) record(event TraceEvent) {
+	if event.Kind == TRACE_STEP || len(d.history) == 0 {
+		d.history = append(d.history, nil)
+	}
+	d.history[len(d.history)-1] = append(d.history[len(d.history)-1], event)
+}
+
+// breakHit 判断事件是否命中断点，语法错误和接受总是暂停
+func (d *Debugger) breakHit(event TraceEvent) bool {
+	switch event.Kind {
+	case TRACE_TOKEN:
+		return d.breakStates[event.State] || d.breakTokens[string(event.Terminal)] || d.breakTokens[event.Token]
+	case TRACE_REDUCE:
+		return d.breakProductions[event.Production]
+	case TRACE_ERROR, TRACE_ACCEPT:
+		return true
+	}
+	return false
+}
+
+// debuggerHelp 是调试器的命令说明
+const debuggerHelp = `命令：
+  s, step              执行到下一步（查看历史时前进一步）
+  c, continue          继续执行到下一个断点
+  b, break state N     在栈顶状态为 N 时暂停
+  b, break prod N      在使用产生式 N 规约之前暂停
+  b, break token T     在读入终结符或 Token T 时暂停
+  d, delete ...        删除断点，参数与 break 相同，delete all 删除所有断点
+  i, info              列出所有断点
+  st, stack            显示状态栈和符号栈
+  it, items            显示栈顶状态的项集
+  bk, back [N]         后退 N 步（默认 1），查看记录中的那一步
+  f, forward [N]       在记录中前进 N 步（默认 1）
+  q, quit              停止分析
+`
+
+// prompt 读取并执行命令，live 表示正在分析，step 或 continue 时返回 false 继续分析
+// 返回 true 表示用户停止了分析；输入结束时相当于 continue
+func (d *Debugger) prompt(live bool) bool {
+	for {
+		fmt.Fprint(d.out, "(debug) ")
+		line, err := d.in.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(d.out)
+			d.running = true
+			return false
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		last := len(d.history) - 1
+		switch fields[0] {
+		case "s", "step":
+			if d.cursor < last {
+				d.cursor++
+				d.show()
+				continue
+			}
+			if live {
+				return false
+			}
+			fmt.Fprintln(d.out, "已经是记录中的最后一步")
+		case "c", "continue":
+			if live {
+				d.running = true
+				return false
+			}
+			d.continueRecorded()
+		case "b", "break", "d", "delete":
+			d.setBreakpoint(fields[0] == "b" || fields[0] == "break", fields[1:])
+		case "i", "info":
+			d.printBreakpoints()
+		case "st", "stack":
+			d.printStacks()
+		case "it", "items":
+			d.printItems()
+		case "bk", "back":
+			d.cursor = max(0, d.cursor-countArg(fields))
+			d.show()
+		case "f", "forward":
+			d.cursor = min(last, d.cursor+countArg(fields))
+			d.show()
+		case "q", "quit":
+			return true
+		case "h", "help":
+			fmt.Fprint(d.out, debuggerHelp)
+		default:
+			fmt.Fprintf(d.out, "未知命令 %s，输入 help 查看命令\n", fields[0])
+		}
+	}
+}
+
+// countArg 返回 back、forward 的步数参数，省略或无效时为 1
+func countArg(fields []string) int {
+	if len(fields) > 1 {
+		if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 1
+}
+
+// continueRecorded 在跟踪记录中前进到下一个命中断点的步骤
+func (d *Debugger) continueRecorded() {
+	if d.cursor == len(d.history)-1 {
+		fmt.Fprintln(d.out, "已经是记录中的最后一步")
+		return
+	}
+	for d.cursor < len(d.history)-1 {
+		d.cursor++
+		if slices.ContainsFunc(d.history[d.cursor], d.breakHit) {
+			d.show()
+			return
+		}
+	}
+	d.show()
+	fmt.Fprintln(d.out, "已经到达记录的末尾")
+}
+
+// setBreakpoint 添加或删除断点
+func (d *Debugger) setBreakpoint(add bool, args []string) {
+	if !add && len(args) == 1 && args[0] == "all" {
+		clear(d.breakStates)
+		clear(d.breakProductions)
+		clear(d.breakTokens)
+		fmt.Fprintln(d.out, "已删除所有断点")
+		return
+	}
+	if len(args) != 2 {
+		fmt.Fprintln(d.out, "用法：break state N | break prod N | break token T")
+		return
+	}
+	kind, value := args[0], args[1]
+	switch kind {
+	case "state", "prod":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			fmt.Fprintf(d.out, "%s 不是有效的编号\n", value)
+			return
+		}
+		set := d.breakStates
+		if kind == "prod" {
+			set = d.breakProductions
+		}
+		if add {
+			set[n] = true
+		} else {
+			delete(set, n)
+		}
+	case "token":
+		if add {
+			d.breakTokens[value] = true
+		} else {
+			delete(d.breakTokens, value)
+		}
+	default:
+		fmt.Fprintf(d.out, "未知的断点类型 %s，应该是 state、prod 或 token\n", kind)
+		return
+	}
+	d.printBreakpoints()
+}
+
+// printBreakpoints 列出所有断点
+func (d *Debugger) printBreakpoints() {
+	states := sortedStates(d.breakStates)
+	productions := sortedStates(d.breakProductions)
+	tokens := unionKeys(d.breakTokens, nil)
+	fmt.Fprintf(d.out, "断点：状态 %v，产生式 %v，Token %v\n", states, productions, tokens)
+}
+
+// current 返回正在查看的步骤的 step 事件
+func (d *Debugger) current() TraceEvent {
+	return d.history[d.cursor][0]
+}
+
+// show 显示正在查看的步骤
+func (d *Debugger) show() {
+	events := d.history[d.cursor]
+	marker := ""
+	if d.cursor < len(d.history)-1 {
+		marker = "（历史记录）"
+	}
+	fmt.Fprintf(d.out, "---- 第 %d 步%s ----\n", events[0].Step, marker)
+	for _, event := range events {
+		if text := describeEvent(event); text != "" {
+			fmt.Fprintf(d.out, "  %s\n", text)
+		}
+	}
+}
+
+// describeEvent 返回事件的简短描述，step 事件的栈由 stack 命令显示
+func describeEvent(e TraceEvent) string {
+	if e.Predictive {
+		switch e.Kind {
+		case TRACE_TOKEN:
+			return fmt.Sprintf("栈顶符号 %s，当前符号 %s（%s）", e.Head, e.Token, e.Terminal)
+		case TRACE_SHIFT:
+			return fmt.Sprintf("匹配终结符 %s", e.Terminal)
+		case TRACE_ACTION:
+			return fmt.Sprintf("使用产生式 %d 展开：%s → %s", e.Production, e.Head, formatBody(e.Body))
+		}
+	}
+	switch e.Kind {
+	case TRACE_BEGIN:
+		return "开始分析"
+	case TRACE_TOKEN:
+		return fmt.Sprintf("栈顶状态 %d，当前符号 %s（%s）", e.State, e.Token, e.Terminal)
+	case TRACE_ACTION:
+		return fmt.Sprintf("动作 %s", e.Action.Short())
+	case TRACE_SHIFT:
+		return fmt.Sprintf("移入 %s，进入状态 %d", e.Token, e.State)
+	case TRACE_REDUCE:
+		return fmt.Sprintf("使用产生式 %d 规约：%s → %s", e.Production, e.Head, formatBody(e.Body))
+	case TRACE_GOTO:
+		if e.Recovery {
+			return fmt.Sprintf("[错误恢复] 压入同步符号 %s，Goto[%d, %s] = %d", e.Head, e.State, e.Head, e.Target)
+		}
+		return fmt.Sprintf("Goto[%d, %s] = %d", e.State, e.Head, e.Target)
+	case TRACE_ACCEPT:
+		return "接受"
+	case TRACE_ERROR:
+		return "语法错误：" + strings.TrimSpace(e.Message)
+	case TRACE_POP:
+		return fmt.Sprintf("[错误恢复] 弹出状态 %d 和符号 %s", e.State, e.Token)
+	case TRACE_DISCARD:
+		return fmt.Sprintf("[错误恢复] 丢弃符号 %s", e.Token)
+	case TRACE_SEMANTIC:
+		return e.Message
+	}
+	return ""
+}
+
+// printStacks 显示正在查看的步骤开始时的状态栈和符号栈
+func (d *Debugger) printStacks() {
+	step := d.current()
+	fmt.Fprintf(d.out, "状态栈: %v\n符号栈: %v\n", step.StateStack, step.SymbolStack)
+}
+
+// printItems 显示正在查看的步骤中栈顶状态的项集
+func (d *Debugger) printItems() {
+	step := d.current()
+	if d.Parser == nil || len(step.StateStack) == 0 {
+		fmt.Fprintln(d.out, "没有可用的状态集合")
+		return
+	}
+	top := step.StateStack[len(step.StateStack)-1]
+	if top < 0 || top >= len(d.Parser.StateCollection) {
+		fmt.Fprintf(d.out, "状态 %d 不在当前的状态集合中\n", top)
+		return
+	}
+	fmt.Fprintf(d.out, "状态 %d 的项集：\n", top)
+	for _, item := range d.Parser.stateItems(d.Parser.StateCollection[top]) {
+		fmt.Fprintf(d.out, "  [%s, %s]\n", item.Core, strings.Join(item.Lookaheads, "/"))
+	}
+}
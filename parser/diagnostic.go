@@ -0,0 +1,198 @@
+// diagnostic.go
+// 语法错误的诊断信息：出错 Token 的文本和位置、当前状态可以接受的终结符，以及可读的错误描述
+
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// SyntaxError 表示 Parse 遇到的一处语法错误
+type SyntaxError struct {
+	Token       lexer.Token       // 出错的 Token，到达输入末尾时 Type 为 lexer.EOF
+	Terminal    consts.Terminal   // Token 转换后的终结符
+	State       int               // 出错时的栈顶状态
+	Line        int               // 出错位置所在的行，从 1 开始
+	Column      int               // 出错 Token 的第一个字符所在的列，从 1 开始
+	EndColumn   int               // 出错 Token 的最后一个字符所在的列，到达输入末尾时与 Column 相同
+	Expected    []consts.Terminal // 当前状态下 Action 表中有动作的终结符，按照文法中声明的顺序
+	Description string            // 可读的错误描述，例如 在表达式之后期望 ';' 或运算符
+}
+
+// Error 返回错误信息，第一行是位置和描述，第二行列出期望的终结符
+func (e *SyntaxError) Error() string {
+	columns := fmt.Sprint(e.Column)
+	if e.EndColumn > e.Column {
+		columns = fmt.Sprintf("%d-%d", e.Column, e.EndColumn)
+	}
+	return fmt.Sprintf("解析错误：第 %d 行第 %s 列，遇到 %s：%s\n  期望的符号：%s（状态 %d）\n",
+		e.Line, columns, e.found(), e.Description, strings.Join(quoteAll(e.Expected), " "), e.State)
+}
+
+// found 返回出错 Token 的可读形式
+func (e *SyntaxError) found() string {
+	if e.Token.Type == lexer.EOF {
+		return "输入末尾"
+	}
+	return "'" + e.Token.Value + "'"
+}
+
+// quoteTerminal 返回终结符的可读形式，$ 表示输入末尾
+func quoteTerminal(terminal consts.Terminal) string {
+	if terminal == TERMINATE_SYMBOL {
+		return "输入末尾"
+	}
+	return "'" + string(terminal) + "'"
+}
+
+// newSyntaxError 根据当前的分析栈生成语法错误，previous 是最后一个移入的 Token，用来定位输入末尾，symbols 是符号栈
+func (p *Parser) newSyntaxError(state int, token, previous lexer.Token, terminal consts.Terminal, symbols []consts.Symbol) *SyntaxError {
+	e := &SyntaxError{Token: token, Terminal: terminal, State: state, Expected: p.expectedTerminals(state)}
+	if token.Type == lexer.EOF {
+		// 输入末尾没有位置，使用最后一个 Token 之后的位置
+		e.Line, e.Column = previous.Line, previous.Column+1
+		e.EndColumn = e.Column
+	} else {
+		e.Line, e.EndColumn = token.Line, token.Column
+		e.Column = max(1, token.Column-utf8.RuneCountInString(token.Value)+1)
+	}
+	e.Description = p.describeSyntaxError(e, previous, symbols)
+	return e
+}
+
+// expectedTerminals 返回状态 state 下可以接受的终结符，error 终结符只在错误恢复时使用，不列出
+func (p *Parser) expectedTerminals(state int) []consts.Terminal {
+	var expected []consts.Terminal
+	for _, terminal := range p.Grammar.Terminals {
+		if action, ok := p.ActionTable[state][terminal]; ok && action.ActionType != ERROR && terminal != ERROR_TERMINAL && !slices.Contains(expected, terminal) {
+			expected = append(expected, terminal)
+		}
+	}
+	var extra []consts.Terminal
+	for terminal, action := range p.ActionTable[state] {
+		if action.ActionType != ERROR && terminal != ERROR_TERMINAL && !slices.Contains(expected, terminal) {
+			extra = append(extra, terminal)
+		}
+	}
+	slices.Sort(extra)
+	return append(expected, extra...)
+}
+
+var (
+	// OPERATOR_TERMINALS 是二元运算符，描述错误时合称为运算符
+	OPERATOR_TERMINALS = []consts.Terminal{"+", "-", "*", "/", "||", "&&", "==", "!=", "<", "<=", ">", ">="}
+
+	// OPERAND_TERMINALS 是可以开始一个表达式的终结符，全部可以接受时合称为表达式
+	OPERAND_TERMINALS = []consts.Terminal{"id", "num", "real", "true", "false", "(", "!", "-"}
+
+	// STATEMENT_TERMINALS 是可以开始一条语句或声明的终结符，以及结束语句块的 }
+	STATEMENT_TERMINALS = []consts.Terminal{"id", "if", "while", "do", "break", "{", "}", "basic"}
+
+	// EXPRESSION_SYMBOLS 是表达式相关的非终结符，出现在栈顶时说明刚刚读完一个表达式
+	EXPRESSION_SYMBOLS = []consts.Symbol{"bool", "join", "equality", "rel", "expr", "term", "unary", "factor", "loc", "id", "num", "real", "true", "false", ")", "]"}
+)
+
+// describeSyntaxError 生成可读的错误描述
+/*
+	括号不匹配和缺少分号有专门的描述：
+	- 到达输入末尾但还有 { 没有闭合、遇到 } 但栈中没有 {，以及同样情况下的 ( 和 )
+	- 当前状态可以接受 ;，而出错的 Token 是一条新语句的开始或者 }，说明上一条语句缺少 ;
+	其他情况根据栈顶符号描述上下文，并把期望的终结符归纳为表达式和运算符，例如 在表达式之后期望 ';' 或运算符。
+*/
+func (p *Parser) describeSyntaxError(e *SyntaxError, previous lexer.Token, symbols []consts.Symbol) string {
+	expects := func(terminal consts.Terminal) bool { return slices.Contains(e.Expected, terminal) }
+	// unclosed 返回符号栈中还没有闭合的左括号个数，规约之前栈中可能同时有一对括号
+	unclosed := func(open, close consts.Symbol) int {
+		n := 0
+		for _, s := range symbols {
+			switch s {
+			case open:
+				n++
+			case close:
+				n--
+			}
+		}
+		return n
+	}
+	braces, parens := unclosed("{", "}"), unclosed("(", ")")
+
+	switch {
+	case e.Terminal == TERMINATE_SYMBOL && expects("}") && braces > 0:
+		return fmt.Sprintf("缺少 '}'，还有 %d 个 '{' 没有闭合", braces)
+	case e.Terminal == "}" && braces <= 0:
+		return "多余的 '}'，没有与之匹配的 '{'"
+	case e.Terminal == ")" && parens <= 0:
+		return "多余的 ')'，没有与之匹配的 '('"
+	case expects(")") && parens > 0 && slices.Contains([]consts.Terminal{";", "{", "}", TERMINATE_SYMBOL}, e.Terminal):
+		return "缺少 ')'，'(' 没有闭合"
+	case expects(";") && (slices.Contains(STATEMENT_TERMINALS, e.Terminal) || e.Terminal == TERMINATE_SYMBOL) && previous.Value != "":
+		if previous.Line < e.Line {
+			return fmt.Sprintf("第 %d 行的语句缺少 ';'，应该在 '%s' 之后", previous.Line, previous.Value)
+		}
+		return fmt.Sprintf("缺少 ';'，应该在 %s 之前", e.found())
+	}
+
+	// 把期望的终结符归纳为表达式、运算符和其他终结符
+	var groups []string
+	rest := slices.Clone(e.Expected)
+	if !slices.ContainsFunc(OPERAND_TERMINALS, func(t consts.Terminal) bool { return !expects(t) }) {
+		groups = append(groups, "表达式")
+		rest = slices.DeleteFunc(rest, func(t consts.Terminal) bool { return slices.Contains(OPERAND_TERMINALS, t) })
+	}
+	isOperator := func(t consts.Terminal) bool { return slices.Contains(OPERATOR_TERMINALS, t) }
+	if operators := slices.DeleteFunc(slices.Clone(rest), func(t consts.Terminal) bool { return !isOperator(t) }); len(operators) > 2 {
+		groups = append(groups, "运算符")
+		rest = slices.DeleteFunc(rest, isOperator)
+	}
+	parts := append(quoteAll(rest), groups...)
+	if len(parts) == 0 {
+		return "这里不能出现任何符号"
+	}
+	description := spaced("期望", joinAlternatives(parts))
+
+	// 栈顶符号说明出错之前刚刚读完的内容，符号栈中移入的是 Token 的字面值，所以这时使用最后一个 Token 的终结符
+	top := symbols[len(symbols)-1]
+	if !slices.Contains(p.Grammar.NonTerminals(), top) && previous.Value != "" {
+		top = TokenToSymbol(previous)
+	}
+	switch {
+	case slices.Contains(EXPRESSION_SYMBOLS, top):
+		return "在表达式之后" + description
+	case top == "type" || top == "type_array" || top == "basic":
+		return "在类型之后" + description
+	case p.Grammar.IsTerminal(top) && top != consts.Symbol(TERMINATE_SYMBOL) && top != consts.Symbol(ERROR_TERMINAL):
+		return fmt.Sprintf("在 '%s' 之后", top) + description
+	}
+	return description
+}
+
+// quoteAll 返回所有终结符的可读形式
+func quoteAll(terminals []consts.Terminal) []string {
+	quoted := make([]string, len(terminals))
+	for i, terminal := range terminals {
+		quoted[i] = quoteTerminal(terminal)
+	}
+	return quoted
+}
+
+// joinAlternatives 用顿号和“或”连接几种可能，例如 ';'、')' 或运算符
+func joinAlternatives(parts []string) string {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return spaced(spaced(strings.Join(parts[:len(parts)-1], "、"), "或"), parts[len(parts)-1])
+}
+
+// spaced 连接两段文字，只在引号括起来的符号旁边加空格，例如 期望 ';' 或运算符
+func spaced(left, right string) string {
+	if strings.HasSuffix(left, "'") || strings.HasPrefix(right, "'") {
+		return left + " " + right
+	}
+	return left + right
+}
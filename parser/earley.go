@@ -0,0 +1,231 @@
+// earley.go
+// Earley 分析：不需要构建分析表，可以分析任意上下文无关文法
+
+package parser
+
+import (
+	"fmt"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// EarleyItem 表示 Earley 分析中的一个项 [A → α·β, i]
+type EarleyItem struct {
+	Production int // 产生式在 Grammar.Productions 中的编号
+	Position   int // 点的位置，不计算 EPSILON
+	Origin     int // 项开始识别的输入位置
+}
+
+// earleySet 表示 Earley 分析中的一个项集，slice 保证处理顺序，map 用来去重
+type earleySet struct {
+	items []EarleyItem
+	index map[EarleyItem]bool
+}
+
+// add 向项集中加入一个项，返回是否是新加入的
+func (s *earleySet) add(item EarleyItem) bool {
+	if s.index[item] {
+		return false
+	}
+	s.index[item] = true
+	s.items = append(s.items, item)
+	return true
+}
+
+// earleyChart 保存一次 Earley 分析的全部项集
+type earleyChart struct {
+	parser *Parser
+	bodies [][]consts.Symbol // 去掉 EPSILON 后的产生式体
+	sets   []*earleySet      // sets[i] 表示读入前 i 个 Token 之后的项集
+	tokens []lexer.Token
+}
+
+// ParseEarley 使用 Earley 算法分析输入，选择第一种推导后按照 LR 分析的顺序调用产生式的处理函数
+// 返回存在多种推导的节点，这些节点都使用了第一种推导，由调用者决定是否报告
+func (p *Parser) ParseEarley(l *lexer.Lexer) ([]*ForestNode, error) {
+	forest, err := p.EarleyForest(l)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := forest.Disambiguate(nil)
+	if err != nil {
+		return forest.Ambiguities(), err
+	}
+	return forest.Ambiguities(), p.Replay(tree)
+}
+
+// EarleyForest 使用 Earley 算法分析输入，返回包含所有推导的分析森林
+/*
+	Earley 分析直接使用 Grammar.Productions，不需要构建状态集合和分析表。对于每个输入位置 i，反复执行：
+	1. 预测：对于项 [A → α·Bβ, j]，将 B 的所有产生式 [B → ·γ, i] 加入项集
+	2. 完成：对于项 [B → γ·, j]，将项集 j 中所有 [A → α·Bβ, k] 推进为 [A → αB·β, k]
+	3. 扫描：对于项 [A → α·aβ, j]，如果第 i+1 个符号是 a，将 [A → αa·β, j] 加入下一个项集
+	空产生式的完成项与等待它的项在同一个项集中，完成时等待它的项可能还没有加入。
+	这里采用 Aycock 与 Horspool 的做法：预测 B 时，如果 B 可以推导出空串，直接把点移过 B。
+*/
+func (p *Parser) EarleyForest(l *lexer.Lexer) (*Forest, error) {
+	tokens, err := lexAll(l)
+	if err != nil {
+		return nil, err
+	}
+
+	chart := &earleyChart{parser: p, tokens: tokens}
+	for _, prod := range p.Grammar.Productions {
+		chart.bodies = append(chart.bodies, rhs(prod.Body))
+	}
+	nullable := p.Grammar.Nullable()
+
+	// 最后一个 Token 是 EOF，不参与分析
+	n := len(tokens) - 1
+	for i := 0; i <= n; i++ {
+		chart.sets = append(chart.sets, &earleySet{index: make(map[EarleyItem]bool)})
+	}
+	for index, prod := range p.Grammar.Productions {
+		if prod.Head == p.Grammar.Start {
+			chart.sets[0].add(EarleyItem{Production: index, Origin: 0})
+		}
+	}
+
+	for i := 0; i <= n; i++ {
+		set := chart.sets[i]
+		if len(set.items) == 0 {
+			return nil, fmt.Errorf("解析错误：第 %d 个符号 %s 处无法继续分析\n", i, tokens[i-1].Value)
+		}
+		for k := 0; k < len(set.items); k++ {
+			item := set.items[k]
+			body := chart.bodies[item.Production]
+
+			// 完成
+			if item.Position == len(body) {
+				head := p.Grammar.Productions[item.Production].Head
+				for _, waiting := range chart.sets[item.Origin].items {
+					if next := chart.bodies[waiting.Production]; waiting.Position < len(next) && next[waiting.Position] == head {
+						set.add(EarleyItem{Production: waiting.Production, Position: waiting.Position + 1, Origin: waiting.Origin})
+					}
+				}
+				continue
+			}
+
+			sym := body[item.Position]
+			// 扫描
+			if p.Grammar.IsTerminal(sym) {
+				if i < n && consts.Terminal(sym) == TokenToTerminal(tokens[i]) {
+					chart.sets[i+1].add(EarleyItem{Production: item.Production, Position: item.Position + 1, Origin: item.Origin})
+				}
+				continue
+			}
+
+			// 预测
+			for index, prod := range p.Grammar.Productions {
+				if prod.Head == sym {
+					set.add(EarleyItem{Production: index, Origin: i})
+				}
+			}
+			if nullable[sym] {
+				set.add(EarleyItem{Production: item.Production, Position: item.Position + 1, Origin: item.Origin})
+			}
+		}
+	}
+
+	forest := &Forest{Tokens: tokens, nodes: make(map[string]*ForestNode)}
+	if !chart.completed(p.Grammar.Start, 0, n) {
+		if n > 0 && len(chart.sets[n].items) > 0 {
+			return forest, fmt.Errorf("解析错误：输入在符号 %s 之后意外结束\n", tokens[n-1].Value)
+		}
+		return forest, fmt.Errorf("解析错误：输入无法归约为开始符号 %s\n", p.Grammar.Start)
+	}
+	forest.Root = chart.build(forest, p.Grammar.Start, 0, n)
+	return forest, nil
+}
+
+// completed 判断 sym 能否推导出第 start 到第 end 个 Token
+func (c *earleyChart) completed(sym consts.Symbol, start, end int) bool {
+	for index, prod := range c.parser.Grammar.Productions {
+		if prod.Head == sym && c.sets[end].index[EarleyItem{Production: index, Position: len(c.bodies[index]), Origin: start}] {
+			return true
+		}
+	}
+	return false
+}
+
+// build 根据项集构建 sym 推导第 start 到第 end 个 Token 的森林节点
+// 节点在展开之前就登记到森林中，存在 A ⇒+ A 的文法会形成环而不会无限递归
+func (c *earleyChart) build(forest *Forest, sym consts.Symbol, start, end int) *ForestNode {
+	if node, ok := forest.nodes[fmt.Sprintf("%s#%d#%d", sym, start, end)]; ok {
+		return node
+	}
+	node := forest.symbol(sym, start, end)
+	for index, prod := range c.parser.Grammar.Productions {
+		length := len(c.bodies[index])
+		if prod.Head != sym || !c.sets[end].index[EarleyItem{Production: index, Position: length, Origin: start}] {
+			continue
+		}
+		for _, children := range c.split(forest, index, length, start, end) {
+			node.addFamily(index, children)
+		}
+	}
+	return node
+}
+
+// split 返回产生式体的前 position 个符号推导第 start 到第 end 个 Token 的所有划分方式
+// 从右向左划分，只尝试项集中确实存在的位置，避免枚举不可能的划分
+func (c *earleyChart) split(forest *Forest, production, position, start, end int) [][]*ForestNode {
+	if position == 0 {
+		if start == end {
+			return [][]*ForestNode{{}}
+		}
+		return nil
+	}
+	sym := c.bodies[production][position-1]
+	var result [][]*ForestNode
+	for mid := end; mid >= start; mid-- {
+		if !c.sets[mid].index[EarleyItem{Production: production, Position: position - 1, Origin: start}] {
+			continue
+		}
+		var child *ForestNode
+		if c.parser.Grammar.IsTerminal(sym) {
+			if mid+1 != end || TokenToTerminal(c.tokens[mid]) != consts.Terminal(sym) {
+				continue
+			}
+			child = forest.leaf(c.tokens[mid], mid)
+		} else {
+			if !c.completed(sym, mid, end) {
+				continue
+			}
+			child = c.build(forest, sym, mid, end)
+		}
+		for _, prefix := range c.split(forest, production, position-1, start, mid) {
+			result = append(result, append(prefix, child))
+		}
+	}
+	return result
+}
+
+// Replay 按照 LR 分析的顺序重放语法树上的规约，调用产生式的处理函数
+// 语法树的后序遍历就是最右推导的逆序，所以处理函数看到的符号栈与 Parse 中完全相同
+func (p *Parser) Replay(tree *ParseTree) error {
+	if tree == nil {
+		return fmt.Errorf("没有可以重放的语法树")
+	}
+	p.beginParse(0)
+	if err := p.replay(tree); err != nil {
+		return err
+	}
+	p.SymbolTable.ExitScope()
+	return nil
+}
+
+// replay 后序遍历语法树，叶子节点移入符号栈，内部节点执行规约
+func (p *Parser) replay(tree *ParseTree) error {
+	if tree.Token != nil {
+		p.pushSymbol(consts.Symbol(tree.Token.Value), TokenValue(*tree.Token))
+		return nil
+	}
+	for _, child := range tree.Children {
+		if err := p.replay(child); err != nil {
+			return err
+		}
+	}
+	return p.applyReduction(tree.Production)
+}
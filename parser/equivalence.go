@@ -0,0 +1,382 @@
+// equivalence.go
+// 自动机等价性检查：判断两张分析表在状态重新编号之后是否相同，不同时给出最短的区分输入
+
+package parser
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// Automaton 表示一个 LR 自动机，即一对 Action 表和 Goto 表
+// 手工计算的分析表也可以直接写成 ActionTable 和 GotoTable，产生式编号必须与文法一致
+type Automaton struct {
+	Action ActionTable
+	Goto   GotoTable
+	Start  int // 初始状态
+}
+
+// Automaton 返回分析器当前的分析表
+func (p *Parser) Automaton() Automaton {
+	return Automaton{Action: p.ActionTable, Goto: p.GotoTable, Start: 0}
+}
+
+// states 返回自动机中出现的所有状态
+func (a Automaton) states() map[int]bool {
+	states := map[int]bool{a.Start: true}
+	for state, row := range a.Action {
+		states[state] = true
+		for _, entry := range row {
+			if entry.ActionType == SHIFT {
+				states[entry.Number] = true
+			}
+		}
+	}
+	for state, row := range a.Goto {
+		states[state] = true
+		for _, target := range row {
+			states[target] = true
+		}
+	}
+	return states
+}
+
+// lookup 返回状态 state 在终结符 terminal 下的动作，ERROR 和空白表项都返回 false
+func (a Automaton) lookup(state int, terminal consts.Terminal) (ActionEntry, bool) {
+	entry, ok := a.Action[state][terminal]
+	if !ok || entry.ActionType == ERROR {
+		return ActionEntry{}, false
+	}
+	return entry, true
+}
+
+// Equivalence 表示两个自动机的比较结果
+type Equivalence struct {
+	Isomorphic bool        // 两个自动机在状态重新编号之后是否完全相同
+	Mapping    map[int]int // 第一个自动机的状态 → 第二个自动机的状态，只包含已经配对的状态
+	Reason     string      // 不同构时第一处不同的描述
+
+	// 区分输入：两个分析程序读入 Input 之后的动作不同，Actions 是它们在最后一步的动作
+	// 不同构的自动机也可能接受相同的语言并且每一步的动作都相同（例如多出了重复的状态），这时 Input 为空
+	Input   []consts.Terminal
+	Actions [2]string
+
+	Err error // 分析表中规约的产生式不在文法中时不为 nil，这时无法模拟分析过程，也不会建立状态的对应关系
+}
+
+// String 返回比较结果的可读描述
+func (e Equivalence) String() string {
+	if e.Err != nil {
+		return fmt.Sprintf("无法比较两个自动机：%v\n", e.Err)
+	}
+	if e.Isomorphic {
+		return fmt.Sprintf("两个自动机同构，共 %d 个状态\n", len(e.Mapping))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "两个自动机不同构：%s\n", e.Reason)
+	if e.Input == nil {
+		sb.WriteString("在给定长度内没有找到区分输入，两个分析程序对这些输入的动作完全相同\n")
+		return sb.String()
+	}
+	input := make([]string, len(e.Input))
+	for i, terminal := range e.Input {
+		input[i] = string(terminal)
+	}
+	fmt.Fprintf(&sb, "最短区分输入：%s\n读到最后一个符号时，第一张表的动作是 %s，第二张表的动作是 %s\n", strings.Join(input, " "), e.Actions[0], e.Actions[1])
+	return sb.String()
+}
+
+// CompareAutomata 比较两个自动机，maxLength 是搜索区分输入时输入的最大长度（包括结尾的 $）
+/*
+	1. 从两个初始状态开始同时做广度优先搜索，建立状态之间的对应关系：
+	   配对的两个状态在每个终结符下的动作必须相同（移入的目标状态继续配对，规约的产生式编号必须相同），
+	   在每个非终结符下的 Goto 目标也继续配对。一个状态配对了两个不同的状态，或者有状态没有配对，两个自动机就不同构。
+	2. 不同构时，在配对状态上按照输入长度从小到大搜索，让两个分析程序同时分析到达每个配对状态的输入，找出第一个让它们的动作不同的输入。
+	   两个分析程序的状态栈一起保存为配对的栈，规约时两边弹出相同的长度，所以栈的长度始终相同。
+	规约需要知道产生式的头部和长度，所以两张表必须使用同一个文法 g 的产生式编号。
+	手工计算的分析表可能写错产生式编号，所以比较之前先检查两张表中规约的产生式是否都在文法中，有错误时记录在 Err 中。
+*/
+func (g *Grammar) CompareAutomata(a, b Automaton, maxLength int) Equivalence {
+	result := Equivalence{Mapping: make(map[int]int)}
+	for i, automaton := range []Automaton{a, b} {
+		if err := g.checkReductions(automaton); err != nil {
+			result.Err = fmt.Errorf("%s%v", [2]string{"第一张表", "第二张表"}[i], err)
+			return result
+		}
+	}
+	result.Reason = g.isomorphism(a, b, result.Mapping)
+	if result.Reason == "" {
+		result.Isomorphic = true
+		return result
+	}
+	result.Input, result.Actions = g.distinguish(a, b, maxLength)
+	return result
+}
+
+// checkReductions 检查自动机中规约的产生式编号是否都在文法中，按照状态编号和终结符的顺序返回第一处错误
+func (g *Grammar) checkReductions(a Automaton) error {
+	states := make([]int, 0, len(a.Action))
+	for state := range a.Action {
+		states = append(states, state)
+	}
+	slices.Sort(states)
+	for _, state := range states {
+		for _, terminal := range unionKeys(a.Action[state], nil) {
+			entry := a.Action[state][terminal]
+			if entry.ActionType == REDUCE && (entry.Number < 0 || entry.Number >= len(g.Productions)) {
+				return fmt.Errorf("的状态 %d 在终结符 %s 下的动作 %s 规约的产生式不在文法中，文法只有 %d 个产生式", state, terminal, entry.Short(), len(g.Productions))
+			}
+		}
+	}
+	return nil
+}
+
+// isomorphism 建立两个自动机状态之间的对应关系，返回第一处不同的描述，同构时返回空字符串
+func (g *Grammar) isomorphism(a, b Automaton, mapping map[int]int) string {
+	reverse := make(map[int]int)
+	queue := [][2]int{{a.Start, b.Start}}
+	mapping[a.Start], reverse[b.Start] = b.Start, a.Start
+	pair := func(x, y int) string {
+		if mapped, ok := mapping[x]; ok && mapped != y {
+			return fmt.Sprintf("第一张表的状态 %d 同时对应第二张表的状态 %d 和 %d", x, mapped, y)
+		}
+		if mapped, ok := reverse[y]; ok && mapped != x {
+			return fmt.Sprintf("第二张表的状态 %d 同时对应第一张表的状态 %d 和 %d", y, mapped, x)
+		}
+		if _, ok := mapping[x]; !ok {
+			mapping[x], reverse[y] = y, x
+			queue = append(queue, [2]int{x, y})
+		}
+		return ""
+	}
+
+	for len(queue) > 0 {
+		x, y := queue[0][0], queue[0][1]
+		queue = queue[1:]
+		for _, terminal := range unionKeys(a.Action[x], b.Action[y]) {
+			ea, okA := a.lookup(x, terminal)
+			eb, okB := b.lookup(y, terminal)
+			if okA != okB || ea.ActionType != eb.ActionType || ea.ActionType != SHIFT && ea.Number != eb.Number {
+				return fmt.Sprintf("状态 %d 和 %d 在终结符 %s 下的动作分别是 %s 和 %s", x, y, terminal, entryName(ea, okA), entryName(eb, okB))
+			}
+			if ea.ActionType == SHIFT {
+				if reason := pair(ea.Number, eb.Number); reason != "" {
+					return reason
+				}
+			}
+		}
+		for _, sym := range unionKeys(a.Goto[x], b.Goto[y]) {
+			ga, okA := a.Goto[x][sym]
+			gb, okB := b.Goto[y][sym]
+			if okA != okB {
+				return fmt.Sprintf("状态 %d 和 %d 中只有一个有非终结符 %s 的 Goto", x, y, sym)
+			}
+			if reason := pair(ga, gb); reason != "" {
+				return reason
+			}
+		}
+	}
+
+	statesA, statesB := a.states(), b.states()
+	if len(statesA) != len(mapping) || len(statesB) != len(reverse) {
+		return fmt.Sprintf("第一张表有 %d 个状态、第二张表有 %d 个状态，其中只有 %d 个状态可以从初始状态到达并配对", len(statesA), len(statesB), len(mapping))
+	}
+	return ""
+}
+
+// unionKeys 返回两个表行中所有的键，按照字典序排列
+func unionKeys[K ~string, V any](x, y map[K]V) []K {
+	var keys []K
+	for key := range x {
+		keys = append(keys, key)
+	}
+	for key := range y {
+		if _, ok := x[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// entryName 返回动作的简写，空白表项为 error
+func entryName(entry ActionEntry, ok bool) string {
+	if !ok {
+		return ERROR
+	}
+	return entry.Short()
+}
+
+// DISTINGUISH_REDUCTIONS 表示读入一个终结符时最多连续规约的次数，超过时认为分析表存在单位产生式的环
+const DISTINGUISH_REDUCTIONS = 10000
+
+// distinguish 搜索让两个分析程序的动作不同的最短输入，找不到时返回 nil
+/*
+	与 shortestPrefix 相同，在状态转移图上搜索，不过节点是两个自动机配对的状态 (x, y)：
+	两边都移入同一个终结符，或者都有同一个非终结符的 Goto 时，得到下一个配对状态。
+	非终结符的边代表它推导出的最短终结符串（见 shortestYields），边的长度就是这个串的长度，
+	所以按照到达配对状态的输入长度从小到大访问（Dijkstra），每个配对状态只访问一次，搜索量与状态数成正比。
+	访问到 (x, y) 时，对每个终结符 t 让两个分析程序从头分析到达 (x, y) 的输入 w 再加上 t，
+	第一次动作不同的位置之前的部分就是区分输入。输入是实际模拟得到的，所以找到的一定是真正的区分输入；
+	w 中每个非终结符都用最短的串代替，所以它在这种形式的输入中最短。超过 maxLength 的输入不再检查。
+*/
+func (g *Grammar) distinguish(a, b Automaton, maxLength int) ([]consts.Terminal, [2]string) {
+	yields := g.shortestYields()
+	best := map[[2]int][]consts.Terminal{{a.Start, b.Start}: nil}
+	buckets := make([][][2]int, maxLength+1)
+	buckets[0] = [][2]int{{a.Start, b.Start}}
+	done := make(map[[2]int]bool)
+	relax := func(pair [2]int, input []consts.Terminal) {
+		if len(input) >= maxLength {
+			return
+		}
+		if old, ok := best[pair]; ok && len(old) <= len(input) {
+			return
+		}
+		best[pair] = input
+		buckets[len(input)] = append(buckets[len(input)], pair)
+	}
+
+	for length := 0; length < maxLength; length++ {
+		for i := 0; i < len(buckets[length]); i++ {
+			pair := buckets[length][i]
+			if done[pair] || len(best[pair]) != length {
+				continue
+			}
+			done[pair] = true
+			prefix := best[pair]
+			x, y := pair[0], pair[1]
+
+			for _, terminal := range unionKeys(a.Action[x], b.Action[y]) {
+				input := append(slices.Clone(prefix), terminal)
+				if n, actions, differ := g.simulate(a, b, input); differ {
+					return input[:n], actions
+				}
+				if terminal == ERROR_TERMINAL {
+					continue
+				}
+				ea, okA := a.lookup(x, terminal)
+				eb, okB := b.lookup(y, terminal)
+				if okA && okB && ea.ActionType == SHIFT && eb.ActionType == SHIFT {
+					relax([2]int{ea.Number, eb.Number}, input)
+				}
+			}
+			for _, sym := range unionKeys(a.Goto[x], b.Goto[y]) {
+				ga, okA := a.Goto[x][sym]
+				gb, okB := b.Goto[y][sym]
+				yield, ok := yields[sym]
+				if okA && okB && ok {
+					relax([2]int{ga, gb}, append(slices.Clone(prefix), yield...))
+				}
+			}
+		}
+	}
+	return nil, [2]string{}
+}
+
+// simulate 让两个分析程序同时分析 input，动作不同时返回读到第几个终结符时不同以及两边的动作
+func (g *Grammar) simulate(a, b Automaton, input []consts.Terminal) (int, [2]string, bool) {
+	stack := [][2]int{{a.Start, b.Start}}
+	for i, terminal := range input {
+		next, actions, differ := g.step(a, b, stack, terminal)
+		if differ {
+			return i + 1, actions, true
+		}
+		// 两边都报错或者都接受，继续读入也不会有区别
+		if next == nil {
+			return 0, [2]string{}, false
+		}
+		stack = next
+	}
+	return 0, [2]string{}, false
+}
+
+// shortestYields 返回每个非终结符推导出的最短终结符串，长度相同时使用文法中靠前的产生式
+// 含有 error 的产生式不使用，error 不是可以写在输入中的终结符
+func (g *Grammar) shortestYields() map[consts.Symbol][]consts.Terminal {
+	yields := make(map[consts.Symbol][]consts.Terminal)
+	for changed := true; changed; {
+		changed = false
+	productions:
+		for _, prod := range g.Productions {
+			var yield []consts.Terminal
+			for _, sym := range rhs(prod.Body) {
+				switch {
+				case sym == consts.Symbol(ERROR_TERMINAL):
+					continue productions
+				case g.IsTerminal(sym):
+					yield = append(yield, consts.Terminal(sym))
+				default:
+					sub, ok := yields[sym]
+					if !ok {
+						continue productions
+					}
+					yield = append(yield, sub...)
+				}
+			}
+			if old, ok := yields[prod.Head]; !ok || len(yield) < len(old) {
+				yields[prod.Head] = yield
+				changed = true
+			}
+		}
+	}
+	return yields
+}
+
+// step 让两个分析程序读入同一个终结符，先执行所有规约，最后移入
+// 返回移入之后的配对栈，两边都报错或者都接受时返回 nil；动作不同时返回两边的动作
+func (g *Grammar) step(a, b Automaton, stack [][2]int, terminal consts.Terminal) ([][2]int, [2]string, bool) {
+	for i := 0; i < DISTINGUISH_REDUCTIONS; i++ {
+		top := stack[len(stack)-1]
+		ea, okA := a.lookup(top[0], terminal)
+		eb, okB := b.lookup(top[1], terminal)
+		if okA != okB || ea.ActionType != eb.ActionType || ea.ActionType == REDUCE && ea.Number != eb.Number {
+			return nil, [2]string{entryName(ea, okA), entryName(eb, okB)}, true
+		}
+		if !okA || ea.ActionType == ACCEPT {
+			return nil, [2]string{}, false
+		}
+		if ea.ActionType == SHIFT {
+			return append(stack, [2]int{ea.Number, eb.Number}), [2]string{}, false
+		}
+
+		// CompareAutomata 已经检查过产生式编号，这里只防止越界，仍然作为一处不同报告出来，不会被当成两边相同
+		if ea.Number < 0 || ea.Number >= len(g.Productions) {
+			return nil, [2]string{ea.Short(), eb.Short()}, true
+		}
+		prod := g.Productions[ea.Number]
+		length := len(rhs(prod.Body))
+		if length >= len(stack) {
+			return nil, [2]string{}, false
+		}
+		stack = stack[:len(stack)-length]
+		top = stack[len(stack)-1]
+		ga, okA := a.Goto[top[0]][prod.Head]
+		gb, okB := b.Goto[top[1]][prod.Head]
+		if okA != okB {
+			return nil, [2]string{gotoName(prod.Head, ga, okA), gotoName(prod.Head, gb, okB)}, true
+		}
+		if !okA {
+			return nil, [2]string{}, false
+		}
+		stack = append(stack, [2]int{ga, gb})
+	}
+	return nil, [2]string{}, false
+}
+
+// gotoName 返回规约之后 Goto 的描述
+func gotoName(head consts.Symbol, target int, ok bool) string {
+	if !ok {
+		return fmt.Sprintf("规约为 %s 之后没有 Goto", head)
+	}
+	return fmt.Sprintf("规约为 %s 之后转移到 %d", head, target)
+}
+
+// PrintEquivalence 比较分析器与另一个自动机，把结果写入 w
+func (p *Parser) PrintEquivalence(w io.Writer, other Automaton, maxLength int) {
+	fmt.Fprint(w, p.Grammar.CompareAutomata(p.Automaton(), other, maxLength))
+}
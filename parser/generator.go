@@ -0,0 +1,479 @@
+// generator.go
+// 根据文法随机生成句子，用于检查分析表的正确性和生成性能测试的输入
+
+package parser
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// Sentence 表示生成的一个句子
+type Sentence struct {
+	Terminals   []consts.Terminal // 句子中的终结符
+	Lexemes     []string          // 每个终结符对应的字面值
+	Productions []int             // 最左推导中依次使用的产生式编号
+	Depth       int               // 推导树的深度，即从根到叶子最多经过几个产生式
+}
+
+// String 返回句子的源代码形式，字面值之间用空格分隔
+func (s Sentence) String() string {
+	return strings.Join(s.Lexemes, " ")
+}
+
+// Generator 根据文法随机生成长度有限的句子
+/*
+	1. 每个产生式有一个最小推导高度，即使用这个产生式之后至少还要展开多少层才能得到终结符串，
+	   展开时只选择在 MaxDepth 之内能够结束的产生式，高度越小的产生式权重越大，所以生成过程总会结束
+	2. 已经生成的终结符超过 MaxTokens 之后，只选择高度最小的产生式，尽快结束推导；
+	   不到 MinTokens 时反过来，高度越大的产生式权重越大，使句子尽量达到这个长度
+	3. 终结符的字面值默认就是终结符本身，id、num 这样的终结符需要通过 Lexemes 指定生成方法
+	4. Allow 可以根据上下文禁止某些产生式，例如没有声明变量时不使用变量，不在循环中时不使用 break
+*/
+type Generator struct {
+	Grammar   *Grammar
+	Rand      *rand.Rand
+	MaxDepth  int                                             // 推导树的最大深度
+	MaxTokens int                                             // 句子的长度超过这个值之后尽快结束推导
+	MinTokens int                                             // 句子的长度达到这个值之前优先选择高度较大的产生式，为 0 时不限制
+	Lexemes   map[consts.Terminal]func(gen *Generator) string // 终结符字面值的生成方法
+	Allow     func(gen *Generator, production int) bool       // 判断当前上下文中是否可以使用产生式，为 nil 时都可以使用
+	Scopes    map[int]bool                                    // 使用这些产生式展开时进入新的作用域，例如 block
+	Used      map[int]int                                     // 每个产生式被使用的次数
+	height    map[consts.Symbol]int                           // 非终结符的最小推导高度
+	path      []int                                           // 正在展开的产生式，从根到当前节点
+	scopes    [][]string                                      // 作用域栈，每层保存声明的名字
+	names     int                                             // 已经生成的名字个数
+	sentence  Sentence                                        // 正在生成的句子
+	cover     bool                                            // 是否优先选择还没有使用过的产生式
+}
+
+// NewGenerator 创建一个随机句子生成器，相同的 seed 生成相同的句子
+func NewGenerator(g *Grammar, seed int64) *Generator {
+	gen := &Generator{
+		Grammar:   g,
+		Rand:      rand.New(rand.NewSource(seed)),
+		MaxDepth:  20,
+		MaxTokens: 200,
+		Lexemes:   make(map[consts.Terminal]func(gen *Generator) string),
+		Scopes:    make(map[int]bool),
+		Used:      make(map[int]int),
+	}
+	gen.computeHeights()
+	return gen
+}
+
+// computeHeights 计算每个非终结符的最小推导高度，不能推导出终结符串的非终结符没有高度
+func (gen *Generator) computeHeights() {
+	gen.height = make(map[consts.Symbol]int)
+	for changed := true; changed; {
+		changed = false
+		for index, prod := range gen.Grammar.Productions {
+			h, ok := gen.productionHeight(index)
+			if !ok {
+				continue
+			}
+			if old, exists := gen.height[prod.Head]; !exists || h < old {
+				gen.height[prod.Head] = h
+				changed = true
+			}
+		}
+	}
+}
+
+// productionHeight 返回产生式的最小推导高度，产生式体中有不能推导出终结符串的符号时返回 false
+func (gen *Generator) productionHeight(index int) (int, bool) {
+	h := 1
+	for _, sym := range rhs(gen.Grammar.Productions[index].Body) {
+		if gen.Grammar.IsTerminal(sym) {
+			continue
+		}
+		sh, ok := gen.height[sym]
+		if !ok {
+			return 0, false
+		}
+		h = max(h, sh+1)
+	}
+	return h, true
+}
+
+// Generate 从开始符号生成一个句子
+func (gen *Generator) Generate() (Sentence, error) {
+	return gen.GenerateFrom(gen.Grammar.Start)
+}
+
+// GenerateFrom 从指定的符号生成一个句子
+func (gen *Generator) GenerateFrom(sym consts.Symbol) (Sentence, error) {
+	gen.path, gen.scopes, gen.names = nil, [][]string{{}}, 0
+	gen.sentence = Sentence{}
+	if err := gen.expand(sym, 0); err != nil {
+		return Sentence{}, err
+	}
+	return gen.sentence, nil
+}
+
+// Cover 反复生成句子，直到每个可以使用的产生式都至少使用过一次，最多生成 limit 个句子
+// 返回使用了新产生式的句子，以及最后仍然没有使用过的产生式编号
+func (gen *Generator) Cover(limit int) ([]Sentence, []int) {
+	gen.cover = true
+	defer func() { gen.cover = false }()
+
+	var sentences []Sentence
+	for i := 0; i < limit && len(gen.Uncovered()) > 0; i++ {
+		before := len(gen.Uncovered())
+		sentence, err := gen.Generate()
+		if err != nil {
+			continue
+		}
+		if len(gen.Uncovered()) < before {
+			sentences = append(sentences, sentence)
+		}
+	}
+	return sentences, gen.Uncovered()
+}
+
+// Uncovered 返回还没有使用过的产生式编号
+func (gen *Generator) Uncovered() []int {
+	var result []int
+	for index := range gen.Grammar.Productions {
+		if gen.Used[index] == 0 {
+			result = append(result, index)
+		}
+	}
+	return result
+}
+
+// expand 展开符号 sym，depth 是它在推导树中的深度
+func (gen *Generator) expand(sym consts.Symbol, depth int) error {
+	if gen.Grammar.IsTerminal(sym) {
+		terminal := consts.Terminal(sym)
+		lexeme := string(terminal)
+		if lexemeOf, ok := gen.Lexemes[terminal]; ok {
+			lexeme = lexemeOf(gen)
+		}
+		gen.sentence.Terminals = append(gen.sentence.Terminals, terminal)
+		gen.sentence.Lexemes = append(gen.sentence.Lexemes, lexeme)
+		return nil
+	}
+
+	index, err := gen.choose(sym, depth)
+	if err != nil {
+		return err
+	}
+	gen.Used[index]++
+	gen.sentence.Productions = append(gen.sentence.Productions, index)
+	gen.path = append(gen.path, index)
+	gen.sentence.Depth = max(gen.sentence.Depth, len(gen.path))
+	if gen.Scopes[index] {
+		gen.scopes = append(gen.scopes, nil)
+	}
+	for _, child := range rhs(gen.Grammar.Productions[index].Body) {
+		if err := gen.expand(child, depth+1); err != nil {
+			return err
+		}
+	}
+	if gen.Scopes[index] {
+		gen.scopes = gen.scopes[:len(gen.scopes)-1]
+	}
+	gen.path = gen.path[:len(gen.path)-1]
+	return nil
+}
+
+// choose 按照权重为 sym 选择一个产生式
+// 高度每大 1，权重减半；句子的长度还不到 MinTokens 时反过来，高度每小 1，权重减半；覆盖模式下还没有使用过的产生式权重乘以 8
+func (gen *Generator) choose(sym consts.Symbol, depth int) (int, error) {
+	var candidates []int
+	lowest := math.MaxInt
+	for index, prod := range gen.Grammar.Productions {
+		if prod.Head != sym {
+			continue
+		}
+		h, ok := gen.productionHeight(index)
+		if !ok || slices.Contains(prod.Body, consts.Symbol(ERROR_TERMINAL)) {
+			continue
+		}
+		if gen.Allow != nil && !gen.Allow(gen, index) {
+			continue
+		}
+		candidates = append(candidates, index)
+		lowest = min(lowest, h)
+	}
+	if len(candidates) == 0 {
+		return 0, fmt.Errorf("生成错误：当前上下文中 %s 没有可以使用的产生式", sym)
+	}
+
+	// 超出深度或长度限制时只保留高度最小的产生式
+	limit := gen.MaxDepth - depth
+	if len(gen.sentence.Terminals) >= gen.MaxTokens || limit < lowest {
+		limit = lowest
+	}
+
+	// 句子还不够长时以限制之内高度最大的产生式为基准
+	growing := len(gen.sentence.Terminals) < gen.MinTokens && limit > lowest
+	highest := lowest
+	for _, index := range candidates {
+		if h, _ := gen.productionHeight(index); h <= limit {
+			highest = max(highest, h)
+		}
+	}
+
+	var weights []float64
+	total := 0.0
+	for _, index := range candidates {
+		h, _ := gen.productionHeight(index)
+		w := 0.0
+		if h <= limit {
+			w = math.Pow(0.5, float64(h-lowest))
+			if growing {
+				w = math.Pow(0.5, float64(highest-h))
+			}
+			if gen.cover && gen.Used[index] == 0 {
+				w *= 8
+			}
+		}
+		weights = append(weights, w)
+		total += w
+	}
+	r := gen.Rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return candidates[i], nil
+		}
+		r -= w
+	}
+	// 浮点误差时选择最后一个权重不为 0 的产生式
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if weights[i] > 0 {
+			return candidates[i], nil
+		}
+	}
+	return candidates[0], nil
+}
+
+// Current 返回正在展开的产生式编号，在 Lexemes 中调用时就是终结符所在的产生式
+func (gen *Generator) Current() int {
+	if len(gen.path) == 0 {
+		return -1
+	}
+	return gen.path[len(gen.path)-1]
+}
+
+// Inside 判断当前是否在使用某个产生式展开的子树中，例如 break 是否在循环语句中
+func (gen *Generator) Inside(productions ...int) bool {
+	for _, index := range gen.path {
+		for _, production := range productions {
+			if index == production {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Fresh 生成一个新的名字，并在当前作用域中声明它
+func (gen *Generator) Fresh(prefix string) string {
+	name := fmt.Sprintf("%s%d", prefix, gen.names)
+	gen.names++
+	gen.scopes[len(gen.scopes)-1] = append(gen.scopes[len(gen.scopes)-1], name)
+	return name
+}
+
+// Visible 返回当前作用域中可以使用的名字
+func (gen *Generator) Visible() []string {
+	var names []string
+	for _, scope := range gen.scopes {
+		names = append(names, scope...)
+	}
+	return names
+}
+
+// ProgramOptions 表示生成程序的规模，为 0 的字段使用 NewGenerator 的默认值
+type ProgramOptions struct {
+	MaxDepth  int // 推导树的最大深度
+	MinTokens int // 程序的目标长度，达到之前优先选择让程序变长的产生式
+	MaxTokens int // 程序的长度超过这个值之后尽快结束推导
+}
+
+// NewProgramGenerator 返回按照课程文法生成程序的生成器，生成的程序可以通过词法分析，并且满足以下规则：
+// 1. 变量先声明后使用，只使用当前作用域中可见的变量，每个声明使用不同的名字
+// 2. break 只出现在 while 或 do-while 循环中
+// 产生式按照头部和产生式体在 g 中查找，所以 g 可以经过 WithMidActions 等不改变这些产生式的变换
+// 生成程序的长度和深度由 options 控制，例如性能测试需要较长的程序时可以设置 MinTokens
+func NewProgramGenerator(g *Grammar, seed int64, options ProgramOptions) *Generator {
+	gen := NewGenerator(g, seed)
+	if options.MaxDepth > 0 {
+		gen.MaxDepth = options.MaxDepth
+	}
+	if options.MaxTokens > 0 {
+		gen.MaxTokens = options.MaxTokens
+	}
+	gen.MinTokens = min(options.MinTokens, gen.MaxTokens)
+	block := findProduction(g, "block", "{", "decls", "stmts", "}")
+	decl := findProduction(g, "decl", "type", "id", ";")
+	loops := []int{
+		findProduction(g, "stmt", "while", "(", "bool", ")", "stmt"),
+		findProduction(g, "stmt", "do", "stmt", "while", "(", "bool", ")", ";"),
+	}
+	if block >= 0 {
+		gen.Scopes[block] = true
+	}
+	basics := []string{"int", "float", "bool", "byte"}
+	gen.Lexemes["id"] = func(gen *Generator) string {
+		if decl >= 0 && gen.Current() == decl {
+			return gen.Fresh("v")
+		}
+		names := gen.Visible()
+		return names[gen.Rand.Intn(len(names))]
+	}
+	gen.Lexemes["basic"] = func(gen *Generator) string { return basics[gen.Rand.Intn(len(basics))] }
+	gen.Lexemes["num"] = func(gen *Generator) string { return fmt.Sprint(1 + gen.Rand.Intn(9)) }
+	gen.Lexemes["real"] = func(gen *Generator) string { return fmt.Sprintf("%d.%d", gen.Rand.Intn(10), 1+gen.Rand.Intn(9)) }
+
+	gen.Allow = func(gen *Generator, production int) bool {
+		for _, sym := range rhs(g.Productions[production].Body) {
+			if sym == "loc" && len(gen.Visible()) == 0 {
+				return false
+			}
+			if sym == "break" && !gen.Inside(loops...) {
+				return false
+			}
+		}
+		return true
+	}
+	return gen
+}
+
+// findProduction 返回 g 中头部为 head、产生式体为 body 的产生式编号，不存在时返回 -1
+// 比较产生式体时忽略中间动作的标记，例如 while @whileBegin ( bool ) @whileBody stmt 与 while ( bool ) stmt 相同
+func findProduction(g *Grammar, head consts.Symbol, body ...consts.Symbol) int {
+	for index, prod := range g.Productions {
+		if prod.Head != head {
+			continue
+		}
+		symbols := slices.DeleteFunc(rhs(prod.Body), IsMarker)
+		if slices.Equal(symbols, body) {
+			return index
+		}
+	}
+	return -1
+}
+
+// Recognize 只使用分析表判断 Token 序列能否被接受，不调用处理函数，也不打印分析过程
+func (p *Parser) Recognize(tokens []lexer.Token) error {
+	states := []int{0}
+	for next := 0; ; {
+		state := states[len(states)-1]
+		terminal := TokenToTerminal(tokens[next])
+		action, ok := p.ActionTable[state][terminal]
+		if !ok || action.ActionType == ERROR {
+			return fmt.Errorf("解析错误：第 %d 个符号 %s 处无法找到状态 %d 的动作\n", next+1, tokens[next].Value, state)
+		}
+		switch action.ActionType {
+		case SHIFT:
+			states = append(states, action.Number)
+			next++
+		case REDUCE:
+			production := p.Grammar.Productions[action.Number]
+			states = states[:len(states)-len(rhs(production.Body))]
+			top := states[len(states)-1]
+			gotoState, ok := p.GotoTable[top][production.Head]
+			if !ok {
+				return fmt.Errorf("解析错误：无法在状态 %v 中找到产生式 %v 的转移状态\n", top, production)
+			}
+			states = append(states, gotoState)
+		case ACCEPT:
+			return nil
+		default:
+			return fmt.Errorf("解析错误: %s\n", action.ActionType)
+		}
+	}
+}
+
+// SelfCheckReport 表示 SelfCheck 的结果
+type SelfCheckReport struct {
+	Errors []error // 所有失败的句子和原因
+	Sizes  []int   // 每个生成的句子的长度（终结符个数），生成失败的句子不计算在内
+	Depths []int   // 每个生成的句子的推导树深度，与 Sizes 一一对应
+}
+
+// SelfCheck 生成 n 个句子，经过词法分析之后交给分析表识别，返回所有失败的句子和原因，以及句子长度和深度的分布
+// 生成的终结符与词法分析的结果不一致时，说明文法中有词法分析器无法产生的终结符；识别失败时说明分析表有问题
+func (p *Parser) SelfCheck(gen *Generator, n int) SelfCheckReport {
+	var report SelfCheckReport
+	for i := 0; i < n; i++ {
+		sentence, err := gen.Generate()
+		if err != nil {
+			report.Errors = append(report.Errors, err)
+			continue
+		}
+		report.Sizes = append(report.Sizes, len(sentence.Terminals))
+		report.Depths = append(report.Depths, sentence.Depth)
+		tokens, err := lexAll(lexer.NewLexer(strings.NewReader(sentence.String())))
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("词法分析失败：%s\n%v", sentence, err))
+			continue
+		}
+		if mismatch := lexMismatch(sentence, tokens); mismatch != "" {
+			report.Errors = append(report.Errors, fmt.Errorf("词法分析结果与生成的终结符不一致：%s\n%s", sentence, mismatch))
+			continue
+		}
+		if err := p.Recognize(tokens); err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("分析表无法识别生成的句子：%s\n%v", sentence, err))
+		}
+	}
+	return report
+}
+
+// WriteText 以文本形式输出自检结果：失败的句子，以及长度和深度的分布
+func (r SelfCheckReport) WriteText(w io.Writer) {
+	fmt.Fprintln(w, "===============自检报告===============")
+	fmt.Fprintf(w, "生成 %d 个句子，失败 %d 个\n", len(r.Sizes), len(r.Errors))
+	for _, err := range r.Errors {
+		fmt.Fprintln(w, err)
+	}
+	writeDistribution(w, "长度", r.Sizes)
+	writeDistribution(w, "深度", r.Depths)
+}
+
+// writeDistribution 输出一组数的最小值、中位数、最大值，以及按 2 的幂分组的直方图
+func writeDistribution(w io.Writer, name string, values []int) {
+	if len(values) == 0 {
+		return
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	fmt.Fprintf(w, "\n%s：最小 %d，中位数 %d，最大 %d\n", name, sorted[0], sorted[len(sorted)/2], sorted[len(sorted)-1])
+	for low := 0; low <= sorted[len(sorted)-1]; low = max(1, low*2) {
+		high := max(1, low*2) // 区间 [low, high)
+		count := 0
+		for _, v := range sorted {
+			if v >= low && v < high {
+				count++
+			}
+		}
+		if count > 0 {
+			fmt.Fprintf(w, "  %-12s %4d  %s\n", fmt.Sprintf("[%d, %d)", low, high), count, strings.Repeat("#", max(1, count*40/len(sorted))))
+		}
+	}
+}
+
+// lexMismatch 比较生成的终结符与词法分析得到的终结符，返回第一处不一致的描述
+func lexMismatch(sentence Sentence, tokens []lexer.Token) string {
+	// tokens 的最后一个是 EOF
+	if len(tokens)-1 != len(sentence.Terminals) {
+		return fmt.Sprintf("生成了 %d 个终结符，词法分析得到 %d 个", len(sentence.Terminals), len(tokens)-1)
+	}
+	for i, terminal := range sentence.Terminals {
+		if got := TokenToTerminal(tokens[i]); got != terminal {
+			return fmt.Sprintf("第 %d 个符号 %s 应该是 %s，词法分析得到 %s", i+1, sentence.Lexemes[i], terminal, got)
+		}
+	}
+	return ""
+}
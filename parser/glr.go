@@ -0,0 +1,464 @@
+// glr.go
+// 广义 LR（GLR）分析：图结构栈与共享压缩分析森林
+
+package parser
+
+import (
+	"fmt"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// GLRActionTable 表示保留了所有冲突动作的 Action 表
+type GLRActionTable map[int]map[consts.Terminal][]ActionEntry
+
+// ForestNode 表示共享压缩分析森林（SPPF）中的一个符号节点
+// 同一个符号在同一段输入 [Start, End) 上的所有推导共享同一个节点，不同的推导作为不同的 Family 挂在节点下面
+type ForestNode struct {
+	Symbol   consts.Symbol // 文法符号
+	Start    int           // 覆盖的第一个 Token 的下标
+	End      int           // 覆盖的最后一个 Token 的下一个下标
+	Token    *lexer.Token  // 终结符节点对应的 Token
+	Families []*PackedNode // 非终结符节点的所有推导
+}
+
+// PackedNode 表示森林中的一个打包节点，即符号节点的一种推导方式
+type PackedNode struct {
+	Production int           // 使用的产生式编号
+	Children   []*ForestNode // 产生式体中每个符号对应的节点
+}
+
+// Forest 表示一次 GLR 分析得到的共享压缩分析森林
+type Forest struct {
+	Root   *ForestNode   // 开始符号覆盖全部输入的节点
+	Tokens []lexer.Token // 输入的 Token 序列
+	nodes  map[string]*ForestNode
+}
+
+// gssNode 表示图结构栈（GSS）中的一个节点
+type gssNode struct {
+	state int        // LR 状态
+	level int        // 节点所在的输入位置
+	edges []*gssEdge // 指向栈中更深处的边
+}
+
+// gssEdge 表示图结构栈中的一条边，边上记录对应符号的森林节点
+type gssEdge struct {
+	to   *gssNode
+	node *ForestNode
+}
+
+// glrReduction 表示一个待执行的规约
+// 如果 via 不为空，说明这是因为新加入了边 via 而补做的规约，路径的第一条边必须是 via
+type glrReduction struct {
+	node       *gssNode
+	production int
+	via        *gssEdge
+}
+
+// BuildGLRTable 构建保留所有冲突动作的 Action 表
+// 与 buildActionTable 不同，这里不会丢弃任何动作，需要在 BuildStateCollection 之后调用
+func (p *Parser) BuildGLRTable() {
+	table := make(GLRActionTable)
+	for i, state := range p.StateCollection {
+		for _, item := range state.Items {
+			terminal, action, ok := p.itemAction(i, item)
+			if !ok {
+				continue
+			}
+			if table[i] == nil {
+				table[i] = make(map[consts.Terminal][]ActionEntry)
+			}
+			duplicated := false
+			for _, existing := range table[i][terminal] {
+				if existing == action {
+					duplicated = true
+					break
+				}
+			}
+			if !duplicated {
+				table[i][terminal] = append(table[i][terminal], action)
+			}
+		}
+	}
+	p.GLRActionTable = table
+}
+
+// itemAction 返回状态中的一个项对应的动作
+// 点在末尾的项对应规约或接受动作，点在终结符之前的项对应移入动作，其余的项没有动作
+func (p *Parser) itemAction(state int, item LR1Item) (consts.Terminal, ActionEntry, bool) {
+	if isReduceItem(item) {
+		if p.isAugmented(item.Production) {
+			return item.Lookahead, ActionEntry{ActionType: ACCEPT, Number: 0}, item.Lookahead == TERMINATE_SYMBOL
+		}
+		return item.Lookahead, ActionEntry{ActionType: REDUCE, Number: p.productionIndex(item.Production)}, true
+	}
+
+	sym := item.Production.Body[item.Position]
+	if !p.Grammar.IsTerminal(sym) {
+		return "", ActionEntry{}, false
+	}
+	next, ok := p.Transitions[state][sym]
+	return consts.Terminal(sym), ActionEntry{ActionType: SHIFT, Number: next}, ok
+}
+
+// ParseGLR 使用图结构栈对输入进行广义 LR 分析，返回包含所有推导的分析森林
+/*
+	GLR 分析在遇到冲突时不做选择，而是同时保留所有可能的分析栈：
+	1. 所有的栈合并在一个图结构栈中，同一输入位置、同一状态的栈顶只保留一个节点
+	2. 对于当前输入位置的每个栈顶，先执行所有可能的规约（规约可能产生新的栈顶，需要继续规约）
+	3. 然后所有栈顶一起移入当前 Token，没有任何栈顶可以移入时说明输入有语法错误
+	规约得到的森林节点按照 (符号, 起点, 终点) 共享，同一段输入的不同推导会成为同一个节点下的不同 Family。
+	需要先调用 BuildGLRTable 和 BuildTables（使用其中的 Goto 表）。
+*/
+func (p *Parser) ParseGLR(l *lexer.Lexer) (*Forest, error) {
+	if p.GLRActionTable == nil {
+		return nil, fmt.Errorf("GLR 分析表尚未构建")
+	}
+	tokens, err := lexAll(l)
+	if err != nil {
+		return nil, err
+	}
+
+	forest := &Forest{Tokens: tokens, nodes: make(map[string]*ForestNode)}
+	frontier := map[int]*gssNode{0: {state: 0, level: 0}}
+
+	for i := range tokens {
+		terminal := TokenToTerminal(tokens[i])
+
+		// 规约阶段
+		var queue []glrReduction
+		for _, node := range sortedFrontier(frontier) {
+			queue = append(queue, p.glrReductions(node, terminal, nil)...)
+		}
+		for len(queue) > 0 {
+			r := queue[0]
+			queue = queue[1:]
+			queue = append(queue, p.glrReduce(forest, frontier, r, i, terminal)...)
+		}
+
+		// 检查是否接受
+		if terminal == TERMINATE_SYMBOL {
+			for _, node := range sortedFrontier(frontier) {
+				for _, action := range p.GLRActionTable[node.state][terminal] {
+					if action.ActionType == ACCEPT && len(node.edges) > 0 {
+						forest.Root = node.edges[0].node
+						return forest, nil
+					}
+				}
+			}
+			return forest, fmt.Errorf("解析错误：输入结束时没有任何分析栈可以接受\n")
+		}
+
+		// 移入阶段
+		leaf := forest.leaf(tokens[i], i)
+		next := make(map[int]*gssNode)
+		for _, node := range sortedFrontier(frontier) {
+			for _, action := range p.GLRActionTable[node.state][terminal] {
+				if action.ActionType != SHIFT {
+					continue
+				}
+				target, ok := next[action.Number]
+				if !ok {
+					target = &gssNode{state: action.Number, level: i + 1}
+					next[action.Number] = target
+				}
+				target.edges = append(target.edges, &gssEdge{to: node, node: leaf})
+			}
+		}
+		if len(next) == 0 {
+			return forest, fmt.Errorf("解析错误：没有任何分析栈可以移入第 %d 个符号 %s (%s)\n", i+1, tokens[i].Value, terminal)
+		}
+		frontier = next
+	}
+	return forest, fmt.Errorf("解析错误：输入没有以 EOF 结束\n")
+}
+
+// glrReductions 返回栈顶节点在当前展望符下的所有规约
+func (p *Parser) glrReductions(node *gssNode, terminal consts.Terminal, via *gssEdge) []glrReduction {
+	var reductions []glrReduction
+	for _, action := range p.GLRActionTable[node.state][terminal] {
+		if action.ActionType != REDUCE {
+			continue
+		}
+		// 补做规约时，空产生式在节点创建时已经规约过了
+		if via != nil && len(rhs(p.Grammar.Productions[action.Number].Body)) == 0 {
+			continue
+		}
+		reductions = append(reductions, glrReduction{node: node, production: action.Number, via: via})
+	}
+	return reductions
+}
+
+// glrReduce 执行一个规约，返回因此产生的新规约
+func (p *Parser) glrReduce(forest *Forest, frontier map[int]*gssNode, r glrReduction, level int, terminal consts.Terminal) []glrReduction {
+	production := p.Grammar.Productions[r.production]
+	length := len(rhs(production.Body))
+
+	var queue []glrReduction
+	for _, path := range gssPaths(r.node, length, r.via) {
+		bottom := path.bottom
+		target, ok := p.GotoTable[bottom.state][production.Head]
+		if !ok {
+			continue
+		}
+
+		symbol := forest.symbol(production.Head, bottom.level, level)
+		symbol.addFamily(r.production, path.children)
+
+		node, exists := frontier[target]
+		if !exists {
+			node = &gssNode{state: target, level: level}
+			frontier[target] = node
+			node.edges = append(node.edges, &gssEdge{to: bottom, node: symbol})
+			queue = append(queue, p.glrReductions(node, terminal, nil)...)
+			continue
+		}
+
+		// 节点已经存在，如果边也存在，新的推导已经合并到共享的森林节点中
+		linked := false
+		for _, edge := range node.edges {
+			if edge.to == bottom {
+				linked = true
+				break
+			}
+		}
+		if !linked {
+			edge := &gssEdge{to: bottom, node: symbol}
+			node.edges = append(node.edges, edge)
+			queue = append(queue, p.glrReductions(node, terminal, edge)...)
+		}
+	}
+	return queue
+}
+
+// gssPath 表示图结构栈中的一条路径
+type gssPath struct {
+	bottom   *gssNode      // 路径的终点
+	children []*ForestNode // 路径上各条边的森林节点，按照从左到右的顺序
+}
+
+// gssPaths 返回从 node 出发长度为 length 的所有路径，如果 via 不为空，第一条边必须是 via
+func gssPaths(node *gssNode, length int, via *gssEdge) []gssPath {
+	if length == 0 {
+		return []gssPath{{bottom: node}}
+	}
+	var paths []gssPath
+	for _, edge := range node.edges {
+		if via != nil && edge != via {
+			continue
+		}
+		for _, rest := range gssPaths(edge.to, length-1, nil) {
+			children := append(append([]*ForestNode{}, rest.children...), edge.node)
+			paths = append(paths, gssPath{bottom: rest.bottom, children: children})
+		}
+	}
+	return paths
+}
+
+// sortedFrontier 按照状态编号返回栈顶节点，保证分析过程是确定的
+func sortedFrontier(frontier map[int]*gssNode) []*gssNode {
+	max := -1
+	for state := range frontier {
+		if state > max {
+			max = state
+		}
+	}
+	nodes := make([]*gssNode, 0, len(frontier))
+	for state := 0; state <= max; state++ {
+		if node, ok := frontier[state]; ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// leaf 返回 Token 对应的终结符节点
+func (f *Forest) leaf(token lexer.Token, index int) *ForestNode {
+	node := f.symbol(TokenToSymbol(token), index, index+1)
+	node.Token = &f.Tokens[index]
+	return node
+}
+
+// symbol 返回 (符号, 起点, 终点) 对应的共享节点
+func (f *Forest) symbol(sym consts.Symbol, start, end int) *ForestNode {
+	key := fmt.Sprintf("%s#%d#%d", sym, start, end)
+	if node, ok := f.nodes[key]; ok {
+		return node
+	}
+	node := &ForestNode{Symbol: sym, Start: start, End: end}
+	f.nodes[key] = node
+	return node
+}
+
+// addFamily 为符号节点添加一种推导，相同的推导只保留一份
+func (n *ForestNode) addFamily(production int, children []*ForestNode) {
+	for _, family := range n.Families {
+		if family.Production != production || len(family.Children) != len(children) {
+			continue
+		}
+		same := true
+		for i := range children {
+			if family.Children[i] != children[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return
+		}
+	}
+	n.Families = append(n.Families, &PackedNode{Production: production, Children: children})
+}
+
+// Ambiguous 判断森林中是否存在多种推导
+func (f *Forest) Ambiguous() bool {
+	return len(f.Ambiguities()) > 0
+}
+
+// Ambiguities 返回森林中所有存在多种推导的节点，按照深度优先的顺序排列
+func (f *Forest) Ambiguities() []*ForestNode {
+	var nodes []*ForestNode
+	visited := make(map[*ForestNode]bool)
+	var visit func(node *ForestNode)
+	visit = func(node *ForestNode) {
+		if node == nil || visited[node] {
+			return
+		}
+		visited[node] = true
+		if len(node.Families) > 1 {
+			nodes = append(nodes, node)
+		}
+		for _, family := range node.Families {
+			for _, child := range family.Children {
+				visit(child)
+			}
+		}
+	}
+	visit(f.Root)
+	return nodes
+}
+
+// Trees 枚举森林中的语法树，最多返回 limit 棵
+func (f *Forest) Trees(limit int) []*ParseTree {
+	if f.Root == nil {
+		return nil
+	}
+	return f.trees(f.Root, limit, make(map[*ForestNode]bool))
+}
+
+// trees 枚举以 node 为根的语法树，active 用来避免在存在环的文法中无限展开
+func (f *Forest) trees(node *ForestNode, limit int, active map[*ForestNode]bool) []*ParseTree {
+	if node.Token != nil {
+		return []*ParseTree{{Symbol: node.Symbol, Production: -1, Token: node.Token}}
+	}
+	if active[node] {
+		return nil
+	}
+	active[node] = true
+	defer delete(active, node)
+
+	var result []*ParseTree
+	for _, family := range node.Families {
+		// 对产生式体中的每个符号依次做笛卡尔积
+		partial := [][]*ParseTree{{}}
+		for _, child := range family.Children {
+			subtrees := f.trees(child, limit, active)
+			var next [][]*ParseTree
+			for _, prefix := range partial {
+				for _, subtree := range subtrees {
+					if len(next) >= limit {
+						break
+					}
+					next = append(next, append(append([]*ParseTree{}, prefix...), subtree))
+				}
+			}
+			partial = next
+		}
+		for _, children := range partial {
+			if len(result) >= limit {
+				return result
+			}
+			result = append(result, &ParseTree{Symbol: node.Symbol, Production: family.Production, Children: children})
+		}
+	}
+	return result
+}
+
+// Disambiguate 在每个存在多种推导的节点上调用 choose 选择一种推导，返回唯一的一棵语法树
+// choose 返回 node.Families 中的下标，传入 nil 时总是选择第一种推导
+/*
+	存在 A → A 或者可以推导出空串的环时，森林中的节点可能是自己的后代，沿着这样的推导会无限展开。
+	与 Trees 相同，用 active 记录正在展开的节点：选择的推导经过正在展开的节点时，依次尝试这个节点的其他推导，
+	所有推导都经过环时返回错误，指出这个节点。
+*/
+func (f *Forest) Disambiguate(choose func(node *ForestNode) int) (*ParseTree, error) {
+	if f.Root == nil {
+		return nil, nil
+	}
+	if choose == nil {
+		choose = func(*ForestNode) int { return 0 }
+	}
+	active := make(map[*ForestNode]bool)
+	var build func(node *ForestNode) *ParseTree
+	build = func(node *ForestNode) *ParseTree {
+		if node.Token != nil {
+			return &ParseTree{Symbol: node.Symbol, Production: -1, Token: node.Token}
+		}
+		if active[node] {
+			return nil
+		}
+		active[node] = true
+		defer delete(active, node)
+
+		first := 0
+		if len(node.Families) > 1 {
+			first = choose(node)
+		}
+		order := []int{first}
+		for i := range node.Families {
+			if i != first {
+				order = append(order, i)
+			}
+		}
+	families:
+		for _, i := range order {
+			family := node.Families[i]
+			tree := &ParseTree{Symbol: node.Symbol, Production: family.Production}
+			for _, child := range family.Children {
+				subtree := build(child)
+				if subtree == nil {
+					continue families
+				}
+				tree.Children = append(tree.Children, subtree)
+			}
+			return tree
+		}
+		return nil
+	}
+	if tree := build(f.Root); tree != nil {
+		return tree, nil
+	}
+	return nil, fmt.Errorf("分析森林中 %s 在第 %d 到第 %d 个符号之间的所有推导都经过环，无法得到有限的语法树", f.Root.Symbol, f.Root.Start+1, f.Root.End)
+}
+
+// PreferProductions 返回一个消歧函数，优先选择在 order 中排在前面的产生式
+// 例如对于悬挂 else，PreferProductions(11) 会让外层优先使用 if 语句而不是 if-else 语句，即 else 与最近的 if 配对
+func PreferProductions(order ...int) func(node *ForestNode) int {
+	rank := make(map[int]int, len(order))
+	for i, production := range order {
+		rank[production] = i + 1
+	}
+	return func(node *ForestNode) int {
+		best := 0
+		for i, family := range node.Families {
+			r, ok := rank[family.Production]
+			bestRank, bestOk := rank[node.Families[best].Production]
+			if ok && (!bestOk || r < bestRank) {
+				best = i
+			}
+		}
+		return best
+	}
+}
@@ -11,10 +11,50 @@ import (
 
 // NewGrammar 初始化一个文法
 func NewGrammar(rules []Production, terminals []consts.Terminal) *Grammar {
-	return &Grammar{
+	grammar := &Grammar{
 		Productions: rules,
 		Terminals:   terminals,
 	}
+	if len(rules) > 0 {
+		grammar.Start = rules[0].Head
+	}
+	return grammar
+}
+
+// AugmentedProduction 返回文法的增广产生式 S' → S
+// 课程文法的开始符号是 program，返回的就是 ARGUMENTED_PRODUCTION
+// 文法中已经有 S' 时（例如消除左递归之后开始符号恰好有 S'），继续追加 '，保证增广符号不与文法中的符号重名
+func (g *Grammar) AugmentedProduction() Production {
+	head := freshName(g.usedNames(), g.Start)
+	if head == ARGUMENTED_PRODUCTION.Head {
+		return ARGUMENTED_PRODUCTION
+	}
+	return Production{head, []consts.Symbol{g.Start}, genARGUMENTED_PRODUCTION}
+}
+
+// AugmentedProductions 返回开始符号和 entries 中每个入口的增广产生式，第一个总是 AugmentedProduction
+// 每个增广符号都通过 freshName 生成，既不与文法中的符号重名，也不与其他入口的增广符号重名
+// 例如消除左递归之后的文法中已经有 expr'，入口 expr 的增广符号就会在 expr' 之后再追加一个 '
+func (g *Grammar) AugmentedProductions(entries ...consts.Symbol) []Production {
+	start := g.AugmentedProduction()
+	names := g.symbolNames()
+	productions := []Production{start}
+	for _, entry := range uniqueSymbols(entries) {
+		if entry == g.Start {
+			continue
+		}
+		productions = append(productions, Production{freshName(names, entry), []consts.Symbol{entry}, genARGUMENTED_PRODUCTION})
+	}
+	return productions
+}
+
+// isAugmented 判断产生式是否是某个入口的增广产生式
+// 构建状态集合时记录了每个入口的增广符号；没有构建状态集合时（例如只构建 LR(k) 分析表）只有开始符号的增广产生式
+func (p *Parser) isAugmented(prod Production) bool {
+	if p.augmented != nil {
+		return p.augmented[prod.Head]
+	}
+	return prod.Head == p.Grammar.AugmentedProduction().Head
 }
 
 // checkLeftRecursion 检查文法是否存在左递归
@@ -120,7 +160,7 @@ func (p *Parser) InitFirstSet() {
 				}
 			}
 
-			for _, sym := range p.Body {
+			for i, sym := range p.Body {
 				// 特殊情况：如果符号是 EPSILON，直接添加到 head 的 FIRST 集合
 				if sym == EPSILON {
 					if !headFirstSet[EPSILON] {
@@ -146,7 +186,8 @@ func (p *Parser) InitFirstSet() {
 
 					// 如果我们到达了产生式体的末尾，并且所有的符号都包含 EPSILON
 					// 那么我们需要将 EPSILON 添加到产生式头部的 FIRST 集合中
-					if sym == p.Body[len(p.Body)-1] && symFirstSet[EPSILON] {
+					// 只有 EPSILON 是新加入的时候才标记变化，否则对于全部可空的产生式体会无限循环
+					if i == len(p.Body)-1 && !headFirstSet[EPSILON] {
 						headFirstSet[EPSILON] = true
 						changed = true
 					}
@@ -184,11 +225,14 @@ func (p *Parser) InitFollowSet() error {
 	}
 
 	// 初始化增广产生式头部的Follow集
-	if _, exists := followSet[ARGUMENTED_PRODUCTION.Head]; !exists {
-		followSet[ARGUMENTED_PRODUCTION.Head] = make(map[consts.Terminal]bool)
+	augmented := p.Grammar.AugmentedProduction()
+	if _, exists := followSet[augmented.Head]; !exists {
+		followSet[augmented.Head] = make(map[consts.Terminal]bool)
 	}
 	// 将$加入到开始符号的Follow集中
-	followSet[ARGUMENTED_PRODUCTION.Head][TERMINATE_SYMBOL] = true
+	// 增广产生式不在 Productions 中，所以原开始符号也需要直接加入$
+	followSet[augmented.Head][TERMINATE_SYMBOL] = true
+	followSet[p.Grammar.Start][TERMINATE_SYMBOL] = true
 
 	// 迭代直到没有变化为止
 	changed := true
@@ -210,7 +254,7 @@ func (p *Parser) InitFollowSet() error {
 				// 如果是产生式的最后一个符号或者下一个符号的First集包含EPSILON
 				// 就将产生式头部的Follow集加入到当前符号的Follow集中
 				// 将First(β) - {ε} 加入Follow(B)
-				if i+1 == len(prod.Body) || p.containsEpsilon(p.computeFirstSetOfSequence(prod.Body[i+1:])) {
+				if i+1 == len(prod.Body) || p.nullableSequence(prod.Body[i+1:]) {
 					for terminal := range followSet[prod.Head] {
 						if !followSetSym[terminal] {
 							followSetSym[terminal] = true
@@ -219,10 +263,10 @@ func (p *Parser) InitFollowSet() error {
 					}
 				}
 
-				// 将后续符号的First集（除了EPSILON）加入到当前符号的Follow集中
+				// 将后续符号串的First集（除了EPSILON）加入到当前符号的Follow集中
+				// 后续符号可空时还需要继续看再后面的符号，所以这里使用整个符号串的First集
 				if i < len(prod.Body)-1 {
-					nextSym := prod.Body[i+1]
-					nextFirstSet := p.computeFirstSetOfSymbol(nextSym)
+					nextFirstSet := p.computeFirstSetOfSequence(prod.Body[i+1:])
 					for terminal := range nextFirstSet {
 						if terminal != EPSILON && !followSetSym[terminal] {
 							followSetSym[terminal] = true
@@ -266,6 +310,16 @@ func (p *Parser) computeFirstSetOfSequence(sequence []consts.Symbol) map[consts.
 	return firstSetSeq
 }
 
+// nullableSequence 检查符号串是否可以推导出空串
+func (p *Parser) nullableSequence(sequence []consts.Symbol) bool {
+	for _, sym := range sequence {
+		if sym != EPSILON && !p.containsEpsilon(p.FirstSet[sym]) {
+			return false
+		}
+	}
+	return true
+}
+
 // containsEpsilon 检查First集合是否包含EPSILON
 func (p *Parser) containsEpsilon(firstSet map[consts.Terminal]bool) bool {
 	return firstSet[EPSILON]
@@ -0,0 +1,252 @@
+// graph.go
+// LR 自动机的导出：Graphviz DOT 图和可以交互查看的 HTML 页面
+
+package parser
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// AutomatonView 表示导出用的 LR 自动机，状态和转移都已经排好序，每次导出的结果相同
+type AutomatonView struct {
+	States []StateView `json:"states"`
+}
+
+// StateView 表示自动机中的一个状态
+type StateView struct {
+	Index       int              `json:"index"`
+	Items       []ItemView       `json:"items"`       // 项的核心相同的 LR(1) 项合并为一行
+	Transitions []TransitionView `json:"transitions"` // 移入边和 Goto 边
+	Incoming    []int            `json:"incoming"`    // 能够转移到这个状态的状态
+	Conflicts   []string         `json:"conflicts"`   // 这个状态中的冲突，没有冲突时为空
+}
+
+// ItemView 表示核心相同的一组 LR(1) 项，例如 stmt → if ( bool ) stmt • 和它的所有展望符
+type ItemView struct {
+	Core       string   `json:"core"`
+	Lookaheads []string `json:"lookaheads"`
+	Kernel     bool     `json:"kernel"` // 内核项：点不在开头，或者是增广产生式的项
+}
+
+// TransitionView 表示一条转移边，Terminal 为 true 时是移入边，否则是 Goto 边
+type TransitionView struct {
+	Symbol   string `json:"symbol"`
+	Target   int    `json:"target"`
+	Terminal bool   `json:"terminal"`
+}
+
+// AutomatonView 根据状态集合、状态转移和冲突记录生成导出用的自动机，需要先调用 BuildStateCollection 和 BuildTables
+func (p *Parser) AutomatonView() AutomatonView {
+	var view AutomatonView
+	incoming := make(map[int][]int)
+	for state, row := range p.Transitions {
+		for _, target := range row {
+			if !slices.Contains(incoming[target], state) {
+				incoming[target] = append(incoming[target], state)
+			}
+		}
+	}
+
+	for _, state := range p.StateCollection {
+		sv := StateView{Index: state.Index, Items: p.stateItems(state), Incoming: incoming[state.Index]}
+		slices.Sort(sv.Incoming)
+
+		symbols := make([]consts.Symbol, 0, len(p.Transitions[state.Index]))
+		for sym := range p.Transitions[state.Index] {
+			symbols = append(symbols, sym)
+		}
+		slices.Sort(symbols)
+		for _, sym := range symbols {
+			sv.Transitions = append(sv.Transitions, TransitionView{Symbol: string(sym), Target: p.Transitions[state.Index][sym], Terminal: p.Grammar.IsTerminal(sym)})
+		}
+
+		for _, c := range p.Conflicts {
+			if c.State == state.Index {
+				sv.Conflicts = append(sv.Conflicts, fmt.Sprintf("%s 冲突，展望符 %s：%s / %s", c.Kind, c.Lookahead, c.Chosen.Short(), c.Rejected.Short()))
+			}
+		}
+		view.States = append(view.States, sv)
+	}
+	return view
+}
+
+// stateItems 把状态中核心相同的 LR(1) 项合并为一行，展望符按照项在状态中第一次出现的顺序合并，内核项在前
+func (p *Parser) stateItems(state *State) []ItemView {
+	var items []ItemView
+	positions := make(map[string]int)
+	for _, item := range state.Items {
+		core := strings.Join(strings.Fields(DerivationFrame{Production: item.Production, Position: item.Position}.format(true)), " ")
+		index, ok := positions[core]
+		if !ok {
+			index = len(items)
+			positions[core] = index
+			items = append(items, ItemView{Core: core, Kernel: item.Position > 0 || p.isAugmented(item.Production)})
+		}
+		items[index].Lookaheads = append(items[index].Lookaheads, string(item.Lookahead))
+	}
+	for i := range items {
+		slices.Sort(items[i].Lookaheads)
+	}
+	slices.SortStableFunc(items, func(a, b ItemView) int {
+		if a.Kernel == b.Kernel {
+			return 0
+		}
+		if a.Kernel {
+			return -1
+		}
+		return 1
+	})
+	return items
+}
+
+// WriteDOT 以 Graphviz DOT 格式输出自动机，可以用 dot -Tsvg 生成图片
+// 节点的标签是状态的项集，移入边为实线、Goto 边为虚线，有冲突的状态填充为红色
+func (v AutomatonView) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph LR1 {\n")
+	sb.WriteString("\trankdir=LR;\n")
+	sb.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	for _, state := range v.States {
+		var label strings.Builder
+		fmt.Fprintf(&label, "I%d\\l", state.Index)
+		for _, item := range state.Items {
+			fmt.Fprintf(&label, "%s , %s\\l", dotEscape(item.Core), dotEscape(strings.Join(item.Lookaheads, "/")))
+		}
+		for _, conflict := range state.Conflicts {
+			fmt.Fprintf(&label, "%s\\l", dotEscape(conflict))
+		}
+		style := ""
+		if len(state.Conflicts) > 0 {
+			style = ", style=filled, fillcolor=\"#ffcccc\""
+		}
+		fmt.Fprintf(&sb, "\t%d [label=\"%s\"%s];\n", state.Index, label.String(), style)
+	}
+	for _, state := range v.States {
+		for _, t := range state.Transitions {
+			style := ""
+			if !t.Terminal {
+				style = ", style=dashed"
+			}
+			fmt.Fprintf(&sb, "\t%d -> %d [label=\"%s\"%s];\n", state.Index, t.Target, dotEscape(t.Symbol), style)
+		}
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// dotEscape 转义 DOT 字符串中的引号和反斜杠
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// automatonHTML 是 HTML 查看器的模板，自动机的数据以 JSON 的形式嵌入页面，不依赖外部文件
+var automatonHTML = template.Must(template.New("automaton").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>LR(1) 自动机</title>
+<style>
+body { font-family: sans-serif; display: flex; margin: 0; height: 100vh; }
+#list { width: 16em; overflow-y: auto; border-right: 1px solid #ccc; padding: 8px; }
+#list input { width: 100%; box-sizing: border-box; margin-bottom: 8px; }
+#list a { display: block; padding: 2px 4px; cursor: pointer; }
+#list a.conflict { background: #fdd; }
+#list a.current { font-weight: bold; background: #def; }
+#detail { flex: 1; overflow-y: auto; padding: 8px 16px; }
+.item { font-family: monospace; padding: 1px 0; }
+.item.closure { color: #666; }
+.item[title]:hover { background: #ffc; }
+.conflict-note { color: #c00; }
+.edge { cursor: pointer; color: #06c; margin-right: 1em; }
+.edge.goto { font-style: italic; }
+</style>
+</head>
+<body>
+<div id="list">
+<input id="search" placeholder="搜索状态编号或项，例如 stmt → if">
+<div id="states"></div>
+</div>
+<div id="detail"></div>
+<script>
+const automaton = {{.}};
+const list = document.getElementById("states");
+const detail = document.getElementById("detail");
+const search = document.getElementById("search");
+let current = 0;
+
+function text(tag, content, cls) {
+	const el = document.createElement(tag);
+	el.textContent = content;
+	if (cls) el.className = cls;
+	return el;
+}
+
+function matches(state, query) {
+	if (query === "" || String(state.index) === query) return true;
+	return state.items.some(item => item.core.includes(query));
+}
+
+function renderList() {
+	const query = search.value.trim();
+	list.replaceChildren();
+	for (const state of automaton.states) {
+		if (!matches(state, query)) continue;
+		const link = text("a", "I" + state.index + (state.conflicts ? "  ⚠" : ""));
+		if (state.conflicts) link.classList.add("conflict");
+		if (state.index === current) link.classList.add("current");
+		link.onclick = () => show(state.index);
+		list.appendChild(link);
+	}
+}
+
+function edge(label, target, cls) {
+	const el = text("span", label + " → I" + target, "edge " + cls);
+	el.onclick = () => show(target);
+	return el;
+}
+
+function show(index) {
+	current = index;
+	const state = automaton.states[index];
+	detail.replaceChildren(text("h2", "状态 I" + index));
+	for (const conflict of state.conflicts || []) detail.appendChild(text("div", conflict, "conflict-note"));
+	detail.appendChild(text("h3", "项集（鼠标悬停查看展望符）"));
+	for (const item of state.items) {
+		const el = text("div", "[" + item.core + "]", "item" + (item.kernel ? "" : " closure"));
+		el.title = "展望符：" + item.lookaheads.join(" ");
+		detail.appendChild(el);
+	}
+	detail.appendChild(text("h3", "转移"));
+	const out = document.createElement("div");
+	for (const t of state.transitions || []) out.appendChild(edge(t.symbol, t.target, t.terminal ? "shift" : "goto"));
+	detail.appendChild(out);
+	detail.appendChild(text("h3", "来源"));
+	const from = document.createElement("div");
+	for (const source of state.incoming || []) {
+		const t = automaton.states[source].transitions.find(t => t.target === index);
+		const el = text("span", "I" + source + " —" + t.symbol + "→", "edge");
+		el.onclick = () => show(source);
+		from.appendChild(el);
+	}
+	detail.appendChild(from);
+	renderList();
+}
+
+search.oninput = renderList;
+show(0);
+</script>
+</body>
+</html>
+`))
+
+// WriteHTML 输出可以交互查看的 HTML 页面：左侧可以搜索状态，右侧显示项集、转移和来源，点击转移跳到对应的状态
+func (v AutomatonView) WriteHTML(w io.Writer) error {
+	return automatonHTML.Execute(w, v)
+}
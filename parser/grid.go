@@ -0,0 +1,196 @@
+// grid.go
+// 分析表的表格形式：状态为行，终结符和非终结符为列，可以输出 Markdown、CSV 和 HTML
+
+package parser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// ParseTableGrid 表示教材形式的 LR 分析表
+type ParseTableGrid struct {
+	Terminals    []consts.Terminal // Action 部分的列
+	NonTerminals []consts.Symbol   // Goto 部分的列
+	Rows         []ParseTableRow
+}
+
+// ParseTableRow 表示分析表中的一行，空字符串表示空白表项
+type ParseTableRow struct {
+	State   int
+	Actions []string // 与 Terminals 一一对应，例如 s5、r12、acc
+	Gotos   []string // 与 NonTerminals 一一对应
+}
+
+// ParseTableGrid 把 Action 表和 Goto 表整理成表格
+/*
+	列的顺序固定：终结符按照它们在文法中声明的顺序，然后是非终结符按照它们第一次作为产生式头部出现的顺序，
+	分析表中出现但没有声明的符号排在最后并按字典序排列。在所有状态中都是空白的列不会输出（例如课程文法中的 int、float，
+	它们在词法分析时已经转换为 basic）。行按照状态编号排列，所以同一个分析表每次输出的结果完全相同，可以直接比较差异。
+*/
+func (p *Parser) ParseTableGrid() ParseTableGrid {
+	usedTerminals := make(map[consts.Terminal]bool)
+	for _, row := range p.ActionTable {
+		for terminal := range row {
+			usedTerminals[terminal] = true
+		}
+	}
+	usedSymbols := make(map[consts.Symbol]bool)
+	for _, row := range p.GotoTable {
+		for sym := range row {
+			usedSymbols[sym] = true
+		}
+	}
+
+	var grid ParseTableGrid
+	for _, terminal := range p.Grammar.Terminals {
+		if usedTerminals[terminal] && !slices.Contains(grid.Terminals, terminal) {
+			grid.Terminals = append(grid.Terminals, terminal)
+		}
+	}
+	for _, terminal := range unionKeys(usedTerminals, nil) {
+		if !slices.Contains(grid.Terminals, terminal) {
+			grid.Terminals = append(grid.Terminals, terminal)
+		}
+	}
+	for _, sym := range p.Grammar.NonTerminals() {
+		if usedSymbols[sym] && !slices.Contains(grid.NonTerminals, sym) {
+			grid.NonTerminals = append(grid.NonTerminals, sym)
+		}
+	}
+	for _, sym := range unionKeys(usedSymbols, nil) {
+		if !slices.Contains(grid.NonTerminals, sym) {
+			grid.NonTerminals = append(grid.NonTerminals, sym)
+		}
+	}
+
+	states := make(map[int]bool)
+	for state := range p.ActionTable {
+		states[state] = true
+	}
+	for state := range p.GotoTable {
+		states[state] = true
+	}
+	for _, state := range sortedStates(states) {
+		row := ParseTableRow{State: state, Actions: make([]string, len(grid.Terminals)), Gotos: make([]string, len(grid.NonTerminals))}
+		for i, terminal := range grid.Terminals {
+			if entry, ok := p.ActionTable[state][terminal]; ok {
+				row.Actions[i] = entry.Short()
+			}
+		}
+		for i, sym := range grid.NonTerminals {
+			if target, ok := p.GotoTable[state][sym]; ok {
+				row.Gotos[i] = strconv.Itoa(target)
+			}
+		}
+		grid.Rows = append(grid.Rows, row)
+	}
+	return grid
+}
+
+// header 返回表头，第一列是状态
+func (g ParseTableGrid) header() []string {
+	header := []string{"状态"}
+	for _, terminal := range g.Terminals {
+		header = append(header, string(terminal))
+	}
+	for _, sym := range g.NonTerminals {
+		header = append(header, string(sym))
+	}
+	return header
+}
+
+// cells 返回一行中的所有单元格，第一列是状态编号
+func (r ParseTableRow) cells() []string {
+	return append(append([]string{strconv.Itoa(r.State)}, r.Actions...), r.Gotos...)
+}
+
+// WriteMarkdown 以 Markdown 表格的形式输出分析表，符号中的 | 会被转义
+func (g ParseTableGrid) WriteMarkdown(w io.Writer) error {
+	escape := strings.NewReplacer("|", `\|`)
+	line := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = escape.Replace(cell)
+		}
+		return "| " + strings.Join(escaped, " | ") + " |\n"
+	}
+
+	var sb strings.Builder
+	header := g.header()
+	sb.WriteString(line(header))
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	sb.WriteString(line(separator))
+	for _, row := range g.Rows {
+		sb.WriteString(line(row.cells()))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteCSV 以 CSV 的形式输出分析表
+func (g ParseTableGrid) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(g.header()); err != nil {
+		return err
+	}
+	for _, row := range g.Rows {
+		if err := writer.Write(row.cells()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// gridHTML 是 HTML 分析表的模板，表头分为 ACTION 和 GOTO 两部分
+var gridHTML = template.Must(template.New("grid").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>LR(1) 分析表</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; text-align: center; font-family: monospace; }
+th { background: #eee; }
+.goto { background: #f6f6ff; }
+</style>
+</head>
+<body>
+<table>
+<tr><th rowspan="2">状态</th><th colspan="{{len .Terminals}}">ACTION</th><th colspan="{{len .NonTerminals}}">GOTO</th></tr>
+<tr>{{range .Terminals}}<th>{{.}}</th>{{end}}{{range .NonTerminals}}<th class="goto">{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><th>{{.State}}</th>{{range .Actions}}<td>{{.}}</td>{{end}}{{range .Gotos}}<td class="goto">{{.}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTML 以 HTML 表格的形式输出分析表
+func (g ParseTableGrid) WriteHTML(w io.Writer) error {
+	return gridHTML.Execute(w, g)
+}
+
+// WriterFor 根据文件的扩展名（.md、.csv、.html）选择输出格式
+func (g ParseTableGrid) WriterFor(name string) (func(io.Writer) error, error) {
+	switch {
+	case strings.HasSuffix(name, ".md"):
+		return g.WriteMarkdown, nil
+	case strings.HasSuffix(name, ".csv"):
+		return g.WriteCSV, nil
+	case strings.HasSuffix(name, ".html"):
+		return g.WriteHTML, nil
+	}
+	return nil, fmt.Errorf("无法根据文件名 %s 确定分析表的格式，扩展名应该是 .md、.csv 或 .html", name)
+}
@@ -5,8 +5,12 @@ import "fmt"
 // LabelCounter 用于生成新的唯一标签
 var LabelCounter int = 0
 
-// BreakLabelStack 用于维护 break 标签的栈
-var BreakLabelStack []string = []string{}
+// pendingLabel 表示外层循环的 break 标签
+// depth 是进入循环的中间动作的标记在符号栈中的位置，错误恢复弹出这个标记时标签也随之作废
+type pendingLabel struct {
+	label string
+	depth int
+}
 
 // NewLabel 生成一个新的唯一标签
 func (p *Parser) NewLabel() string {
@@ -17,30 +21,50 @@ func (p *Parser) NewLabel() string {
 
 // EmitLabel 将标签输出到三地址码中
 func (p *Parser) EmitLabel(label string) {
-	p.ThreeAddress = append(p.ThreeAddress, label+":")
+	p.ThreeAddress = append(p.ThreeAddress, label+":\n")
 }
 
-// GetBreakLabel 返回最内层循环或 switch 语句的结束标签
-func (p *Parser) GetBreakLabel() string {
-	if len(BreakLabelStack) == 0 {
-		panic("发生逻辑错误: BreakLabelStack 为空，但 GetBreakLabel 被调用。")
+// GetBreakLabel 返回最内层循环或 switch 语句的结束标签，不在循环中时返回错误
+func (p *Parser) GetBreakLabel() (string, error) {
+	if len(p.breakLabels) == 0 {
+		return "", fmt.Errorf("break 不在循环中")
 	}
 	// 返回栈顶元素
-	return BreakLabelStack[len(BreakLabelStack)-1]
+	return p.breakLabels[len(p.breakLabels)-1].label, nil
 }
 
 // EnterLoop 用于在进入循环或 switch 时调用，将新的 break 标签压入栈
 func (p *Parser) EnterLoop() {
 	label := p.NewLabel()
-	BreakLabelStack = append(BreakLabelStack, label)
+	p.breakLabels = append(p.breakLabels, pendingLabel{label, len(p.TokenStack)})
 }
 
 // ExitLoop 用于在离开循环或 switch 时调用，将 break 标签弹出栈
-func (p *Parser) ExitLoop() {
-	if len(BreakLabelStack) == 0 {
-		panic("发生逻辑错误: BreakLabelStack 为空，但 ExitLoop 被调用。")
+func (p *Parser) ExitLoop() error {
+	if len(p.breakLabels) == 0 {
+		return fmt.Errorf("发生逻辑错误: break 标签栈为空，但 ExitLoop 被调用。")
+	}
+	p.breakLabels = p.breakLabels[:len(p.breakLabels)-1]
+	return nil
+}
+
+// dropLabels 丢弃标记已经不在符号栈中的循环的 break 标签
+// 正常规约时循环语句的处理函数会调用 ExitLoop；错误恢复直接弹出符号栈时，这些标签需要在这里丢弃，否则外层的 break 会跳到这里
+// 中间动作的其他标签保存在标记的值中，随标记一起弹出，不需要处理
+func (p *Parser) dropLabels() {
+	for len(p.breakLabels) > 0 && p.breakLabels[len(p.breakLabels)-1].depth >= len(p.TokenStack) {
+		p.breakLabels = p.breakLabels[:len(p.breakLabels)-1]
+	}
+}
+
+// EmitJump 输出跳转指令，例如 goto L1、ifFalse t1 goto L2
+// op: goto、if 或 ifFalse，条件跳转时 args 为条件和标签，无条件跳转时 args 只有标签
+func (p *Parser) EmitJump(op string, args ...string) {
+	if op == "goto" {
+		p.ThreeAddress = append(p.ThreeAddress, fmt.Sprintf("goto %s\n", args[0]))
+		return
 	}
-	BreakLabelStack = BreakLabelStack[:len(BreakLabelStack)-1]
+	p.ThreeAddress = append(p.ThreeAddress, fmt.Sprintf("%s %s goto %s\n", op, args[0], args[1]))
 }
 
 // Emit 输出三地址代码
@@ -50,6 +74,9 @@ func (p *Parser) Emit(result string, opcode string, operands ...string) {
 	// 输出三地址代码
 	if opcode == "" {
 		op = fmt.Sprintf("%s = %s\n", result, operands[0])
+	} else if len(operands) == 1 {
+		// 一元运算，例如 t1 = - a;
+		op = fmt.Sprintf("%s = %s %s;\n", result, opcode, operands[0])
 	} else {
 		op = fmt.Sprintf("%s = %s %s %s;\n", result, operands[0], opcode, operands[1])
 	}
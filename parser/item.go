@@ -6,6 +6,7 @@ package parser
 import (
 	"fmt"
 	"slices"
+	"strings"
 
 	"github.com/ozline/CoursePractice-GoCompiler/consts"
 )
@@ -24,15 +25,35 @@ func (p *Parser) PrintStateCollection() {
 // buildItems 构建LR(1)项集合
 // 该函数是构建状态集合的基础，它会计算文法的闭包和转移，以构建状态集合。
 func (p *Parser) BuildStateCollection() {
-	// 初始化一个产生式，产生式的头部是文法的开始符号，体部是文法的第一个产生式体，点的位置为0，展望符为终止符
-	startProd := ARGUMENTED_PRODUCTION
-	// 产生式的点位置为0，展望符为终止符
-	startItem := LR1Item{Production: startProd, Position: 0, Lookahead: TERMINATE_SYMBOL}
-
-	initialState := &State{Items: p.closure(LR1Items{startItem}), Index: 0}
+	p.BuildStateCollectionFor()
+}
 
-	states := StateCollection{initialState}
-	toProcess := []*State{initialState}
+// BuildStateCollectionFor 以文法的开始符号和 entries 中的每个非终结符为入口构建状态集合
+// 每个入口都有自己的增广产生式和初始状态，开始符号的初始状态总是 0，其他入口的初始状态记录在 EntryStates 中
+// 之后可以使用 ParseAs 只分析一个表达式、一条语句或一个声明
+func (p *Parser) BuildStateCollectionFor(entries ...consts.Symbol) {
+	states := StateCollection{}
+	toProcess := []*State{}
+	transitions := make(Transitions)
+	// 以状态的规范形式作为键，避免每次都与所有状态逐项比较
+	indexes := make(map[string]int)
+	entryStates := make(map[consts.Symbol]int)
+
+	augmented := make(map[consts.Symbol]bool)
+
+	for _, startProd := range p.Grammar.AugmentedProductions(entries...) {
+		// 增广产生式的头部是入口的增广符号，体部是入口符号
+		entry := startProd.Body[0]
+		augmented[startProd.Head] = true
+		// 产生式的点位置为0，展望符为终止符
+		startItem := LR1Item{Production: startProd, Position: 0, Lookahead: TERMINATE_SYMBOL}
+
+		initialState := &State{Items: p.closure(LR1Items{startItem}), Index: len(states)}
+		indexes[stateKey(initialState.Items)] = initialState.Index
+		entryStates[entry] = initialState.Index
+		states = append(states, initialState)
+		toProcess = append(toProcess, initialState)
+	}
 
 	// 循环直到不再有新状态
 	for len(toProcess) > 0 {
@@ -43,17 +64,31 @@ func (p *Parser) BuildStateCollection() {
 		for _, sym := range p.getAllSymbols() {
 			gotoItems := p.gotoState(state.Items, sym)
 			if len(gotoItems) > 0 {
-				newState := &State{Items: p.closure(gotoItems), Index: len(states)}
+				// gotoState 返回的项集已经求过闭包
+				newState := &State{Items: gotoItems, Index: len(states)}
 				// 如果新状态不存在，就添加到状态集合中
-				if _, exists := p.containsState(states, newState); !exists {
+				key := stateKey(newState.Items)
+				index, exists := indexes[key]
+				if !exists {
+					index = newState.Index
+					indexes[key] = index
 					states = append(states, newState)
 					toProcess = append(toProcess, newState) // 入队新状态
 				}
+
+				// 记录状态转移，冲突分析和可视化都需要用到
+				if transitions[state.Index] == nil {
+					transitions[state.Index] = make(map[consts.Symbol]int)
+				}
+				transitions[state.Index][sym] = index
 			}
 		}
 	}
 
 	p.StateCollection = states
+	p.Transitions = transitions
+	p.EntryStates = entryStates
+	p.augmented = augmented
 }
 
 // getAllSymbols 返回文法中所有的符号（终结符和非终结符）
@@ -154,6 +189,16 @@ func itemKey(item LR1Item) string {
 	return fmt.Sprintf("%v|%v|%v|%v", item.Production.Head, item.Production.Body, item.Lookahead, item.Position)
 }
 
+// stateKey 返回项集的规范形式，两个项集相等当且仅当它们的规范形式相等
+func stateKey(items LR1Items) string {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = itemKey(item)
+	}
+	slices.Sort(keys)
+	return strings.Join(slices.Compact(keys), "\n")
+}
+
 // closure 计算LR(1)项集的闭包
 // 闭包是一个重要的概念，用来计算一个状态的所有可能项。在构建状态集合时，我们需要计算每个状态的闭包，以便在状态转移时能够正确地处理展望符。
 // items 是一个 LR(1) 项集，expanded 是一个映射，用来记录哪些项已经扩展过了。
@@ -161,6 +206,12 @@ func (p *Parser) closure(items LR1Items) LR1Items {
 	closure := make(LR1Items, len(items))
 	copy(closure, items)
 
+	// present 记录闭包中已有的项，代替逐项比较的 contains
+	present := make(map[string]bool, len(items))
+	for _, item := range items {
+		present[itemKey(item)] = true
+	}
+
 	expanded := make(map[string]bool, 0)
 
 	changed := true // 标记是否闭包发生了变化，如果没有变化，就不需要继续扩展了
@@ -200,7 +251,8 @@ func (p *Parser) closure(items LR1Items) LR1Items {
 								Position:   0,
 								Lookahead:  item.Lookahead,
 							}
-							if !contains(closure, newItem) {
+							if key := itemKey(newItem); !present[key] {
+								present[key] = true
 								closure = append(closure, newItem)
 								changed = true
 							}
@@ -212,7 +264,8 @@ func (p *Parser) closure(items LR1Items) LR1Items {
 									Lookahead:  lookahead,
 								}
 
-								if !contains(closure, newItem) {
+								if key := itemKey(newItem); !present[key] {
+									present[key] = true
 									closure = append(closure, newItem)
 									changed = true
 								}
@@ -282,7 +335,8 @@ func (p *Parser) computeLookahead(symbols []consts.Symbol, lookahead consts.Term
 		/*
 			在计算 FIRST 集合时，我们只关心可以立即开始的符号。在产生式右侧的符号序列中，如果一个符号的 FIRST 集合包含 EPSILON，那么就意味着这个符号可以为空，我们需要继续查看下一个符号。如果一个符号的 FIRST 集合不包含 EPSILON，那么这个符号就不能为空，我们就可以停止查看后续的符号。
 		*/
-		if !firstSet[EPSILON] {
+		// 注意 firstSet 中不会包含 EPSILON，这里要检查的是 sym 本身的 FIRST 集合，终结符不可空
+		if p.Grammar.IsTerminal(sym) || !p.FirstSet[sym][EPSILON] {
 			allNullable = false
 			break
 		}
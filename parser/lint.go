@@ -0,0 +1,381 @@
+// lint.go
+// 文法健康检查：不可达、不可终止、未定义符号等问题
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// Severity 表示检查结果的严重程度
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // 错误，文法无法正常使用
+	SeverityWarning Severity = "warning" // 警告，文法可以使用但可能存在问题
+	SeverityInfo    Severity = "info"    // 提示
+)
+
+// LintFinding 表示一条文法检查结果
+type LintFinding struct {
+	Severity Severity      // 严重程度
+	Check    string        // 检查项名称，例如 "unreachable"
+	Symbol   consts.Symbol // 相关的符号，可能为空
+	Message  string        // 描述信息
+}
+
+// String 返回检查结果的可读描述
+func (f LintFinding) String() string {
+	return fmt.Sprintf("[%s] %s: %s", f.Severity, f.Check, f.Message)
+}
+
+// NewGrammarChecked 初始化一个文法并进行健康检查
+// 如果检查中存在错误级别的结果，返回的 error 不为空，文法不应该继续用于构建分析表
+func NewGrammarChecked(rules []Production, terminals []consts.Terminal) (*Grammar, []LintFinding, error) {
+	grammar := NewGrammar(rules, terminals)
+	findings := grammar.Lint()
+
+	var errs []string
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errs = append(errs, f.Message)
+		}
+	}
+	if len(errs) > 0 {
+		return grammar, findings, fmt.Errorf("文法检查失败: %s", strings.Join(errs, "; "))
+	}
+	return grammar, findings, nil
+}
+
+// PrintLint 打印文法检查结果
+func (g *Grammar) PrintLint() {
+	findings := g.Lint()
+	fmt.Println("文法检查 - 共有", len(findings), "条结果")
+	for _, f := range findings {
+		fmt.Println(f.String())
+	}
+}
+
+// Lint 对文法进行健康检查
+// 检查项包括：EPSILON 的误用、重复的终结符和产生式、未定义的符号、不可达的非终结符、不可终止的非终结符、A ⇒+ A 形式的环以及未使用的终结符
+func (g *Grammar) Lint() []LintFinding {
+	var findings []LintFinding
+	findings = append(findings, g.lintEpsilon()...)
+	findings = append(findings, g.lintDuplicates()...)
+	findings = append(findings, g.lintUndefined()...)
+	findings = append(findings, g.lintUnreachable()...)
+	findings = append(findings, g.lintUnproductive()...)
+	findings = append(findings, g.lintCycles()...)
+	findings = append(findings, g.lintUnusedTerminals()...)
+	return findings
+}
+
+// NonTerminals 按照第一次作为产生式头部出现的顺序返回所有非终结符
+func (g *Grammar) NonTerminals() []consts.Symbol {
+	var heads []consts.Symbol
+	seen := make(map[consts.Symbol]bool)
+	for _, prod := range g.Productions {
+		if !seen[prod.Head] {
+			seen[prod.Head] = true
+			heads = append(heads, prod.Head)
+		}
+	}
+	return heads
+}
+
+// Nullable 返回所有可以推导出空串的非终结符
+func (g *Grammar) Nullable() map[consts.Symbol]bool {
+	nullable := make(map[consts.Symbol]bool)
+	changed := true
+	for changed {
+		changed = false
+		for _, prod := range g.Productions {
+			if nullable[prod.Head] {
+				continue
+			}
+			all := true
+			for _, sym := range prod.Body {
+				if sym != EPSILON && !nullable[sym] {
+					all = false
+					break
+				}
+			}
+			if all {
+				nullable[prod.Head] = true
+				changed = true
+			}
+		}
+	}
+	return nullable
+}
+
+// lintEpsilon 检查 EPSILON 的误用
+func (g *Grammar) lintEpsilon() []LintFinding {
+	var findings []LintFinding
+	for _, t := range g.Terminals {
+		if t == EPSILON {
+			findings = append(findings, LintFinding{
+				Severity: SeverityWarning,
+				Check:    "epsilon",
+				Message:  "EPSILON 被列在终结符集合中，它表示空串而不是一个真正的终结符",
+			})
+			break
+		}
+	}
+	for i, prod := range g.Productions {
+		if prod.Head == EPSILON {
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Check:    "epsilon",
+				Message:  fmt.Sprintf("产生式 %d 的头部是 EPSILON", i),
+			})
+		}
+		for _, sym := range prod.Body {
+			if sym == EPSILON && len(prod.Body) > 1 {
+				findings = append(findings, LintFinding{
+					Severity: SeverityError,
+					Check:    "epsilon",
+					Symbol:   prod.Head,
+					Message:  fmt.Sprintf("产生式 %d %s → %s 中 EPSILON 与其他符号同时出现", i, prod.Head, formatSymbols(prod.Body)),
+				})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// lintDuplicates 检查重复的终结符与重复的产生式
+func (g *Grammar) lintDuplicates() []LintFinding {
+	var findings []LintFinding
+	seenTerminals := make(map[consts.Terminal]bool)
+	for _, t := range g.Terminals {
+		if seenTerminals[t] && t != EPSILON {
+			findings = append(findings, LintFinding{
+				Severity: SeverityWarning,
+				Check:    "duplicate",
+				Symbol:   consts.Symbol(t),
+				Message:  fmt.Sprintf("终结符 '%s' 被重复声明", t),
+			})
+		}
+		seenTerminals[t] = true
+	}
+
+	for i, prod := range g.Productions {
+		for j := 0; j < i; j++ {
+			if equalProductions(g.Productions[j], prod) {
+				findings = append(findings, LintFinding{
+					Severity: SeverityWarning,
+					Check:    "duplicate",
+					Symbol:   prod.Head,
+					Message:  fmt.Sprintf("产生式 %d 与产生式 %d 重复: %s → %s", i, j, prod.Head, formatSymbols(prod.Body)),
+				})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// lintUndefined 检查被使用但没有定义的符号，以及同时被当作终结符和非终结符的符号
+func (g *Grammar) lintUndefined() []LintFinding {
+	var findings []LintFinding
+	defined := make(map[consts.Symbol]bool)
+	for _, head := range g.NonTerminals() {
+		defined[head] = true
+		if g.IsTerminal(head) {
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Check:    "undefined",
+				Symbol:   head,
+				Message:  fmt.Sprintf("符号 '%s' 既是终结符又是产生式的头部", head),
+			})
+		}
+	}
+
+	reported := make(map[consts.Symbol]bool)
+	for i, prod := range g.Productions {
+		for _, sym := range prod.Body {
+			if sym == EPSILON || defined[sym] || g.IsTerminal(sym) || reported[sym] {
+				continue
+			}
+			reported[sym] = true
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Check:    "undefined",
+				Symbol:   sym,
+				Message:  fmt.Sprintf("符号 '%s' 在产生式 %d 中被使用，但既不是终结符也没有对应的产生式", sym, i),
+			})
+		}
+	}
+	return findings
+}
+
+// reachableSymbols 返回从开始符号出发能够到达的所有符号
+func (g *Grammar) reachableSymbols() map[consts.Symbol]bool {
+	reachable := map[consts.Symbol]bool{g.Start: true}
+	queue := []consts.Symbol{g.Start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, prod := range g.Productions {
+			if prod.Head != current {
+				continue
+			}
+			for _, sym := range prod.Body {
+				if !reachable[sym] {
+					reachable[sym] = true
+					queue = append(queue, sym)
+				}
+			}
+		}
+	}
+	return reachable
+}
+
+// lintUnreachable 检查从开始符号出发无法到达的非终结符
+func (g *Grammar) lintUnreachable() []LintFinding {
+	reachable := g.reachableSymbols()
+	var findings []LintFinding
+	for _, head := range g.NonTerminals() {
+		if !reachable[head] {
+			findings = append(findings, LintFinding{
+				Severity: SeverityWarning,
+				Check:    "unreachable",
+				Symbol:   head,
+				Message:  fmt.Sprintf("非终结符 '%s' 无法从开始符号 '%s' 到达", head, g.Start),
+			})
+		}
+	}
+	return findings
+}
+
+// lintUnproductive 检查无法推导出任何终结符串的非终结符
+func (g *Grammar) lintUnproductive() []LintFinding {
+	productive := make(map[consts.Symbol]bool)
+	changed := true
+	for changed {
+		changed = false
+		for _, prod := range g.Productions {
+			if productive[prod.Head] {
+				continue
+			}
+			all := true
+			for _, sym := range prod.Body {
+				if sym != EPSILON && !g.IsTerminal(sym) && !productive[sym] {
+					all = false
+					break
+				}
+			}
+			if all {
+				productive[prod.Head] = true
+				changed = true
+			}
+		}
+	}
+
+	var findings []LintFinding
+	for _, head := range g.NonTerminals() {
+		if !productive[head] {
+			findings = append(findings, LintFinding{
+				Severity: SeverityError,
+				Check:    "unproductive",
+				Symbol:   head,
+				Message:  fmt.Sprintf("非终结符 '%s' 无法推导出任何终结符串", head),
+			})
+		}
+	}
+	return findings
+}
+
+// lintCycles 检查 A ⇒+ A 形式的环
+// 如果存在产生式 A → α B β 且 α、β 都可以推导出空串，那么 A ⇒+ B，沿着这样的边能回到自身就说明存在环，此时文法一定是二义的
+func (g *Grammar) lintCycles() []LintFinding {
+	nullable := g.Nullable()
+	edges := make(map[consts.Symbol][]consts.Symbol)
+	for _, prod := range g.Productions {
+		for i, sym := range prod.Body {
+			if sym == EPSILON || g.IsTerminal(sym) {
+				continue
+			}
+			rest := true
+			for j, other := range prod.Body {
+				if j != i && other != EPSILON && !nullable[other] {
+					rest = false
+					break
+				}
+			}
+			if rest {
+				edges[prod.Head] = append(edges[prod.Head], sym)
+			}
+		}
+	}
+
+	var findings []LintFinding
+	for _, head := range g.NonTerminals() {
+		// 从 head 出发做广度优先搜索，记录父节点以便还原环
+		parent := make(map[consts.Symbol]consts.Symbol)
+		queue := []consts.Symbol{head}
+		found := false
+		for len(queue) > 0 && !found {
+			current := queue[0]
+			queue = queue[1:]
+			for _, next := range edges[current] {
+				if next == head {
+					parent[head] = current
+					found = true
+					break
+				}
+				if _, seen := parent[next]; !seen {
+					parent[next] = current
+					queue = append(queue, next)
+				}
+			}
+		}
+		if !found {
+			continue
+		}
+
+		cycle := []string{string(head)}
+		for sym := parent[head]; sym != head; sym = parent[sym] {
+			cycle = append([]string{string(sym)}, cycle...)
+		}
+		cycle = append([]string{string(head)}, cycle...)
+		findings = append(findings, LintFinding{
+			Severity: SeverityError,
+			Check:    "cycle",
+			Symbol:   head,
+			Message:  fmt.Sprintf("存在环 %s", strings.Join(cycle, " ⇒ ")),
+		})
+	}
+	return findings
+}
+
+// lintUnusedTerminals 检查声明了但没有在任何产生式中出现的终结符
+func (g *Grammar) lintUnusedTerminals() []LintFinding {
+	used := make(map[consts.Symbol]bool)
+	for _, prod := range g.Productions {
+		for _, sym := range prod.Body {
+			used[sym] = true
+		}
+	}
+
+	var findings []LintFinding
+	seen := make(map[consts.Terminal]bool)
+	for _, t := range g.Terminals {
+		if t == EPSILON || t == TERMINATE_SYMBOL || used[consts.Symbol(t)] || seen[t] {
+			continue
+		}
+		seen[t] = true
+		findings = append(findings, LintFinding{
+			Severity: SeverityInfo,
+			Check:    "unused",
+			Symbol:   consts.Symbol(t),
+			Message:  fmt.Sprintf("终结符 '%s' 没有在任何产生式中使用", t),
+		})
+	}
+	return findings
+}
@@ -0,0 +1,194 @@
+// ll1.go
+// LL(1) 预测分析表的构建与预测分析程序
+
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// LL1Table 表示 LL(1) 预测分析表，Symbol 表示非终结符，Terminal 表示展望符，int 表示产生式编号
+type LL1Table map[consts.Symbol]map[consts.Terminal]int
+
+// LL1Conflict 表示 LL(1) 分析表中的一次冲突
+type LL1Conflict struct {
+	NonTerminal consts.Symbol   // 非终结符
+	Lookahead   consts.Terminal // 展望符
+	Chosen      int             // 表中保留的产生式编号
+	Rejected    int             // 被丢弃的产生式编号
+	Resolved    bool            // 被丢弃的产生式只是通过 Follow 集加入的，按照悬挂 else 的惯例选择了 First 集中包含展望符的产生式
+}
+
+// String 返回冲突的可读描述
+func (c LL1Conflict) String() string {
+	if c.Resolved {
+		return fmt.Sprintf("M[%s, %s] 存在 First/Follow 冲突，保留产生式 %d，舍弃产生式 %d", c.NonTerminal, c.Lookahead, c.Chosen, c.Rejected)
+	}
+	return fmt.Sprintf("M[%s, %s] 存在 First/First 冲突，文法不是 LL(1) 的，保留产生式 %d，舍弃产生式 %d", c.NonTerminal, c.Lookahead, c.Chosen, c.Rejected)
+}
+
+// PredictiveForm 消除左递归并提取左公因子，得到适合预测分析的文法
+func (g *Grammar) PredictiveForm() (*Grammar, []TransformNote) {
+	withoutRecursion, notes := g.EliminateLeftRecursion()
+	factored, factorNotes := withoutRecursion.LeftFactor()
+	return factored, append(notes, factorNotes...)
+}
+
+// BuildLL1Table 根据 First 集和 Follow 集构建 LL(1) 预测分析表，返回发现的冲突
+/*
+	对于每个产生式 A → α：
+	1. 对于 FIRST(α) 中的每个终结符 a，将 A → α 加入 M[A, a]
+	2. 如果 α 可以推导出空串，对于 FOLLOW(A) 中的每个终结符 b，将 A → α 加入 M[A, b]
+	一个单元格中出现多个产生式就说明文法不是 LL(1) 的。
+	如果冲突的一方只是通过 FOLLOW 集加入的，就保留 FIRST 集中包含展望符的产生式，并记为已解决的冲突，
+	这样 stmt' → else stmt | ε 会把 else 交给最近的 if，与悬挂 else 的惯例以及 LR 分析表优先移入的做法一致。
+	两个产生式的 FIRST 集相交时保留先写入的产生式，这种冲突说明文法即使经过 PredictiveForm 变换也不是 LL(1) 的。
+*/
+func (p *Parser) BuildLL1Table() []LL1Conflict {
+	if p.FirstSet == nil {
+		p.InitFirstSet()
+	}
+	if p.FollowSet == nil {
+		p.InitFollowSet()
+	}
+
+	table := make(LL1Table)
+	viaFirst := make(map[consts.Symbol]map[consts.Terminal]bool) // 单元格中的产生式是否通过 FIRST 集加入
+	var conflicts []LL1Conflict
+	set := func(head consts.Symbol, terminal consts.Terminal, index int, first bool) {
+		if table[head] == nil {
+			table[head] = make(map[consts.Terminal]int)
+			viaFirst[head] = make(map[consts.Terminal]bool)
+		}
+		existing, exists := table[head][terminal]
+		if !exists {
+			table[head][terminal], viaFirst[head][terminal] = index, first
+			return
+		}
+		if existing == index {
+			return
+		}
+		// FIRST 集先于 FOLLOW 集写入，所以先写入的产生式总是优先的
+		conflicts = append(conflicts, LL1Conflict{NonTerminal: head, Lookahead: terminal, Chosen: existing, Rejected: index, Resolved: !first && viaFirst[head][terminal]})
+	}
+
+	for index, prod := range p.Grammar.Productions {
+		for terminal := range p.computeFirstSetOfSequence(prod.Body) {
+			// FIRST 集中的 EPSILON 不是输入符号，可以推导出空串的产生式由下面的 FOLLOW 集写入
+			if terminal == EPSILON {
+				continue
+			}
+			set(prod.Head, terminal, index, true)
+		}
+	}
+	for index, prod := range p.Grammar.Productions {
+		if p.nullableSequence(prod.Body) {
+			for terminal := range p.FollowSet[prod.Head] {
+				set(prod.Head, terminal, index, false)
+			}
+		}
+	}
+
+	slices.SortFunc(conflicts, func(a, b LL1Conflict) int {
+		if a.Rejected != b.Rejected {
+			return a.Rejected - b.Rejected
+		}
+		return strings.Compare(string(a.Lookahead), string(b.Lookahead))
+	})
+	p.LL1Table = table
+	return conflicts
+}
+
+// PrintLL1Table 打印 LL(1) 预测分析表
+func (p *Parser) PrintLL1Table() {
+	fmt.Println("LL(1) 预测分析表")
+	terminals := append(append([]consts.Terminal{}, p.Grammar.Terminals...), TERMINATE_SYMBOL)
+	printed := make(map[consts.Terminal]bool)
+	for _, head := range p.Grammar.NonTerminals() {
+		clear(printed)
+		for _, terminal := range terminals {
+			index, ok := p.LL1Table[head][terminal]
+			if !ok || printed[terminal] {
+				continue
+			}
+			printed[terminal] = true
+			prod := p.Grammar.Productions[index]
+			fmt.Printf("M[%s, %s] = %s → %s\n", head, terminal, prod.Head, formatBody(prod.Body))
+		}
+	}
+}
+
+// ParseLL1 使用 LL(1) 预测分析表对输入进行分析
+// 预测分析是自顶向下的，规约函数依赖自底向上的符号栈布局，所以这里只做语法分析，不会调用产生式的处理函数
+// 分析过程和 Parse 一样通过 p.Tracer 报告，事件的 Predictive 为 true
+func (p *Parser) ParseLL1(l *lexer.Lexer) error {
+	if p.LL1Table == nil {
+		return fmt.Errorf("LL(1) 预测分析表尚未构建")
+	}
+
+	// 分析栈的栈底是终止符，栈顶是开始符号
+	stack := []consts.Symbol{consts.Symbol(TERMINATE_SYMBOL), p.Grammar.Start}
+	token, err := l.NextToken()
+	if err != nil {
+		return err
+	}
+	cnt := 0
+
+	p.trace(TraceEvent{Kind: TRACE_BEGIN, Predictive: true})
+	for {
+		cnt++
+		p.trace(TraceEvent{Kind: TRACE_STEP, Step: cnt, SymbolStack: slices.Clone(stack), Predictive: true})
+
+		top := stack[len(stack)-1]
+		terminal := TokenToTerminal(token)
+		p.trace(TraceEvent{Kind: TRACE_TOKEN, Step: cnt, Head: top, Token: token.Value, Terminal: terminal, Predictive: true})
+
+		// 栈顶是终止符，且输入也结束，分析成功
+		if top == consts.Symbol(TERMINATE_SYMBOL) {
+			if terminal != TERMINATE_SYMBOL {
+				err := fmt.Errorf("解析错误：输入在符号 %s 处没有结束\n", terminal)
+				p.trace(TraceEvent{Kind: TRACE_ERROR, Step: cnt, Head: top, Token: token.Value, Terminal: terminal, Message: err.Error(), Predictive: true})
+				return err
+			}
+			p.trace(TraceEvent{Kind: TRACE_ACCEPT, Step: cnt, Predictive: true})
+			return nil
+		}
+
+		// 栈顶是终结符，需要与输入匹配
+		if p.Grammar.IsTerminal(top) {
+			if consts.Terminal(top) != terminal {
+				err := fmt.Errorf("解析错误：期望符号 %s，但读到了 %s\n", top, terminal)
+				p.trace(TraceEvent{Kind: TRACE_ERROR, Step: cnt, Head: top, Token: token.Value, Terminal: terminal, Message: err.Error(), Predictive: true})
+				return err
+			}
+			p.trace(TraceEvent{Kind: TRACE_SHIFT, Step: cnt, Token: token.Value, Terminal: terminal, Predictive: true})
+			stack = stack[:len(stack)-1]
+			if token, err = l.NextToken(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// 栈顶是非终结符，查表选择产生式展开
+		index, ok := p.LL1Table[top][terminal]
+		if !ok {
+			err := fmt.Errorf("解析错误：无法找到非终结符 %s 和符号 %s 的产生式\n", top, terminal)
+			p.trace(TraceEvent{Kind: TRACE_ERROR, Step: cnt, Head: top, Token: token.Value, Terminal: terminal, Message: err.Error(), Predictive: true})
+			return err
+		}
+		prod := p.Grammar.Productions[index]
+		p.trace(TraceEvent{Kind: TRACE_ACTION, Step: cnt, Terminal: terminal, Production: index, Head: prod.Head, Body: prod.Body, Predictive: true})
+
+		// 产生式体逆序入栈，EPSILON 不入栈
+		stack = stack[:len(stack)-1]
+		body := rhs(prod.Body)
+		for i := len(body) - 1; i >= 0; i-- {
+			stack = append(stack, body[i])
+		}
+	}
+}
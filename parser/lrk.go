@@ -0,0 +1,394 @@
+// lrk.go
+// 规范 LR(k) 项集族与分析表的构建，以及使用 k 个展望符的分析程序
+
+package parser
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// Lookahead 表示 LR(k) 项的展望串，长度总是 k，输入结束之后的部分用 $ 补齐
+type Lookahead []consts.Terminal
+
+// String 返回展望串的规范形式，同时用作分析表的键
+func (l Lookahead) String() string {
+	terminals := make([]string, len(l))
+	for i, terminal := range l {
+		terminals[i] = string(terminal)
+	}
+	return strings.Join(terminals, " ")
+}
+
+// LRkItem 表示一个 LR(k) 项，与 LR1Item 相比，展望符换成了长度为 k 的展望串
+type LRkItem struct {
+	Production Production // 产生式
+	Position   int        // 点的位置，不计算 EPSILON
+	Lookahead  Lookahead  // 展望串
+}
+
+// LRkState 表示一个 LR(k) 状态
+type LRkState struct {
+	Items []LRkItem // LR(k) 项集
+	Index int       // 状态编号
+}
+
+// LRkConflict 表示 LR(k) 分析表中的一次冲突
+type LRkConflict struct {
+	State     int         // 状态编号
+	Lookahead Lookahead   // 展望串
+	Chosen    ActionEntry // 表中保留的动作
+	Rejected  ActionEntry // 被丢弃的动作
+}
+
+// String 返回冲突的可读描述
+func (c LRkConflict) String() string {
+	return fmt.Sprintf("ACTION[%d, %s] 存在冲突，保留 %s，舍弃 %s", c.State, c.Lookahead, c.Chosen.Short(), c.Rejected.Short())
+}
+
+// LRkTable 表示规范 LR(k) 分析表
+// Action 表的键是展望串的规范形式，移入动作也需要看完整的展望串
+type LRkTable struct {
+	K           int                                    // 展望符的个数
+	States      []*LRkState                            // 状态集合
+	Transitions Transitions                            // 状态转移
+	Action      map[int]map[string]ActionEntry         // Action 表
+	Goto        GotoTable                              // Goto 表
+	Conflicts   []LRkConflict                          // 构建分析表时发现的冲突
+	first       map[consts.Symbol]map[string]Lookahead // 每个符号的 FIRSTₖ 集合
+}
+
+// BuildLRkTable 构建规范 LR(k) 分析表，返回发现的冲突
+/*
+	构建过程与 BuildStateCollection、BuildTables 相同，区别只在于展望符：
+	1. 闭包：对于项 [A → α·Bβ, u]，对 FIRSTₖ(βu) 中的每个展望串 v，加入项 [B → ·γ, v]
+	2. 规约：对于项 [A → α·, u]，在展望串 u 下规约
+	3. 移入：对于项 [A → α·aβ, u]，在 FIRSTₖ(aβu) 中的每个展望串下移入
+	状态按照相同的广度优先顺序和符号顺序编号，所以 k = 1 时得到的分析表与 BuildTables 完全相同。
+*/
+func (p *Parser) BuildLRkTable(k int) []LRkConflict {
+	if k < 1 {
+		k = 1
+	}
+	t := &LRkTable{
+		K:           k,
+		Transitions: make(Transitions),
+		Action:      make(map[int]map[string]ActionEntry),
+		Goto:        make(GotoTable),
+		first:       p.firstK(k),
+	}
+
+	end := make(Lookahead, k)
+	for i := range end {
+		end[i] = TERMINATE_SYMBOL
+	}
+	initial := &LRkState{Items: t.closure(p, []LRkItem{{Production: p.Grammar.AugmentedProduction(), Lookahead: end}}), Index: 0}
+	t.States = []*LRkState{initial}
+	indexes := map[string]int{lrkStateKey(initial.Items): 0}
+
+	symbols := p.getAllSymbols()
+	for queue := []*LRkState{initial}; len(queue) > 0; queue = queue[1:] {
+		state := queue[0]
+		for _, sym := range symbols {
+			var kernel []LRkItem
+			for _, item := range state.Items {
+				if body := rhs(item.Production.Body); item.Position < len(body) && body[item.Position] == sym {
+					kernel = append(kernel, LRkItem{Production: item.Production, Position: item.Position + 1, Lookahead: item.Lookahead})
+				}
+			}
+			if len(kernel) == 0 {
+				continue
+			}
+			items := t.closure(p, kernel)
+			key := lrkStateKey(items)
+			index, exists := indexes[key]
+			if !exists {
+				index = len(t.States)
+				indexes[key] = index
+				next := &LRkState{Items: items, Index: index}
+				t.States = append(t.States, next)
+				queue = append(queue, next)
+			}
+			if t.Transitions[state.Index] == nil {
+				t.Transitions[state.Index] = make(map[consts.Symbol]int)
+			}
+			t.Transitions[state.Index][sym] = index
+		}
+	}
+
+	for _, state := range t.States {
+		for sym, next := range t.Transitions[state.Index] {
+			if !p.Grammar.IsTerminal(sym) {
+				if t.Goto[state.Index] == nil {
+					t.Goto[state.Index] = make(map[consts.Symbol]int)
+				}
+				t.Goto[state.Index][sym] = next
+			}
+		}
+		for _, item := range state.Items {
+			t.setItemAction(p, state.Index, item)
+		}
+	}
+
+	p.LRkTable = t
+	return t.Conflicts
+}
+
+// setItemAction 将一个项对应的动作写入 Action 表，冲突时保留先写入的动作
+func (t *LRkTable) setItemAction(p *Parser, state int, item LRkItem) {
+	set := func(lookahead Lookahead, action ActionEntry) {
+		if t.Action[state] == nil {
+			t.Action[state] = make(map[string]ActionEntry)
+		}
+		key := lookahead.String()
+		existing, exists := t.Action[state][key]
+		if !exists {
+			t.Action[state][key] = action
+			return
+		}
+		if existing != action {
+			t.Conflicts = append(t.Conflicts, LRkConflict{State: state, Lookahead: lookahead, Chosen: existing, Rejected: action})
+		}
+	}
+
+	body := rhs(item.Production.Body)
+	if item.Position == len(body) {
+		if p.isAugmented(item.Production) {
+			set(item.Lookahead, ActionEntry{ActionType: ACCEPT, Number: 0})
+			return
+		}
+		set(item.Lookahead, ActionEntry{ActionType: REDUCE, Number: p.productionIndex(item.Production)})
+		return
+	}
+
+	sym := body[item.Position]
+	if !p.Grammar.IsTerminal(sym) {
+		return
+	}
+	next, ok := t.Transitions[state][sym]
+	if !ok {
+		return
+	}
+	// FIRSTₖ(aβu) 就是 a 后面接上 FIRSTₖ(βu) 的前 k-1 个符号
+	for _, rest := range t.sequence(body[item.Position+1:], item.Lookahead) {
+		lookahead := append(Lookahead{consts.Terminal(sym)}, rest[:t.K-1]...)
+		set(lookahead, ActionEntry{ActionType: SHIFT, Number: next})
+	}
+}
+
+// closure 计算 LR(k) 项集的闭包
+func (t *LRkTable) closure(p *Parser, items []LRkItem) []LRkItem {
+	closure := slices.Clone(items)
+	present := make(map[string]bool, len(items))
+	for _, item := range items {
+		present[lrkItemKey(item)] = true
+	}
+
+	for i := 0; i < len(closure); i++ {
+		item := closure[i]
+		body := rhs(item.Production.Body)
+		if item.Position >= len(body) || p.Grammar.IsTerminal(body[item.Position]) {
+			continue
+		}
+		lookaheads := t.sequence(body[item.Position+1:], item.Lookahead)
+		for _, prod := range p.Grammar.Productions {
+			if prod.Head != body[item.Position] {
+				continue
+			}
+			for _, lookahead := range lookaheads {
+				newItem := LRkItem{Production: prod, Lookahead: lookahead}
+				if key := lrkItemKey(newItem); !present[key] {
+					present[key] = true
+					closure = append(closure, newItem)
+				}
+			}
+		}
+	}
+	return closure
+}
+
+// sequence 计算 FIRSTₖ(symbols lookahead)，按照规范形式排序
+// lookahead 的长度是 k，所以结果中每个展望串的长度也都是 k
+func (t *LRkTable) sequence(symbols []consts.Symbol, lookahead Lookahead) []Lookahead {
+	prefixes := map[string]Lookahead{"": {}}
+	for _, sym := range symbols {
+		prefixes = concatK(prefixes, t.first[sym], t.K)
+	}
+	prefixes = concatK(prefixes, map[string]Lookahead{lookahead.String(): lookahead}, t.K)
+
+	keys := make([]string, 0, len(prefixes))
+	for key := range prefixes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	result := make([]Lookahead, len(keys))
+	for i, key := range keys {
+		result[i] = prefixes[key]
+	}
+	return result
+}
+
+// firstK 计算每个符号的 FIRSTₖ 集合，即符号能推导出的终结符串截取前 k 个符号
+// 与 InitFirstSet 一样迭代到不再变化为止，空串用长度为 0 的展望串表示
+func (p *Parser) firstK(k int) map[consts.Symbol]map[string]Lookahead {
+	first := make(map[consts.Symbol]map[string]Lookahead)
+	for _, terminal := range p.Grammar.Terminals {
+		lookahead := Lookahead{terminal}
+		first[consts.Symbol(terminal)] = map[string]Lookahead{lookahead.String(): lookahead}
+	}
+	for _, prod := range p.Grammar.Productions {
+		if first[prod.Head] == nil {
+			first[prod.Head] = make(map[string]Lookahead)
+		}
+	}
+
+	changed := true
+	for changed {
+		changed = false
+		for _, prod := range p.Grammar.Productions {
+			prefixes := map[string]Lookahead{"": {}}
+			for _, sym := range rhs(prod.Body) {
+				prefixes = concatK(prefixes, first[sym], k)
+			}
+			for key, lookahead := range prefixes {
+				if _, exists := first[prod.Head][key]; !exists {
+					first[prod.Head][key] = lookahead
+					changed = true
+				}
+			}
+		}
+	}
+	return first
+}
+
+// concatK 计算两个终结符串集合的连接，结果截取前 k 个符号
+// 左侧已经有 k 个符号的串不受右侧影响，右侧集合为空时其余的串都会被丢弃
+func concatK(left, right map[string]Lookahead, k int) map[string]Lookahead {
+	result := make(map[string]Lookahead)
+	for key, l := range left {
+		if len(l) >= k {
+			result[key] = l
+			continue
+		}
+		for _, r := range right {
+			s := append(slices.Clone(l), r...)
+			if len(s) > k {
+				s = s[:k]
+			}
+			result[s.String()] = s
+		}
+	}
+	return result
+}
+
+// lrkItemKey 返回 LR(k) 项的规范形式
+func lrkItemKey(item LRkItem) string {
+	return fmt.Sprintf("%v|%v|%v|%v", item.Production.Head, item.Production.Body, item.Lookahead, item.Position)
+}
+
+// lrkStateKey 返回 LR(k) 项集的规范形式
+func lrkStateKey(items []LRkItem) string {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = lrkItemKey(item)
+	}
+	slices.Sort(keys)
+	return strings.Join(slices.Compact(keys), "\n")
+}
+
+// PrintLRkTable 把 LR(k) 分析表写入 w，每个状态的动作按照展望串排序
+func (p *Parser) PrintLRkTable(w io.Writer) {
+	t := p.LRkTable
+	fmt.Fprintf(w, "LR(%d) 分析表 - 共有 %d 个状态\n", t.K, len(t.States))
+	for i := range t.States {
+		keys := make([]string, 0, len(t.Action[i]))
+		for key := range t.Action[i] {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			fmt.Fprintf(w, "ACTION[%d, %s] = %s\n", i, key, t.Action[i][key].Short())
+		}
+	}
+}
+
+// ParseLRk 使用 LR(k) 分析表对输入进行分析，每一步查看 k 个展望符
+func (p *Parser) ParseLRk(l *lexer.Lexer) error {
+	t := p.LRkTable
+	if t == nil {
+		return fmt.Errorf("LR(k) 分析表尚未构建")
+	}
+	tokens, err := lexAll(l)
+	if err != nil {
+		return err
+	}
+
+	// window 返回从第 i 个 Token 开始的展望串，超出输入的部分用 $ 补齐
+	window := func(i int) Lookahead {
+		lookahead := make(Lookahead, t.K)
+		for j := range lookahead {
+			lookahead[j] = TERMINATE_SYMBOL
+			if i+j < len(tokens) {
+				lookahead[j] = TokenToTerminal(tokens[i+j])
+			}
+		}
+		return lookahead
+	}
+
+	p.beginParse(0)
+	next, cnt := 0, 0
+
+	// 分析过程与 Parse 一样通过 Tracer 输出，token 事件中的终结符是展望串的第一个符号
+	p.trace(TraceEvent{Kind: TRACE_BEGIN})
+	for {
+		cnt++
+		p.trace(TraceEvent{Kind: TRACE_STEP, Step: cnt, StateStack: slices.Clone(p.StateStack), SymbolStack: slices.Clone(p.TokenStack)})
+
+		state := p.StateStack[len(p.StateStack)-1]
+		lookahead := window(next)
+		token := string(TERMINATE_SYMBOL)
+		if next < len(tokens) {
+			token = tokens[next].Value
+		}
+		p.trace(TraceEvent{Kind: TRACE_TOKEN, Step: cnt, State: state, Token: token, Terminal: lookahead[0]})
+
+		action, ok := t.Action[state][lookahead.String()]
+		if !ok {
+			err := fmt.Errorf("解析错误：无法找到状态 %d 和展望串 %s 的动作\n", state, lookahead)
+			p.trace(TraceEvent{Kind: TRACE_ERROR, Step: cnt, State: state, Token: token, Terminal: lookahead[0], Message: err.Error()})
+			return err
+		}
+		p.trace(TraceEvent{Kind: TRACE_ACTION, Step: cnt, State: state, Token: token, Terminal: lookahead[0], Action: action})
+
+		switch action.ActionType {
+		case SHIFT:
+			p.StateStack = append(p.StateStack, action.Number)
+			p.pushSymbol(consts.Symbol(tokens[next].Value), TokenValue(tokens[next]))
+			p.trace(TraceEvent{Kind: TRACE_SHIFT, Step: cnt, State: action.Number, Token: token, Terminal: lookahead[0]})
+			next++
+		case REDUCE:
+			production := p.Grammar.Productions[action.Number]
+			p.trace(TraceEvent{Kind: TRACE_REDUCE, Step: cnt, State: state, Terminal: lookahead[0], Production: action.Number, Head: production.Head, Body: production.Body})
+			if err := p.applyReduction(action.Number); err != nil {
+				return err
+			}
+			p.StateStack = p.StateStack[:len(p.StateStack)-len(rhs(production.Body))]
+			topState := p.StateStack[len(p.StateStack)-1]
+			gotoState, ok := t.Goto[topState][production.Head]
+			if !ok {
+				return fmt.Errorf("解析错误：无法在状态 %v 中找到产生式 %v 的转移状态\n", topState, production)
+			}
+			p.trace(TraceEvent{Kind: TRACE_GOTO, Step: cnt, State: topState, Head: production.Head, Target: gotoState})
+			p.StateStack = append(p.StateStack, gotoState)
+		case ACCEPT:
+			p.trace(TraceEvent{Kind: TRACE_ACCEPT, Step: cnt, State: state})
+			p.SymbolTable.ExitScope()
+			return nil
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// midaction.go
+// 产生式体中间的语义动作，通过自动插入的空标记非终结符实现
+
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// MARKER_PREFIX 表示标记非终结符名字的前缀，词法分析器不会产生以它开头的符号
+const MARKER_PREFIX = "@"
+
+// MidAction 表示产生式体中间的一个语义动作
+type MidAction struct {
+	Position int                                          // 动作在产生式体中的位置，即动作左边有几个符号（不计算 EPSILON 和其他标记）
+	Name     string                                       // 标记的名字，同名的动作共享同一个标记非终结符
+	Handler  func(p *Parser, left []Value) (Value, error) // 动作的处理函数，left[i] 是原产生式体中第 i 个符号在语义值栈中的值，返回值是标记的值
+}
+
+// MarkerSymbol 返回中间动作对应的标记非终结符
+func MarkerSymbol(name string) consts.Symbol {
+	return consts.Symbol(MARKER_PREFIX + name)
+}
+
+// IsMarker 判断符号是否是中间动作的标记非终结符
+func IsMarker(sym consts.Symbol) bool {
+	return strings.HasPrefix(string(sym), MARKER_PREFIX)
+}
+
+// WithMidActions 将中间动作展开为标记非终结符，返回新的文法
+/*
+	对于 A → α {action} β，在动作的位置插入一个新的非终结符 @action，并加入产生式 @action → ε：
+	1. 分析到这个位置时，@action → ε 被规约，它的处理函数就是中间动作，此时语义值栈的栈顶正好是 α 对应的值，
+	   动作返回的值作为标记的值留在语义值栈中，之后的动作和产生式的处理函数通过 MarkerValue 取出
+	2. actions 的键是产生式在 rules 中的编号，标记产生式追加在最后，原有产生式的编号保持不变
+	3. 两个产生式有相同的前缀时（例如 if 语句和 if-else 语句），如果在前缀后面插入不同的标记，
+	   分析程序读完前缀之后无法决定规约哪一个标记，会产生规约-规约冲突，这时应该让它们使用同名的动作，共享同一个标记。
+	   同名的标记只会生成一个产生式，所以它们的位置和处理函数必须相同。
+*/
+func (g *Grammar) WithMidActions(actions map[int][]MidAction) *Grammar {
+	productions := slices.Clone(g.Productions)
+	positions := make(map[consts.Symbol]int)
+	var markers []Production
+
+	for index := range productions {
+		list := slices.Clone(actions[index])
+		if len(list) == 0 {
+			continue
+		}
+		slices.SortStableFunc(list, func(a, b MidAction) int { return a.Position - b.Position })
+
+		body := rhs(productions[index].Body)
+		expanded := make([]consts.Symbol, 0, len(body)+len(list))
+		next := 0
+		for _, action := range list {
+			if action.Position < 0 || action.Position > len(body) {
+				panic(fmt.Sprintf("发生逻辑错误: 中间动作 %s 的位置 %d 超出了产生式 %d 的范围", action.Name, action.Position, index))
+			}
+			expanded = append(expanded, body[next:action.Position]...)
+			next = action.Position
+
+			marker := MarkerSymbol(action.Name)
+			expanded = append(expanded, marker)
+			if position, exists := positions[marker]; exists {
+				if position != action.Position {
+					panic(fmt.Sprintf("发生逻辑错误: 同名的中间动作 %s 出现在不同的位置 %d 和 %d", action.Name, position, action.Position))
+				}
+				continue
+			}
+			positions[marker] = action.Position
+			markers = append(markers, Production{marker, []consts.Symbol{EPSILON}, markerHandler(action)})
+		}
+		expanded = append(expanded, body[next:]...)
+
+		productions[index] = Production{productions[index].Head, expanded, productions[index].Handler}
+	}
+
+	// 原有产生式的编号不变，所以优先级可以直接沿用
+	grammar := NewGrammar(append(productions, markers...), g.Terminals)
+	grammar.Start = g.Start
+	grammar.Precedence, grammar.ProductionPrec = g.Precedence, g.ProductionPrec
+	return grammar
+}
+
+// markerHandler 将中间动作包装为标记产生式的处理函数
+// 标记产生式体为空，规约时语义值栈的栈顶就是动作左边的符号的值，其中更早的标记不属于原产生式体，需要去掉
+func markerHandler(action MidAction) func(*Parser, []Value) (Value, error) {
+	return func(p *Parser, _ []Value) (Value, error) {
+		var left []Value
+		for i := len(p.TokenStack) - 1; i >= 0 && len(left) < action.Position; i-- {
+			if !IsMarker(p.TokenStack[i]) {
+				left = append(left, p.ValueStack[i])
+			}
+		}
+		slices.Reverse(left)
+		return action.Handler(p, left)
+	}
+}
+
+// MarkerValue 返回符号栈中离栈顶最近的标记 @name 的值
+// 产生式体中的子语句在规约时已经弹出了它们自己的标记，所以在中间动作和产生式的处理函数中，最近的同名标记就属于当前的产生式
+func (p *Parser) MarkerValue(name string) (Value, error) {
+	marker := MarkerSymbol(name)
+	for i := len(p.TokenStack) - 1; i >= 0; i-- {
+		if p.TokenStack[i] == marker {
+			return p.ValueStack[i], nil
+		}
+	}
+	return Value{}, fmt.Errorf("发生逻辑错误: 符号栈中没有标记 %s", marker)
+}
@@ -0,0 +1,121 @@
+// panic.go
+// 恐慌模式的语法错误恢复：弹出到可以转移到同步非终结符的状态，跳过 Token 直到同步终结符，然后继续分析
+
+package parser
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// PanicMode 表示恐慌模式错误恢复的配置
+type PanicMode struct {
+	Symbols   []consts.Symbol   // 同步非终结符，越靠前越优先，例如 stmt、decl
+	Terminals []consts.Terminal // 同步终结符，跳过 Token 时在这些终结符处停下，例如 ; 和 }
+	MaxErrors int               // 最多报告多少处语法错误，超过之后停止分析，为 0 表示不限制
+}
+
+// DEFAULT_PANIC_MODE 是课程文法使用的恐慌模式配置
+var DEFAULT_PANIC_MODE = PanicMode{
+	Symbols:   []consts.Symbol{"stmt", "decl"},
+	Terminals: []consts.Terminal{";", "}"},
+	MaxErrors: 20,
+}
+
+// EnablePanicMode 开启恐慌模式的错误恢复
+/*
+	开启之后，遇到语法错误时如果栈中有状态可以处理 error（即文法中的错误产生式可以处理这个错误），仍然使用错误产生式恢复，
+	否则使用恐慌模式恢复：所有错误都记录在 SyntaxErrors 中，分析会一直进行到输入结束，除非错误数超过了 MaxErrors。
+	恐慌模式恢复时同步非终结符不经过规约就直接压入栈中，不会调用处理函数，它的值为空，符号栈和状态栈仍然是一致的。
+	跳过的 Token 和压入的非终结符会记录为错误节点。与错误产生式相同，发生过语法错误时分析结束后清空生成的三地址码。
+*/
+func (p *Parser) EnablePanicMode(mode PanicMode) {
+	p.Panic = &mode
+}
+
+// canRecoverWithErrorProductions 判断栈中是否有状态可以处理 error，不修改栈
+func (p *Parser) canRecoverWithErrorProductions() bool {
+	if !p.canRecover() {
+		return false
+	}
+	return slices.ContainsFunc(p.StateStack, func(state int) bool {
+		_, ok := p.ActionTable[state][ERROR_TERMINAL]
+		return ok
+	})
+}
+
+// syncState 在栈中从上往下寻找可以转移到同步非终结符、并且转移之后可以接受 terminal 的状态
+// 返回该状态在栈中的位置和同步非终结符，找不到时位置为 -1
+func (p *Parser) syncState(terminal consts.Terminal) (int, consts.Symbol) {
+	for i := len(p.StateStack) - 1; i >= 0; i-- {
+		for _, sym := range p.Panic.Symbols {
+			target, ok := p.GotoTable[p.StateStack[i]][sym]
+			if !ok {
+				continue
+			}
+			if action, ok := p.ActionTable[target][terminal]; ok && action.ActionType != ERROR {
+				return i, sym
+			}
+		}
+	}
+	return -1, ""
+}
+
+// panicRecover 从语法错误中恢复，返回恢复之后的当前 Token
+/*
+	依次查看当前 Token 和之后的 Token：遇到同步终结符时，在栈中寻找可以转移到同步非终结符、并且可以接受这个终结符的状态，
+	找到时弹出它上面的所有状态，压入同步非终结符和转移后的状态。同步终结符不能接在同步非终结符后面时（例如 ; 是语句的一部分），
+	丢弃它并且同样检查紧接着的 Token。discardFirst 为 true 时当前 Token 在上一次恢复之后已经出过错，必须先丢弃，避免死循环。
+	到达输入末尾仍然无法恢复时返回 false。
+*/
+func (p *Parser) panicRecover(l *lexer.Lexer, token lexer.Token, step int, discardFirst bool) (lexer.Token, bool, error) {
+	var skipped []lexer.Token
+	errorToken := token
+	candidate := true
+	for first := true; ; first = false {
+		terminal := TokenToTerminal(token)
+		isSync := slices.Contains(p.Panic.Terminals, terminal) || terminal == TERMINATE_SYMBOL
+		if (candidate || isSync) && !(first && discardFirst) {
+			if index, sym := p.syncState(terminal); index >= 0 {
+				for len(p.StateStack) > index+1 {
+					p.trace(TraceEvent{Kind: TRACE_POP, Step: step, State: p.StateStack[len(p.StateStack)-1], Token: string(p.TokenStack[len(p.TokenStack)-1])})
+					p.StateStack = p.StateStack[:len(p.StateStack)-1]
+					p.popSymbols(1)
+					p.dropLabels()
+				}
+				top := p.StateStack[index]
+				target := p.GotoTable[top][sym]
+				p.trace(TraceEvent{Kind: TRACE_GOTO, Step: step, State: top, Head: sym, Target: target, Recovery: true})
+				p.StateStack = append(p.StateStack, target)
+				p.pushSymbol(sym, Value{})
+				p.ErrorNodes = append(p.ErrorNodes, ErrorNode{Symbol: sym, Token: errorToken, Skipped: skipped})
+				return token, true, nil
+			}
+		}
+		if terminal == TERMINATE_SYMBOL {
+			return token, false, nil
+		}
+		p.trace(TraceEvent{Kind: TRACE_DISCARD, Step: step, Token: token.Value, Terminal: terminal})
+		skipped = append(skipped, token)
+		// 紧跟在同步终结符之后的 Token 也可以作为恢复点，例如 x = ; y = 1; 中的 y
+		candidate = isSync
+		var err error
+		if token, err = l.NextToken(); err != nil {
+			return token, false, err
+		}
+	}
+}
+
+// tooManyErrors 判断报告的语法错误是否已经达到上限
+func (p *Parser) tooManyErrors() bool {
+	return p.Panic.MaxErrors > 0 && len(p.SyntaxErrors) >= p.Panic.MaxErrors
+}
+
+// panicSummary 在恐慌模式无法继续时汇总语法错误，与 errorSummary 相同不保留三地址码
+func (p *Parser) panicSummary(reason string) error {
+	p.ThreeAddress = nil
+	return fmt.Errorf("%s，共发现 %d 处语法错误\n", reason, len(p.SyntaxErrors))
+}
@@ -5,6 +5,7 @@ package parser
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/ozline/CoursePractice-GoCompiler/consts"
 	"github.com/ozline/CoursePractice-GoCompiler/intercoder"
@@ -13,8 +14,22 @@ import (
 
 // NewParser 创建一个新的 Parser 实例
 func NewParser() *Parser {
-	grammar := NewGrammar(PRODUCTIONS, TERMINALS)
+	return NewParserWithGrammar(NewGrammar(PRODUCTIONS, TERMINALS).WithMidActions(MID_ACTIONS))
+}
 
+// NewParserChecked 与 NewParser 相同，但是先用 NewGrammarChecked 对课程文法做健康检查
+// 存在错误级别的检查结果时返回错误，调用者应该停止，不再构建状态集合和分析表；没有错误时也返回所有检查结果
+func NewParserChecked() (*Parser, []LintFinding, error) {
+	grammar, findings, err := NewGrammarChecked(PRODUCTIONS, TERMINALS)
+	if err != nil {
+		return nil, findings, err
+	}
+	return NewParserWithGrammar(grammar.WithMidActions(MID_ACTIONS)), findings, nil
+}
+
+// NewParserWithGrammar 使用指定的文法创建一个新的 Parser 实例
+// 例如经过消除左递归、提取左公因子后的文法
+func NewParserWithGrammar(grammar *Grammar) *Parser {
 	parser := &Parser{
 		Grammar:         grammar,
 		StateCollection: []*State{},
@@ -55,24 +70,36 @@ func TokenToSymbol(token lexer.Token) consts.Symbol {
 }
 
 func (p *Parser) Parse(l *lexer.Lexer) error {
-	var token lexer.Token
+	return p.parseFrom(0, l)
+}
+
+// ParseAs 以 symbol 为开始符号分析输入，例如只分析一个表达式或一条语句
+// symbol 必须在构建状态集合时通过 BuildStateCollectionFor 指定为入口
+func (p *Parser) ParseAs(symbol consts.Symbol, l *lexer.Lexer) error {
+	state, ok := p.EntryStates[symbol]
+	if !ok {
+		return fmt.Errorf("符号 %s 不是分析表的入口，需要在 BuildStateCollectionFor 中指定\n", symbol)
+	}
+	return p.parseFrom(state, l)
+}
+
+// parseFrom 从入口的初始状态 start 开始分析输入
+func (p *Parser) parseFrom(start int, l *lexer.Lexer) error {
+	var token, previous lexer.Token // previous 是最后一个移入的 Token，用来定位语法错误
 	var err error
-	// 初始化分析栈，初始状态为 0
-	p.StateStack = []int{0}                                         // 状态栈
-	p.TokenStack = []consts.Symbol{consts.Symbol(TERMINATE_SYMBOL)} // 预留一个空位，用于处理状态 0 的转移
+	// 初始化分析栈，初始状态为入口的初始状态
+	p.beginParse(start)
 	cnt := int(0)
-	p.SymbolTable.EnterScope() // 进入一个新的作用域
 
 	// 主循环，直到接受或遇到错误
 	readNextToken := true
-	fmt.Printf("\n\n===============开始解析===============")
+	injected := false // 错误恢复时插入了 error 终结符，当前 Token 暂时保留
+	panicShifts := 0  // 恐慌模式恢复之后还需要移入多少个 Token 才会报告新的语法错误
+	p.trace(TraceEvent{Kind: TRACE_BEGIN})
 	for {
-		fmt.Printf("\n\n=====================================\n")
-		// 打印状态和符号栈
+		// 报告状态和符号栈
 		cnt++
-		fmt.Printf("第 %d 步\n", cnt)
-		fmt.Printf("状态栈: %v\n", p.StateStack)
-		fmt.Printf("符号栈: %v\n", p.TokenStack)
+		p.trace(TraceEvent{Kind: TRACE_STEP, Step: cnt, StateStack: slices.Clone(p.StateStack), SymbolStack: slices.Clone(p.TokenStack)})
 		// 查看栈顶状态
 		state := p.StateStack[len(p.StateStack)-1]
 
@@ -84,52 +111,120 @@ func (p *Parser) Parse(l *lexer.Lexer) error {
 			readNextToken = false
 		}
 
-		// 将 Token 转换为终结符
+		// 将 Token 转换为终结符，错误恢复时用 error 终结符代替当前 Token
 		terminal := TokenToTerminal(token)
+		if injected {
+			terminal = ERROR_TERMINAL
+		}
 
 		// 根据当前状态和读取的 Token（终结符）查找 Action 表中的动作
-		fmt.Printf("当前状态: %d, 当前符号: %s 转换后: %s\n", state, token.Value, terminal)
+		p.trace(TraceEvent{Kind: TRACE_TOKEN, Step: cnt, State: state, Token: token.Value, Terminal: terminal})
 
+		// %nonassoc 在优先级相同的位置留下的错误动作与没有动作相同，按照语法错误处理
 		action, ok := p.ActionTable[state][terminal]
-		if !ok {
-			// 如果没有找到动作，打印错误消息并退出
-			return fmt.Errorf("解析错误：无法找到状态 %d 和符号 %s 的动作\n", state, terminal)
+		if !ok || action.ActionType == ERROR {
+			// 如果没有找到动作，文法中没有错误产生式时打印错误消息并退出
+			syntaxErr := p.newSyntaxError(state, token, previous, terminal, p.TokenStack)
+
+			// 开启了恐慌模式并且错误产生式无法处理这个错误时，使用恐慌模式恢复
+			if p.Panic != nil && !injected && p.recovery.shifts != RECOVERY_SHIFTS && !p.canRecoverWithErrorProductions() {
+				// 与错误产生式相同，恢复之后还没有成功移入足够的 Token 时认为是连锁错误，不重复报告
+				if panicShifts == 0 {
+					p.trace(TraceEvent{Kind: TRACE_ERROR, Step: cnt, State: state, Token: token.Value, Terminal: terminal, Recovery: true, Message: syntaxErr.Error()})
+					p.SyntaxErrors = append(p.SyntaxErrors, syntaxErr)
+					if p.tooManyErrors() {
+						return p.panicSummary(fmt.Sprintf("语法错误达到上限 %d 处，停止分析", p.Panic.MaxErrors))
+					}
+				}
+				var recovered bool
+				// 恢复之后一个 Token 都没有移入就再次出错时，当前 Token 必须丢弃
+				if token, recovered, err = p.panicRecover(l, token, cnt, panicShifts == RECOVERY_SHIFTS); err != nil {
+					return err
+				}
+				if !recovered {
+					return p.panicSummary("到达输入末尾仍然无法从语法错误中恢复")
+				}
+				panicShifts = RECOVERY_SHIFTS
+				continue
+			}
+			if !p.canRecover() || injected {
+				fatal := p.fatalSyntaxError(syntaxErr)
+				p.trace(TraceEvent{Kind: TRACE_ERROR, Step: cnt, State: state, Token: token.Value, Terminal: terminal, Message: fatal.Error()})
+				return fatal
+			}
+
+			// 刚刚移入 error，当前 Token 还不能接在后面，丢弃它继续尝试
+			if p.recovery.shifts == RECOVERY_SHIFTS {
+				if terminal == TERMINATE_SYMBOL {
+					fatal := p.fatalSyntaxError(syntaxErr)
+					p.trace(TraceEvent{Kind: TRACE_ERROR, Step: cnt, State: state, Token: token.Value, Terminal: terminal, Message: fatal.Error()})
+					return fatal
+				}
+				p.trace(TraceEvent{Kind: TRACE_DISCARD, Step: cnt, State: state, Token: token.Value, Terminal: terminal})
+				p.recovery.skipped = append(p.recovery.skipped, token)
+				readNextToken = true
+				continue
+			}
+
+			// 上一次恢复之后还没有成功移入足够的 Token，不重复报告
+			if p.recovery.shifts == 0 {
+				p.trace(TraceEvent{Kind: TRACE_ERROR, Step: cnt, State: state, Token: token.Value, Terminal: terminal, Recovery: true, Message: syntaxErr.Error()})
+				p.SyntaxErrors = append(p.SyntaxErrors, syntaxErr)
+				p.recovery.token = token
+			}
+
+			// 弹出状态直到可以处理 error，然后把 error 作为当前的展望符
+			if !p.popToErrorState() {
+				fatal := p.fatalSyntaxError(syntaxErr)
+				p.trace(TraceEvent{Kind: TRACE_ERROR, Step: cnt, State: state, Token: token.Value, Terminal: terminal, Message: fatal.Error()})
+				return fatal
+			}
+			injected = true
+			continue
 		}
 
-		fmt.Printf("动作类别: %s 期望下一步状态: %d\n", action.ActionType, action.Number)
+		p.trace(TraceEvent{Kind: TRACE_ACTION, Step: cnt, State: state, Token: token.Value, Terminal: terminal, Action: action})
+		if p.Coverage != nil {
+			p.Coverage.useAction(state, terminal)
+		}
 
 		switch action.ActionType {
 		case SHIFT:
 			// 移入操作：将 Token 和新状态推入栈中
 			p.StateStack = append(p.StateStack, action.Number)
-			p.TokenStack = append(p.TokenStack, consts.Symbol(token.Value))
-			fmt.Printf("执行移入操作\n")
+			if injected {
+				// 移入 error 之后，原来的 Token 重新成为展望符
+				p.pushSymbol(consts.Symbol(ERROR_TERMINAL), Value{})
+				p.trace(TraceEvent{Kind: TRACE_SHIFT, Step: cnt, State: action.Number, Token: string(ERROR_TERMINAL), Terminal: ERROR_TERMINAL, Recovery: true})
+				injected = false
+				p.recovery.shifts = RECOVERY_SHIFTS
+				// 连锁错误也是一次新的恢复，上一次恢复的错误产生式已经在展望符为 error 时规约，错误节点只记录这一次丢弃的 Token
+				p.recovery.skipped = nil
+				break
+			}
+			p.pushSymbol(consts.Symbol(token.Value), TokenValue(token))
+			previous = token
+			p.trace(TraceEvent{Kind: TRACE_SHIFT, Step: cnt, State: action.Number, Token: token.Value, Terminal: terminal})
 			readNextToken = true
+			if panicShifts > 0 {
+				panicShifts--
+			}
+			if p.recovery.shifts > 0 {
+				p.recovery.shifts--
+			}
 			break
 		case REDUCE:
 			// 规约操作：使用产生式规约，并将相应的符号数从栈中弹出
 			production := p.Grammar.Productions[action.Number]
-			fmt.Printf("使用产生式 %v -> %v 规约\n", production.Head, production.Body)
+			p.trace(TraceEvent{Kind: TRACE_REDUCE, Step: cnt, State: state, Terminal: terminal, Production: action.Number, Head: production.Head, Body: production.Body})
 
-			// 处理规约操作
-			if err := production.Handler(p); err != nil {
+			// 调用处理函数，并将产生式右侧的符号从符号栈中弹出，将产生式左侧的符号推入符号栈中
+			if err := p.applyReduction(action.Number); err != nil {
 				return err
 			}
-
-			// 当我们执行归约操作时，需要将产生式右侧的符号从栈中弹出，并将产生式左侧的符号推入栈中
-			if !(len(production.Body) == 1 && production.Body[0] == EPSILON) {
-				// fmt.Printf("开始弹出%d 个: ", len(production.Body))
-				for range production.Body {
-					// fmt.Printf("%v ", p.TokenStack[len(p.TokenStack)-1])
-					p.TokenStack = p.TokenStack[:len(p.TokenStack)-1]
-					p.StateStack = p.StateStack[:len(p.StateStack)-1]
-				}
-				// fmt.Println()
-			}
+			p.StateStack = p.StateStack[:len(p.StateStack)-len(rhs(production.Body))]
 
 			var gotoState int
-			// 将产生式头部推入栈中
-			p.TokenStack = append(p.TokenStack, production.Head)
 			topState := p.StateStack[len(p.StateStack)-1]
 			gotoState, ok = p.GotoTable[topState][production.Head]
 			if !ok {
@@ -137,18 +232,50 @@ func (p *Parser) Parse(l *lexer.Lexer) error {
 			}
 
 			// 将产生式头部和转移后的状态推入栈中
-			fmt.Printf("转移状态到 %d\n", gotoState)
+			p.trace(TraceEvent{Kind: TRACE_GOTO, Step: cnt, State: topState, Head: production.Head, Target: gotoState})
 			p.StateStack = append(p.StateStack, gotoState)
 			break
 		case ACCEPT:
 			// 接受操作：成功完成分析
-			fmt.Println("\n\n>>> 成功完成解析.")
+			p.trace(TraceEvent{Kind: TRACE_ACCEPT, Step: cnt, State: state})
 			p.SymbolTable.ExitScope() // 确保退出全局作用域
-			return nil
+			return p.errorSummary()
+		}
+	}
+}
+
+// beginParse 在分析开始之前初始化分析栈，Parse、ParseLRk 与 Replay 共用
+func (p *Parser) beginParse(start int) {
+	p.StateStack = []int{start}                                     // 状态栈
+	p.TokenStack = []consts.Symbol{consts.Symbol(TERMINATE_SYMBOL)} // 预留一个空位，用于处理状态 0 的转移
+	p.ValueStack = []Value{{}}                                      // 与符号栈的空位对应
+	p.SymbolTable.EnterScope()                                      // 进入一个新的作用域
+	p.SyntaxErrors, p.ErrorNodes, p.recovery = nil, nil, recovery{}
+	p.breakLabels = nil // 上一次分析出错时可能留下没有弹出的 break 标签
+	if p.Attributes != nil {
+		p.Attributes.reset()
+	}
+}
 
-		case ERROR:
-			// 错误操作：打印错误并退出
-			return fmt.Errorf("解析错误: %s\n", action.ActionType)
+// applyReduction 执行一次规约的语义部分：调用产生式的处理函数，然后在符号栈和语义值栈中用产生式头部和它的值替换产生式体
+// Parse 与 Replay 共用这一步，保证处理函数收到的值完全相同。属性文法按照符号栈的长度对齐，所以在弹出之前计算
+func (p *Parser) applyReduction(index int) error {
+	production := p.Grammar.Productions[index]
+	n := len(rhs(production.Body))
+	value, err := p.callHandler(production, handlerArgs(production.Body, p.ValueStack[len(p.ValueStack)-n:]))
+	if err != nil {
+		return err
+	}
+	if p.Coverage != nil {
+		p.Coverage.Reductions[index]++
+	}
+	// 使用属性文法时，同时建立语法树节点并计算属性
+	if p.Attributes != nil {
+		if err := p.Attributes.reduce(p, index); err != nil {
+			return err
 		}
 	}
+	p.popSymbols(n)
+	p.pushSymbol(production.Head, value)
+	return nil
 }
@@ -0,0 +1,125 @@
+// precedence.go
+// 运算符优先级和结合性：与 yacc 相同，用来解决移入-规约冲突
+
+package parser
+
+import (
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// Assoc 表示运算符的结合性
+type Assoc int
+
+const (
+	AssocLeft     Assoc = iota // 左结合，优先级相同时规约
+	AssocRight                 // 右结合，优先级相同时移入
+	AssocNonAssoc              // 不可结合，优先级相同时报错
+	AssocNone                  // 没有结合性，只有优先级（%precedence），优先级相同时无法解决冲突
+)
+
+// String 返回结合性的名字
+func (a Assoc) String() string {
+	switch a {
+	case AssocRight:
+		return "右结合"
+	case AssocNonAssoc:
+		return "不可结合"
+	case AssocNone:
+		return "无结合性"
+	}
+	return "左结合"
+}
+
+// Precedence 表示终结符的优先级，Level 越大优先级越高
+type Precedence struct {
+	Level int
+	Assoc Assoc
+}
+
+// productionPrecedence 返回产生式的优先级
+// 产生式通过 %prec 指定了终结符时使用这个终结符的优先级，否则使用产生式体中最后一个有优先级的终结符的优先级
+func (g *Grammar) productionPrecedence(index int) (Precedence, bool) {
+	if terminal, ok := g.ProductionPrec[index]; ok {
+		prec, ok := g.Precedence[terminal]
+		return prec, ok
+	}
+	body := rhs(g.Productions[index].Body)
+	for i := len(body) - 1; i >= 0; i-- {
+		if !g.IsTerminal(body[i]) {
+			continue
+		}
+		if prec, ok := g.Precedence[consts.Terminal(body[i])]; ok {
+			return prec, true
+		}
+	}
+	return Precedence{}, false
+}
+
+// resolveConflict 使用优先级和结合性解决移入-规约冲突，返回应该留在表中的动作
+/*
+	与 yacc 的规则相同：
+	1. 展望符的优先级高于产生式的优先级时移入，低于时规约
+	2. 优先级相同时，左结合规约，右结合移入，不可结合时这个位置成为错误动作
+	3. 展望符或产生式没有优先级、或者不是移入-规约冲突时无法解决，返回 false，由调用者记录冲突；
+	   优先级相同而展望符没有结合性（%precedence）时也无法解决，与 Bison 一样作为冲突报告
+	已经因为不可结合成为错误动作的位置不再改变。
+*/
+func (p *Parser) resolveConflict(state int, lookahead consts.Terminal, existing, incoming ActionEntry) (ActionEntry, bool) {
+	if existing.ActionType == ERROR {
+		return existing, true
+	}
+	shift, reduce := existing, incoming
+	if shift.ActionType != SHIFT {
+		shift, reduce = incoming, existing
+	}
+	if shift.ActionType != SHIFT || reduce.ActionType != REDUCE {
+		return ActionEntry{}, false
+	}
+	termPrec, ok := p.Grammar.Precedence[lookahead]
+	if !ok {
+		return ActionEntry{}, false
+	}
+	prodPrec, ok := p.Grammar.productionPrecedence(reduce.Number)
+	if !ok {
+		return ActionEntry{}, false
+	}
+
+	var chosen ActionEntry
+	switch {
+	case termPrec.Level > prodPrec.Level:
+		chosen = shift
+	case termPrec.Level < prodPrec.Level:
+		chosen = reduce
+	case termPrec.Assoc == AssocLeft:
+		chosen = reduce
+	case termPrec.Assoc == AssocRight:
+		chosen = shift
+	case termPrec.Assoc == AssocNone:
+		return ActionEntry{}, false
+	default:
+		chosen = ActionEntry{ActionType: ERROR}
+	}
+	p.addResolved(state, lookahead, shift, reduce, chosen)
+	return chosen, true
+}
+
+// addResolved 在 p.Resolved 中记录一次通过优先级解决的冲突，同一个位置只记录一次
+// 同一个状态中展望符不同的多个项会反复遇到同一个冲突；构建分析表时不输出，由调用者决定是否报告
+func (p *Parser) addResolved(state int, lookahead consts.Terminal, shift, reduce, chosen ActionEntry) {
+	for _, c := range p.Resolved {
+		if c.State == state && c.Lookahead == lookahead {
+			return
+		}
+	}
+	rejected := reduce
+	if chosen == reduce {
+		rejected = shift
+	}
+	p.Resolved = append(p.Resolved, Conflict{
+		State:     state,
+		Lookahead: lookahead,
+		Kind:      SHIFT + "/" + REDUCE,
+		Chosen:    chosen,
+		Rejected:  rejected,
+	})
+}
@@ -0,0 +1,106 @@
+// recovery.go
+// 基于错误产生式的语法错误恢复（与 yacc 的做法相同）
+
+package parser
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// RECOVERY_SHIFTS 表示错误恢复之后需要成功移入多少个 Token 才会报告新的语法错误
+// 在此之前发生的错误被认为是上一个错误引起的连锁错误，只丢弃 Token 而不报告
+const RECOVERY_SHIFTS = 3
+
+// ErrorNode 表示通过错误产生式规约或者恐慌模式恢复得到的错误节点
+type ErrorNode struct {
+	Symbol  consts.Symbol // 错误产生式的头部或者同步非终结符，例如 stmt、block
+	Token   lexer.Token   // 引发语法错误的 Token
+	Skipped []lexer.Token // 恢复过程中被丢弃的 Token
+}
+
+// String 返回错误节点的可读描述
+func (n ErrorNode) String() string {
+	skipped := make([]string, len(n.Skipped))
+	for i, token := range n.Skipped {
+		skipped[i] = token.Value
+	}
+	return fmt.Sprintf("%s 错误节点：第 %d 行第 %d 列的 %s 处出错，丢弃了 %v", n.Symbol, n.Token.Line, n.Token.Column, n.Token.Value, skipped)
+}
+
+// recovery 记录错误恢复的状态
+type recovery struct {
+	shifts  int           // 还需要移入多少个 Token 才会报告新的错误，为 0 表示没有在恢复
+	token   lexer.Token   // 最近一次报告的错误对应的 Token
+	skipped []lexer.Token // 最近一次恢复中被丢弃的 Token
+}
+
+// recordErrorNode 在错误产生式的处理函数中调用，记录一个错误节点
+func (p *Parser) recordErrorNode(symbol consts.Symbol) {
+	p.ErrorNodes = append(p.ErrorNodes, ErrorNode{
+		Symbol:  symbol,
+		Token:   p.recovery.token,
+		Skipped: append([]lexer.Token{}, p.recovery.skipped...),
+	})
+}
+
+// popToErrorState 弹出状态栈，直到栈顶状态可以处理 error 终结符
+// 返回 false 表示没有任何状态可以处理 error，无法恢复
+func (p *Parser) popToErrorState() bool {
+	for len(p.StateStack) > 0 {
+		state := p.StateStack[len(p.StateStack)-1]
+		if _, ok := p.ActionTable[state][ERROR_TERMINAL]; ok {
+			return true
+		}
+		p.trace(TraceEvent{Kind: TRACE_POP, State: state, Token: string(p.TokenStack[len(p.TokenStack)-1])})
+		p.StateStack = p.StateStack[:len(p.StateStack)-1]
+		p.popSymbols(1)
+		p.dropLabels()
+	}
+	return false
+}
+
+// fatalSyntaxError 返回无法从语法错误中恢复时 Parse 返回的错误
+// 错误恢复开始之后，状态栈已经被弹出，展望符可能是 error 或者恢复时跳过的 Token，由此得到的错误描述不是输入中真正的错误，
+// 所以返回恢复之前报告的第一个语法错误；还没有报告过语法错误时返回 current
+func (p *Parser) fatalSyntaxError(current *SyntaxError) error {
+	if len(p.SyntaxErrors) > 0 {
+		return p.SyntaxErrors[0]
+	}
+	return current
+}
+
+// errorSummary 在分析结束时汇总恢复过的语法错误
+// 恢复时被丢弃的 Token 没有生成代码，回填的跳转也可能指向不存在的位置，所以发生过错误时不保留三地址码
+func (p *Parser) errorSummary() error {
+	if len(p.SyntaxErrors) == 0 {
+		return nil
+	}
+	p.ThreeAddress = nil
+	return fmt.Errorf("解析完成，但发现了 %d 处语法错误\n", len(p.SyntaxErrors))
+}
+
+// WithErrorProductions 在文法中加入 ERROR_PRODUCTIONS，返回新的文法
+/*
+	错误产生式和 error 终结符默认不在课程文法中，Parse 遇到第一个语法错误就停止。加入之后 Parse 会通过错误产生式恢复并继续分析，
+	所有错误记录在 SyntaxErrors 中，分析结束时返回错误的数量并清空生成的三地址码。错误产生式追加在最后，原有产生式的编号保持不变。
+	需要在构建状态集合之前调用，例如 NewParserWithGrammar(NewParser().Grammar.WithErrorProductions())
+*/
+func (g *Grammar) WithErrorProductions() *Grammar {
+	grammar := g.derive(append(slices.Clone(g.Productions), ERROR_PRODUCTIONS...))
+	if !slices.Contains(grammar.Terminals, ERROR_TERMINAL) {
+		grammar.Terminals = append(slices.Clone(g.Terminals), ERROR_TERMINAL)
+	}
+	grammar.Precedence, grammar.ProductionPrec = g.Precedence, g.ProductionPrec
+	return grammar
+}
+
+// canRecover 判断文法中是否有错误产生式
+func (p *Parser) canRecover() bool {
+	return slices.ContainsFunc(p.Grammar.Productions, func(prod Production) bool {
+		return slices.Contains(prod.Body, consts.Symbol(ERROR_TERMINAL))
+	})
+}
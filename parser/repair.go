@@ -0,0 +1,431 @@
+// repair.go
+// 最小代价的局部错误修复（Burke–Fisher 方法）：在语法错误处尝试插入、删除或替换少量 Token，选出能让分析继续下去的修复
+
+package parser
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// RepairKind 表示修复中一次编辑的类型
+type RepairKind string
+
+const (
+	REPAIR_INSERT     RepairKind = "insert"     // 在 Token 之前插入一个终结符
+	REPAIR_DELETE     RepairKind = "delete"     // 删除 Token
+	REPAIR_SUBSTITUTE RepairKind = "substitute" // 把 Token 替换为另一个终结符
+)
+
+// RepairOptions 表示搜索修复时的限制
+type RepairOptions struct {
+	MaxEdits int // 一次修复最多包含几次编辑
+	Window   int // 修复之后至少还要能继续移入多少个原来的 Token，到达输入末尾并接受时也算成功
+}
+
+// DEFAULT_REPAIR_OPTIONS 是默认的搜索限制
+var DEFAULT_REPAIR_OPTIONS = RepairOptions{MaxEdits: 2, Window: 3}
+
+// RepairEdit 表示修复中的一次编辑
+type RepairEdit struct {
+	Kind     RepairKind
+	Terminal consts.Terminal // 插入或替换成的终结符，删除时为空
+	Token    lexer.Token     // 被删除或替换的 Token，插入时是插入位置之后的 Token
+	Previous lexer.Token     // 插入位置之前的 Token，用来在源代码中定位插入的位置，在输入开头时为零值
+}
+
+// Repair 表示对一处语法错误的修复
+type Repair struct {
+	Error *SyntaxError // 修复的语法错误，位置和期望的终结符与 Parse 报告的相同
+	Edits []RepairEdit // 按照在输入中的顺序排列
+}
+
+// String 返回修复的可读描述，例如 第 3 行第 5 列：在 'x' 之前插入 ';'
+func (r Repair) String() string {
+	edits := make([]string, len(r.Edits))
+	for i, edit := range r.Edits {
+		edits[i] = edit.String()
+	}
+	return fmt.Sprintf("第 %d 行第 %d 列：%s", r.Error.Line, r.Error.Column, strings.Join(edits, "，然后"))
+}
+
+// String 返回编辑的可读描述
+func (e RepairEdit) String() string {
+	switch e.Kind {
+	case REPAIR_INSERT:
+		if e.Token.Type == lexer.EOF {
+			return spaced("在输入末尾插入", describeTerminal(e.Terminal))
+		}
+		return spaced(fmt.Sprintf("在 '%s' 之前插入", e.Token.Value), describeTerminal(e.Terminal))
+	case REPAIR_DELETE:
+		return fmt.Sprintf("删除多余的 '%s'", e.Token.Value)
+	case REPAIR_SUBSTITUTE:
+		return spaced(fmt.Sprintf("把 '%s' 替换为", e.Token.Value), describeTerminal(e.Terminal))
+	}
+	return string(e.Kind)
+}
+
+// describeTerminal 返回终结符的可读形式，标识符和常量这类终结符使用它们的名称
+func describeTerminal(terminal consts.Terminal) string {
+	switch terminal {
+	case "id":
+		return "标识符"
+	case "num":
+		return "整数"
+	case "real":
+		return "实数"
+	case "basic":
+		return "类型名"
+	}
+	return quoteTerminal(terminal)
+}
+
+// REPAIR_PLACEHOLDERS 是自动修复时插入到源代码中的文本，其他终结符直接插入它本身
+var REPAIR_PLACEHOLDERS = map[consts.Terminal]string{
+	"id":    "tmp",
+	"num":   "0",
+	"real":  "0.0",
+	"basic": "int",
+}
+
+// REPAIR_PREFERRED 是同样有效的修复中优先尝试的终结符：先补全缺少的分隔符和右括号，缺少操作数时使用标识符和整数
+var REPAIR_PREFERRED = []consts.Terminal{";", ")", "]", "}", "id", "num"}
+
+// repairText 返回插入或替换成终结符时写入源代码的文本
+func repairText(terminal consts.Terminal) string {
+	if text, ok := REPAIR_PLACEHOLDERS[terminal]; ok {
+		return text
+	}
+	return string(terminal)
+}
+
+// repairLexable 判断终结符写入源代码的文本能否被词法分析器识别为这个终结符本身
+// 不能识别的终结符（例如 error，词法分析器会把它识别为标识符）写回源代码之后会变成别的东西，所以不用来修复
+func repairLexable(terminal consts.Terminal) bool {
+	tokens, err := lexAll(lexer.NewLexer(strings.NewReader(repairText(terminal))))
+	return err == nil && len(tokens) == 2 && TokenToTerminal(tokens[0]) == terminal
+}
+
+// advance 在状态栈的副本上读入终结符：先完成所有规约，再移入
+// 返回新的状态栈；ok 为 false 表示这个终结符不能出现在这里，accepted 为 true 表示分析已经接受
+func (p *Parser) advance(stack []int, terminal consts.Terminal) (next []int, ok, accepted bool) {
+	stack = slices.Clone(stack)
+	for {
+		action, found := p.ActionTable[stack[len(stack)-1]][terminal]
+		if !found {
+			return nil, false, false
+		}
+		switch action.ActionType {
+		case SHIFT:
+			return append(stack, action.Number), true, false
+		case ACCEPT:
+			return stack, true, true
+		case REDUCE:
+			production := p.Grammar.Productions[action.Number]
+			stack = stack[:len(stack)-len(rhs(production.Body))]
+			target, found := p.GotoTable[stack[len(stack)-1]][production.Head]
+			if !found {
+				return nil, false, false
+			}
+			stack = append(stack, target)
+		default:
+			return nil, false, false
+		}
+	}
+}
+
+// validate 从 pos 开始用原来的终结符继续分析，返回成功移入的终结符个数，接受时返回 -1
+func (p *Parser) validate(stack []int, terminals []consts.Terminal, pos int) int {
+	consumed := 0
+	for ; pos < len(terminals); pos++ {
+		next, ok, accepted := p.advance(stack, terminals[pos])
+		if !ok {
+			return consumed
+		}
+		if accepted {
+			return -1
+		}
+		stack = next
+		consumed++
+	}
+	return consumed
+}
+
+// repairCandidate 是搜索中的一个部分修复：已经应用了 edits，分析栈和输入位置是应用之后的
+type repairCandidate struct {
+	stack []int
+	pos   int
+	edits []RepairEdit
+}
+
+// findRepair 在语法错误处搜索修复
+/*
+	按照编辑次数从少到多搜索，编辑都从出错的 Token 开始连续进行：插入不移动输入位置，删除和替换各消耗一个 Token。
+	插入和替换只尝试当前栈顶状态在 Action 表中有动作的终结符，所以每一次编辑之后分析栈都是合法的。
+	编辑完成之后用原来的输入继续分析，至少移入 Window 个 Token（或者接受）才算有效；
+	同样次数的有效修复中选择继续分析得最远的一个；仍然相同时选择删除和替换（丢掉了原来的 Token）最少的，也就是插入优先，
+	例如 [插入, 插入] 优先于 [插入, 删除]；再相同时按照插入、删除、替换的顺序，终结符先按照 REPAIR_PREFERRED、再按照文法中声明的顺序选择。
+*/
+func (p *Parser) findRepair(stack []int, tokens []lexer.Token, terminals []consts.Terminal, pos int, options RepairOptions) (repairCandidate, bool) {
+	frontier := []repairCandidate{{stack: stack, pos: pos}}
+	for edits := 1; edits <= options.MaxEdits; edits++ {
+		var next []repairCandidate
+		var best repairCandidate
+		bestScore, bestDiscarded := -1, 0
+		for _, c := range frontier {
+			for _, expanded := range p.expandRepair(c, tokens, terminals) {
+				score := p.validate(expanded.stack, terminals, expanded.pos)
+				if score == -1 {
+					score = len(terminals) // 接受的修复总是最好的
+				}
+				if score >= options.Window || score == len(terminals) {
+					discarded := expanded.discarded()
+					if score > bestScore || score == bestScore && discarded < bestDiscarded {
+						best, bestScore, bestDiscarded = expanded, score, discarded
+					}
+				}
+				next = append(next, expanded)
+			}
+		}
+		if bestScore >= 0 {
+			return best, true
+		}
+		frontier = next
+	}
+	return repairCandidate{}, false
+}
+
+// discarded 返回修复中删除和替换的次数，即丢掉了多少个原来的 Token
+func (c repairCandidate) discarded() int {
+	n := 0
+	for _, edit := range c.edits {
+		if edit.Kind != REPAIR_INSERT {
+			n++
+		}
+	}
+	return n
+}
+
+// expandRepair 在部分修复的基础上再进行一次编辑
+func (p *Parser) expandRepair(c repairCandidate, tokens []lexer.Token, terminals []consts.Terminal) []repairCandidate {
+	var expanded []repairCandidate
+	add := func(stack []int, pos int, edit RepairEdit) {
+		edits := append(slices.Clone(c.edits), edit)
+		expanded = append(expanded, repairCandidate{stack: stack, pos: pos, edits: edits})
+	}
+	var previous lexer.Token
+	if c.pos > 0 {
+		previous = tokens[c.pos-1]
+	}
+	candidates := slices.DeleteFunc(p.expectedTerminals(c.stack[len(c.stack)-1]), func(t consts.Terminal) bool {
+		return t == TERMINATE_SYMBOL || !repairLexable(t)
+	})
+	slices.SortStableFunc(candidates, func(a, b consts.Terminal) int {
+		rank := func(t consts.Terminal) int {
+			if i := slices.Index(REPAIR_PREFERRED, t); i >= 0 {
+				return i
+			}
+			return len(REPAIR_PREFERRED)
+		}
+		return rank(a) - rank(b)
+	})
+
+	for _, terminal := range candidates {
+		if stack, ok, _ := p.advance(c.stack, terminal); ok {
+			add(stack, c.pos, RepairEdit{Kind: REPAIR_INSERT, Terminal: terminal, Token: tokens[c.pos], Previous: previous})
+		}
+	}
+	if terminals[c.pos] == TERMINATE_SYMBOL {
+		return expanded
+	}
+	add(c.stack, c.pos+1, RepairEdit{Kind: REPAIR_DELETE, Token: tokens[c.pos]})
+	for _, terminal := range candidates {
+		if terminal == terminals[c.pos] {
+			continue
+		}
+		if stack, ok, _ := p.advance(c.stack, terminal); ok {
+			add(stack, c.pos+1, RepairEdit{Kind: REPAIR_SUBSTITUTE, Terminal: terminal, Token: tokens[c.pos]})
+		}
+	}
+	return expanded
+}
+
+// SuggestRepairs 读取全部输入，只用分析表识别（不调用处理函数），为每一处语法错误给出最小代价的修复
+// 修复之后继续识别后面的输入，所以一次可以给出多处修复；某处错误在限制之内找不到修复时返回已经找到的修复和这个错误
+func (p *Parser) SuggestRepairs(l *lexer.Lexer, options RepairOptions) ([]Repair, error) {
+	var tokens []lexer.Token
+	for {
+		token, err := l.NextToken()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+		if token.Type == lexer.EOF {
+			break
+		}
+	}
+	terminals := make([]consts.Terminal, len(tokens))
+	for i, token := range tokens {
+		terminals[i] = TokenToTerminal(token)
+	}
+
+	// 只用分析表识别时不做规约，symbols 中是所有移入的 Token，用来描述语法错误（例如统计没有闭合的括号）
+	var repairs []Repair
+	stack := []int{0}
+	symbols := []consts.Symbol{consts.Symbol(TERMINATE_SYMBOL)}
+	for pos := 0; pos < len(tokens); {
+		next, ok, accepted := p.advance(stack, terminals[pos])
+		if accepted {
+			return repairs, nil
+		}
+		if ok {
+			stack = next
+			symbols = append(symbols, consts.Symbol(tokens[pos].Value))
+			pos++
+			continue
+		}
+
+		var previous lexer.Token
+		if pos > 0 {
+			previous = tokens[pos-1]
+		}
+		syntaxErr := p.newSyntaxError(stack[len(stack)-1], tokens[pos], previous, terminals[pos], symbols)
+		found, ok := p.findRepair(stack, tokens, terminals, pos, options)
+		if !ok {
+			return repairs, syntaxErr
+		}
+		repairs = append(repairs, Repair{Error: syntaxErr, Edits: found.edits})
+		for _, edit := range found.edits {
+			if edit.Kind != REPAIR_DELETE {
+				symbols = append(symbols, consts.Symbol(repairText(edit.Terminal)))
+			}
+		}
+		stack, pos = found.stack, found.pos
+	}
+	return repairs, nil
+}
+
+// textEdit 表示对源代码的一次修改，位置是从 1 开始的行号和按字符计算的列号，范围包含 start 不包含 end
+type textEdit struct {
+	line, start, end int
+	text             string
+	order            int // 同一位置有多次插入时，按照编辑的顺序排列
+}
+
+// ApplyRepairs 把修复应用到源代码上，返回修改之后的源代码
+/*
+	删除和替换修改 Token 本身所在的位置。插入的 ;、)、] 紧跟在前一个 Token 之后，这样缺少的 ; 会加在上一行的末尾而不是下一行的开头；
+	其他终结符插入在后一个 Token 之前并用空格隔开，在输入末尾时加在最后一个 Token 之后。
+	在同一个位置连续插入的一组终结符使用同一个插入点：只要其中有一个需要插在后一个 Token 之前，整组都插在那里，
+	否则例如 ( 1 + ; 插入 id 和 ) 时，) 会跑到前一个 Token 之后、id 的前面。
+	标识符和常量没有确定的文本，插入时使用 REPAIR_PLACEHOLDERS 中的占位文本。
+*/
+func ApplyRepairs(source string, repairs []Repair) string {
+	var edits []textEdit
+	for _, repair := range repairs {
+		for i := 0; i < len(repair.Edits); {
+			edit := repair.Edits[i]
+			if edit.Kind != REPAIR_INSERT {
+				te := textEdit{order: len(edits), line: edit.Token.Line, start: tokenStart(edit.Token), end: edit.Token.Column + 1}
+				if edit.Kind == REPAIR_SUBSTITUTE {
+					te.text = repairText(edit.Terminal)
+				}
+				edits = append(edits, te)
+				i++
+				continue
+			}
+
+			// 插入在同一个 Token 之前的连续插入是一组，先决定整组的插入点
+			j := i + 1
+			for j < len(repair.Edits) && repair.Edits[j].Kind == REPAIR_INSERT && repair.Edits[j].Token == edit.Token {
+				j++
+			}
+			group := repair.Edits[i:j]
+			attach := edit.Previous.Line > 0 && !slices.ContainsFunc(group, func(e RepairEdit) bool {
+				return !slices.Contains([]string{";", ")", "]"}, repairText(e.Terminal))
+			})
+			for k, e := range group {
+				edits = append(edits, insertEdit(e, len(edits), attach, k == 0))
+			}
+			i = j
+		}
+	}
+
+	// 从后往前修改，前面的位置不受影响；同一位置的插入倒序进行，最后的结果仍然按照编辑的顺序排列
+	sort.SliceStable(edits, func(i, j int) bool {
+		a, b := edits[i], edits[j]
+		if a.line != b.line {
+			return a.line > b.line
+		}
+		if a.start != b.start {
+			return a.start > b.start
+		}
+		return a.order > b.order
+	})
+	lines := strings.SplitAfter(source, "\n")
+	for _, edit := range edits {
+		if edit.line < 1 || edit.line > len(lines) {
+			continue
+		}
+		line := []rune(lines[edit.line-1])
+		start, end := min(edit.start-1, len(line)), min(edit.end-1, len(line))
+		if start < 0 {
+			continue
+		}
+		lines[edit.line-1] = string(line[:start]) + edit.text + string(line[end:])
+	}
+	return strings.Join(lines, "")
+}
+
+// tokenStart 返回 Token 第一个字符所在的列，Token.Column 是最后一个字符所在的列
+func tokenStart(token lexer.Token) int {
+	return token.Column - len([]rune(token.Value)) + 1
+}
+
+// insertEdit 把一次插入转换为文本修改，attach 为 true 时紧跟在前一个 Token 之后，否则插在后一个 Token 之前
+// first 表示这是同一组插入中的第一个，只有它需要与紧挨着的前一个 Token 隔开
+func insertEdit(edit RepairEdit, order int, attach, first bool) textEdit {
+	te := textEdit{order: order}
+	text := repairText(edit.Terminal)
+	switch {
+	case attach:
+		te.line, te.start = edit.Previous.Line, edit.Previous.Column+1
+	case edit.Token.Type == lexer.EOF:
+		te.line, te.start = edit.Previous.Line, edit.Previous.Column+1
+		text = " " + text
+	default:
+		start := tokenStart(edit.Token)
+		te.line, te.start = edit.Token.Line, start
+		text += " "
+		// 前一个 Token 紧挨着时也要隔开，例如 int; 修复为 int tmp ;
+		if first && edit.Previous.Line == edit.Token.Line && edit.Previous.Column == start-1 {
+			text = " " + text
+		}
+	}
+	te.end, te.text = te.start, text
+	return te
+}
+
+// CheckRepaired 对修复之后的源代码重新进行词法分析，并只用分析表识别，确认修复确实消除了所有语法错误
+// 修复按照 Token 搜索，写回源代码时可能因为占位文本或者插入的位置得到不同的 Token，写入文件之前需要检查
+func (p *Parser) CheckRepaired(source string) error {
+	tokens, err := lexAll(lexer.NewLexer(strings.NewReader(source)))
+	if err != nil {
+		return err
+	}
+	return p.Recognize(tokens)
+}
+
+// PrintRepairs 把每一处语法错误和建议的修复写入 w
+func PrintRepairs(w io.Writer, repairs []Repair) {
+	for _, repair := range repairs {
+		fmt.Fprint(w, repair.Error)
+		fmt.Fprintf(w, "  建议：%s\n", repair)
+	}
+}
@@ -2,455 +2,503 @@ package parser
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
-
-	"github.com/ozline/CoursePractice-GoCompiler/consts"
 )
 
+// 处理函数的 args 与 PRODUCTIONS 中写出的产生式体一一对应，args[i] 是第 i 个符号的值，返回值是产生式头部的值
+// 终结符的值由 TokenValue 得到，它的 Addr 就是 Token 的字面值
+
 // program' -> program 增广文法，不需要编写函数
-func genARGUMENTED_PRODUCTION(p *Parser) error { return nil }
+func genARGUMENTED_PRODUCTION(p *Parser, args []Value) (Value, error) { return args[0], nil }
 
 // program → block
-func genProgram(p *Parser) error {
+func genProgram(p *Parser, args []Value) (Value, error) {
 	// 这个规则不需要生成任何中间代码，因为它只是一个开始符号
-	return nil
+	return args[0], nil
 }
 
 // block → { decls stmts }
-func genBlock(p *Parser) error {
+func genBlock(p *Parser, args []Value) (Value, error) {
 	// 这个规则不需要生成中间代码，因为它是一个结构性的规则
-	return nil
+	return Value{}, nil
 }
 
 // decls → decls decl
-func genDecls(p *Parser) error {
+func genDecls(p *Parser, args []Value) (Value, error) {
 	// 由于声明不涉及运行时计算，所以这里不生成中间代码
-	return nil
+	return Value{}, nil
 }
 
 // decls → ε
-func genDeclsEpsilon(p *Parser) error {
+func genDeclsEpsilon(p *Parser, args []Value) (Value, error) {
 	// 空规则，不生成任何代码
-	return nil
+	return Value{}, nil
 }
 
 // decl → type id;
-func genDecl(p *Parser) error {
-	// 获取变量类型
-	varName := p.TokenStack[len(p.TokenStack)-2]
-	// 使用暂存的类型信息来重新定义变量
-	if err := p.SymbolTable.DefineData(string(varName), string(p.LastType), p.LastSize); err != nil {
-		fmt.Println("定义符号时出错:", err)
+func genDecl(p *Parser, args []Value) (Value, error) {
+	// 类型和大小来自 type 的值，变量名来自 id 的值
+	varType, varName := args[0], args[1].Addr
+	if err := p.SymbolTable.DefineData(varName, varType.Type, varType.Size); err != nil {
+		p.traceSemantic("定义符号时出错: %v", err)
 	}
-	fmt.Printf("[符号表] 定义变量 %s 类型为 %s size:%d\n", varName, p.LastType, p.LastSize)
-
-	// 重置 lastType 和 lastSize
-	p.LastType = consts.Terminal("") // 重置类型为空
-	p.LastSize = 4                   // 默认大小为 4
-	return nil
+	p.traceSemantic("[符号表] 定义变量 %s 类型为 %s size:%d", varName, varType.Type, varType.Size)
+	return Value{}, nil
 }
 
 // type → type_array
-func genTypeArray(p *Parser) error {
-	// 这里不需要生成中间代码，因为genLocArrayFinal会处理具体的数组赋值
-	return nil
+func genTypeArray(p *Parser, args []Value) (Value, error) {
+	// 数组类型的值在 genTypeArrayFinal 中计算，这里直接传递
+	return args[0], nil
 }
 
 // type_array -> type[num]
-func genTypeArrayFinal(p *Parser) error {
-	arraySizeToken := p.TokenStack[len(p.TokenStack)-2]
-	arraySize, err := strconv.Atoi(string(arraySizeToken)) // 转换为整数
+func genTypeArrayFinal(p *Parser, args []Value) (Value, error) {
+	arraySize, err := strconv.Atoi(args[2].Addr) // 转换为整数
 	if err != nil {
-		return fmt.Errorf("[符号表] 数组大小转换错误")
+		return Value{}, fmt.Errorf("[符号表] 数组大小转换错误")
 	}
-	p.LastSize = arraySize
-	fmt.Println("[符号表] 触发数组类型定义， 数组大小为", arraySize)
-	return nil
+	p.traceSemantic("[符号表] 触发数组类型定义， 数组大小为 %d", arraySize)
+	return Value{Type: args[0].Type, Size: arraySize}, nil
 }
 
 // type → basic
-func genBasicType(p *Parser) error {
-	varType := p.TokenStack[len(p.TokenStack)-1]
-	p.LastType = consts.Terminal(varType)
-	fmt.Println("[符号表] 触发类型定义，该类型为", varType)
-	return nil
+func genBasicType(p *Parser, args []Value) (Value, error) {
+	varType := args[0].Addr
+	p.traceSemantic("[符号表] 触发类型定义，该类型为 %s", varType)
+	return Value{Type: varType}, nil // 基本类型的 Size 为 0，符号表据此区分变量和数组
 }
 
 // stmts -> stmts stmt
-func genStmts(p *Parser) error {
+func genStmts(p *Parser, args []Value) (Value, error) {
 	// 这部分没弄明白，问 LLM 的 OvO
 	// 由于 stmts -> stmts stmt 规则表示一个语句序列，我们这里可能不需要生成特定的代码。
 	// 通常，每个 stmt 在被解析时会自己生成必要的代码。
-	// 因此，这里返回 nil 表示没有错误，也不需要额外的操作。
-	return nil
+	// 因此，这里返回空值表示没有错误，也不需要额外的操作。
+	return Value{}, nil
 }
 
 // stmts -> ε
-func genStmtsEpsilon(p *Parser) error {
+func genStmtsEpsilon(p *Parser, args []Value) (Value, error) {
 	// 对于空语句（epsilon），同样不需要生成代码。
-	return nil
+	return Value{}, nil
 }
 
 // stmt -> loc=bool;
-func genStmt(p *Parser) error {
-	// 获取变量名
-	varName := p.LastName
-	// 获取变量值
-	varValue := p.TokenStack[len(p.TokenStack)-2]
-	fmt.Printf("[符号表] 将变量 %s 赋值为 %s\n", varName, varValue)
+func genStmt(p *Parser, args []Value) (Value, error) {
+	// 变量名是 loc 的值，变量值是 bool 计算结果所在的位置
+	varName := args[0].Addr
+	varValue := args[2].Addr
+	p.traceSemantic("[符号表] 将变量 %s 赋值为 %s", varName, varValue)
 
 	// 生成赋值代码
-	p.Emit(varName, "", string(varValue)) // 赋值操作
-	return nil
+	p.Emit(varName, "", varValue) // 赋值操作
+	return Value{}, nil
 }
 
 // stmt → if(bool) stmt
-func genStmtIf(p *Parser) error {
-	// 生成 if 语句的代码
-	condition := p.TokenStack[len(p.TokenStack)-3]
-	afterStmtLabel := p.NewLabel() // 创建新的标签，用于 if 之后的代码位置
-
-	// 生成条件为假时跳转的代码
-	p.Emit("ifFalse", string(condition), "goto", afterStmtLabel)
-
-	// 处理 if 语句的 stmt 部分
-	if err := genStmt(p); err != nil {
-		return err
+// 条件跳转在中间动作 @ifThen 中生成，这里只需要标记 if 语句之后的代码位置
+func genStmtIf(p *Parser, args []Value) (Value, error) {
+	ifThen, err := p.MarkerValue("ifThen")
+	if err != nil {
+		return Value{}, err
 	}
-
-	// 标记 if 语句之后的代码位置
-	p.EmitLabel(afterStmtLabel)
-
-	return nil
+	p.EmitLabel(ifThen.Labels[0])
+	return Value{}, nil
 }
 
 // stmt -> if(bool) stmt else stmt
-func genStmtIfElse(p *Parser) error {
-	// 生成 if-else 语句的代码
-	condition := p.TokenStack[len(p.TokenStack)-5]
-	elseLabel := p.NewLabel()      // 创建新的标签，用于 else 语句的代码位置
-	afterStmtLabel := p.NewLabel() // 创建新的标签，用于 if-else 之后的代码位置
-
-	// 生成条件为假时跳转到 else 的代码
-	p.Emit("ifFalse", string(condition), "goto", elseLabel)
-
-	// 处理 if 语句的 stmt 部分
-	if err := genStmt(p); err != nil {
-		return err
+// 条件跳转和跳过 else 的代码在中间动作 @ifThen、@ifElse 中生成，这里只需要标记 if-else 语句之后的代码位置
+func genStmtIfElse(p *Parser, args []Value) (Value, error) {
+	ifElse, err := p.MarkerValue("ifElse")
+	if err != nil {
+		return Value{}, err
 	}
+	p.EmitLabel(ifElse.Labels[0])
+	return Value{}, nil
+}
 
-	// 从 if 直接跳转到 if-else 语句之后的代码位置
-	p.Emit("goto", afterStmtLabel)
-
-	// 标记 else 语句的开始位置
-	p.EmitLabel(elseLabel)
-
-	// 处理 else 语句的 stmt 部分
-	if err := genStmt(p); err != nil {
-		return err
+// stmt -> while(bool) stmt
+// 循环开始的标签和条件跳转在中间动作 @whileBegin、@whileBody 中生成
+func genStmtWhile(p *Parser, args []Value) (Value, error) {
+	// 循环体结束后跳回循环开始
+	whileBegin, err := p.MarkerValue("whileBegin")
+	if err != nil {
+		return Value{}, err
 	}
+	p.EmitJump("goto", whileBegin.Labels[0])
 
-	// 标记 if-else 语句之后的代码位置
-	p.EmitLabel(afterStmtLabel)
-
-	return nil
+	// 标记循环之后的代码位置，break 也跳转到这里
+	breakLabel, err := p.GetBreakLabel()
+	if err != nil {
+		return Value{}, err
+	}
+	p.EmitLabel(breakLabel)
+	return Value{}, p.ExitLoop()
 }
 
-// stmt -> while(bool) stmt
-func genStmtWhile(p *Parser) error {
-	// 生成 while 语句的代码
-	startLabel := p.NewLabel() // 创建新的标签，用于 while 循环开始的位置
-	condition := p.TokenStack[len(p.TokenStack)-3]
-	afterStmtLabel := p.NewLabel() // 创建新的标签，用于 while 循环之后的代码位置
-
-	// 标记循环开始的位置
-	p.EmitLabel(startLabel)
+// stmt -> do stmt while(bool);
+// 循环开始的标签在中间动作 @doBegin 中生成
+func genStmtDoWhile(p *Parser, args []Value) (Value, error) {
+	condition := args[4].Addr
 
-	// 生成条件为假时跳转的代码
-	p.Emit("ifFalse", string(condition), "goto", afterStmtLabel)
+	// 生成条件为真时重复循环的代码
+	doBegin, err := p.MarkerValue("doBegin")
+	if err != nil {
+		return Value{}, err
+	}
+	p.EmitJump("if", condition, doBegin.Labels[0])
 
-	// 处理 while 语句的 stmt 部分
-	if err := genStmt(p); err != nil {
-		return err
+	// 标记循环之后的代码位置，break 也跳转到这里
+	breakLabel, err := p.GetBreakLabel()
+	if err != nil {
+		return Value{}, err
 	}
+	p.EmitLabel(breakLabel)
+	return Value{}, p.ExitLoop()
+}
 
-	// 循环结束后跳回循环开始
-	p.Emit("goto", startLabel)
+// stmt -> break;
+func genStmtBreak(p *Parser, args []Value) (Value, error) {
+	// 生成 break 语句的代码
+	breakLabel, err := p.GetBreakLabel() // 获取跳出循环或 switch 的标签，单独分析一条语句或者 break 在循环外面时没有这个标签
+	if err != nil {
+		return Value{}, err
+	}
+	p.EmitJump("goto", breakLabel)
+	return Value{}, nil
+}
 
-	// 标记循环之后的代码位置
-	p.EmitLabel(afterStmtLabel)
-	return nil
+// @ifThen：if ( bool ) 之后、then 分支之前
+func genIfThen(p *Parser, left []Value) (Value, error) {
+	// 生成条件为假时跳过 then 分支的代码，标签作为标记的值留给 genStmtIf 或 @ifElse
+	condition := left[2].Addr
+	label := p.NewLabel()
+	p.EmitJump("ifFalse", condition, label)
+	return Value{Labels: []string{label}}, nil
 }
 
-// stmt -> do stmt while(bool);
-func genStmtDoWhile(p *Parser) error {
-	// 生成 do-while 语句的代码
-	startLabel := p.NewLabel() // 创建新的标签，用于 do-while 循环开始的位置
-	condition := p.TokenStack[len(p.TokenStack)-2]
+// @ifElse：else 之后、else 分支之前
+func genIfElse(p *Parser, left []Value) (Value, error) {
+	// then 分支执行完之后跳过 else 分支
+	afterStmtLabel := p.NewLabel()
+	p.EmitJump("goto", afterStmtLabel)
 
-	// 标记循环开始的位置
+	// 标记 else 分支的开始位置
+	ifThen, err := p.MarkerValue("ifThen")
+	if err != nil {
+		return Value{}, err
+	}
+	p.EmitLabel(ifThen.Labels[0])
+	return Value{Labels: []string{afterStmtLabel}}, nil
+}
+
+// @whileBegin：while 之后、条件之前
+func genWhileBegin(p *Parser, left []Value) (Value, error) {
+	// 标记循环开始的位置，条件的代码在这之后生成
+	startLabel := p.NewLabel()
 	p.EmitLabel(startLabel)
+	return Value{Labels: []string{startLabel}}, nil
+}
 
-	// 处理 do-while 语句的 stmt 部分
-	if err := genStmt(p); err != nil {
-		return err
+// @whileBody：while ( bool ) 之后、循环体之前
+func genWhileBody(p *Parser, left []Value) (Value, error) {
+	// 进入循环，循环之后的标签同时也是 break 的目标
+	p.EnterLoop()
+	condition := left[2].Addr
+	breakLabel, err := p.GetBreakLabel()
+	if err != nil {
+		return Value{}, err
 	}
-
-	// 生成条件为真时重复循环的代码
-	p.Emit("if", string(condition), "goto", startLabel)
-	return nil
+	p.EmitJump("ifFalse", condition, breakLabel)
+	return Value{}, nil
 }
 
-// stmt -> break;
-func genStmtBreak(p *Parser) error {
-	// 生成 break 语句的代码
-	breakLabel := p.GetBreakLabel() // 获取跳出循环或 switch 的标签
-	p.Emit("goto", breakLabel)
-	return nil
+// @doBegin：do 之后、循环体之前
+func genDoBegin(p *Parser, left []Value) (Value, error) {
+	// 标记循环开始的位置，并进入循环
+	startLabel := p.NewLabel()
+	p.EmitLabel(startLabel)
+	p.EnterLoop()
+	return Value{Labels: []string{startLabel}}, nil
 }
 
 // stmt -> block
-func genStmtBlock(p *Parser) error {
+func genStmtBlock(p *Parser, args []Value) (Value, error) {
 	// p.SymbolTable.EnterScope()
-	fmt.Println("[符号表] 进入新的作用域")
-	return nil
+	p.traceSemantic("[符号表] 进入新的作用域")
+	return Value{}, nil
 }
 
 // loc -> id
-func genLoc(p *Parser) error {
-	// 获取变量名
-	varName := p.TokenStack[len(p.TokenStack)-1]
-	fmt.Printf("[符号表] 触发变量 %s 赋值\n", varName)
-	p.LastName = string(varName)
-	return nil
+func genLoc(p *Parser, args []Value) (Value, error) {
+	// 变量的位置就是变量名
+	varName := args[0].Addr
+	p.traceSemantic("[符号表] 触发变量 %s 赋值", varName)
+	return args[0], nil
 }
 
 // loc -> loc_array
-func genLocArray(p *Parser) error { return nil }
+func genLocArray(p *Parser, args []Value) (Value, error) { return args[0], nil }
 
 // loc_array -> loc[num]
-func genLocArrayFinal(p *Parser) error {
-	// 获取数组名
-	arrayName := p.TokenStack[len(p.TokenStack)-3]
-	// 获取数组索引
-	arrayIndex := p.TokenStack[len(p.TokenStack)-2]
-	fmt.Printf("[符号表] 触发数组变量 %s 赋值，索引为 %s\n", arrayName, arrayIndex)
-	// 生成数组变量赋值代码
-	p.Emit(string(arrayName), string(arrayIndex), "") // 数组变量赋值操作
-	return nil
+func genLocArrayFinal(p *Parser, args []Value) (Value, error) {
+	// 数组名来自 loc 的值，索引来自 num 的值
+	arrayName := args[0].Addr
+	arrayIndex := args[2].Addr
+	p.traceSemantic("[符号表] 触发数组变量 %s 赋值，索引为 %s", arrayName, arrayIndex)
+	// 数组元素的位置写成 a[i]，由使用它的语句生成读写代码
+	return Value{Addr: fmt.Sprintf("%s[%s]", arrayName, arrayIndex)}, nil
+}
+
+// emitBinary 生成二元运算的中间代码，结果保存在一个新的临时变量中，返回这个临时变量
+func emitBinary(p *Parser, opcode string, op1, op2 Value) Value {
+	result := p.SymbolTable.NewTempAddr() // 生成一个新的临时变量
+	p.Emit(result, opcode, op1.Addr, op2.Addr)
+	return Value{Addr: result}
 }
 
 // bool -> join || bool
-func genBoolOr(p *Parser) error {
+func genBoolOr(p *Parser, args []Value) (Value, error) {
 	// 生成逻辑或操作的中间代码
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()          // 生成一个新的临时变量
-	p.Emit(result, string(op1), string(op2), "||") // 逻辑或操作
-	return nil
+	return emitBinary(p, "||", args[0], args[2]), nil
 }
 
 // bool -> join
-func genBool(p *Parser) error {
-	// 不需要生成中间代码，直接返回
-	return nil
+func genBool(p *Parser, args []Value) (Value, error) {
+	// 不需要生成中间代码，直接传递 join 的值
+	return args[0], nil
 }
 
 // join -> equality
-func genJoin(p *Parser) error {
+func genJoin(p *Parser, args []Value) (Value, error) {
 	// 这里不需要生成中间代码，因为相等性比较 equality 会单独处理
-	return nil
+	return args[0], nil
 }
 
 // join -> join && equality
-func genJoinAnd(p *Parser) error {
+func genJoinAnd(p *Parser, args []Value) (Value, error) {
 	// 生成逻辑与操作的中间代码
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()          // 生成一个新的临时变量
-	p.Emit(result, string(op1), string(op2), "&&") // 逻辑与操作
-	return nil
+	return emitBinary(p, "&&", args[0], args[2]), nil
 }
 
 // equality -> equality == rel
-func genEqualityEqual(p *Parser) error {
+func genEqualityEqual(p *Parser, args []Value) (Value, error) {
 	// 生成等于操作的中间代码
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()          // 生成一个新的临时变量
-	p.Emit(result, string(op1), string(op2), "==") // 等于操作
-	return nil
+	return emitBinary(p, "==", args[0], args[2]), nil
 }
 
 // equality -> equality != rel
-func genEqualityNotEqual(p *Parser) error {
+func genEqualityNotEqual(p *Parser, args []Value) (Value, error) {
 	// 生成不等比较操作的中间代码
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()          // 生成一个新的临时变量
-	p.Emit(result, "!=", string(op1), string(op2)) // 不等比较操作
-	return nil
+	return emitBinary(p, "!=", args[0], args[2]), nil
 }
 
 // equality -> rel
-func genEquality(p *Parser) error {
+func genEquality(p *Parser, args []Value) (Value, error) {
 	// 这里不需要生成中间代码，因为相对表达式 rel 会单独处理
-	return nil
+	return args[0], nil
 }
 
 // rel -> expr < expr
-func genRelLess(p *Parser) error {
+func genRelLess(p *Parser, args []Value) (Value, error) {
 	// 生成小于比较操作的中间代码
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()         // 生成一个新的临时变量
-	p.Emit(result, "<", string(op1), string(op2)) // 小于比较操作
-	return nil
+	return emitBinary(p, "<", args[0], args[2]), nil
 }
 
 // rel -> expr <= expr
-func genRelLessEqual(p *Parser) error {
+func genRelLessEqual(p *Parser, args []Value) (Value, error) {
 	// 生成小于等于比较操作的中间代码
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()          // 生成一个新的临时变量
-	p.Emit(result, "<=", string(op1), string(op2)) // 小于等于比较操作
-	return nil
+	return emitBinary(p, "<=", args[0], args[2]), nil
 }
 
 // rel -> expr >= expr
-func genRelGreaterEqual(p *Parser) error {
-	// 生成大于等于比较操作的中间代码
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()
-	p.Emit(result, ">=", string(op1), string(op2))
-	return nil
+func genRelGreaterEqual(p *Parser, args []Value) (Value, error) {
+	return emitBinary(p, ">=", args[0], args[2]), nil
 }
 
 // rel -> expr > expr
-func genRelGreater(p *Parser) error {
-	// 生成大于比较操作的中间代码
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()
-	p.Emit(result, ">", string(op1), string(op2))
-	return nil
+func genRelGreater(p *Parser, args []Value) (Value, error) {
+	return emitBinary(p, ">", args[0], args[2]), nil
 }
 
 // rel -> expr
-func genRel(p *Parser) error {
+func genRel(p *Parser, args []Value) (Value, error) {
 	// 这里不需要生成中间代码，因为 expr 自身已经生成了相应的中间代码。
-	return nil
+	return args[0], nil
 }
 
 // expr -> expr + term
-func genExprAdd(p *Parser) error {
+func genExprAdd(p *Parser, args []Value) (Value, error) {
 	// 生成加法操作的中间代码
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()
-	p.Emit(result, "+", string(op1), string(op2))
-	return nil
+	return emitBinary(p, "+", args[0], args[2]), nil
 }
 
 // expr -> expr - term
-func genExprSub(p *Parser) error {
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()
-	p.Emit(result, "-", string(op1), string(op2))
-	return nil
+func genExprSub(p *Parser, args []Value) (Value, error) {
+	return emitBinary(p, "-", args[0], args[2]), nil
 }
 
 // expr -> term
-func genExpr(p *Parser) error {
+func genExpr(p *Parser, args []Value) (Value, error) {
 	// 这里不需要生成中间代码，因为 term 将单独处理
-	return nil
+	return args[0], nil
 }
 
 // term -> term * unary
-func genTermMul(p *Parser) error {
-	// 生成除法操作的中间代码
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()
-	p.Emit(result, "*", string(op1), string(op2))
-	return nil
+func genTermMul(p *Parser, args []Value) (Value, error) {
+	// 生成乘法操作的中间代码
+	return emitBinary(p, "*", args[0], args[2]), nil
 }
 
 // term -> term / unary
-func genTermDiv(p *Parser) error {
+func genTermDiv(p *Parser, args []Value) (Value, error) {
 	// 生成除法操作的中间代码
-	op1 := p.TokenStack[len(p.TokenStack)-3]
-	op2 := p.TokenStack[len(p.TokenStack)-1]
-	result := p.SymbolTable.NewTempAddr()
-	p.Emit(result, "/", string(op1), string(op2))
-	return nil
+	return emitBinary(p, "/", args[0], args[2]), nil
 }
 
 // term -> unary
-func genTerm(p *Parser) error {
+func genTerm(p *Parser, args []Value) (Value, error) {
 	// 这里不需要生成中间代码，因为一元操作 unary 将单独处理
-	return nil
+	return args[0], nil
 }
 
 // unary -> !unary
-func genUnaryNot(p *Parser) error {
+func genUnaryNot(p *Parser, args []Value) (Value, error) {
 	// 生成逻辑非操作的中间代码
-	op := p.TokenStack[len(p.TokenStack)-1]
 	result := p.SymbolTable.NewTempAddr()
-	p.Emit(result, "!", string(op))
-	return nil
+	p.Emit(result, "!", args[1].Addr)
+	return Value{Addr: result}, nil
 }
 
 // unary -> -unary
-func genUnaryNeg(p *Parser) error {
+func genUnaryNeg(p *Parser, args []Value) (Value, error) {
 	// 生成负号操作的中间代码
-	op := p.TokenStack[len(p.TokenStack)-1]
 	result := p.SymbolTable.NewTempAddr()
-	p.Emit(result, "-", string(op))
-	return nil
+	p.Emit(result, "-", args[1].Addr)
+	return Value{Addr: result}, nil
 }
 
 // unary -> factor
-func genUnary(p *Parser) error {
+func genUnary(p *Parser, args []Value) (Value, error) {
 	// 不需要生成中间代码，因为因子 factor 将单独处理
-	return nil
+	return args[0], nil
 }
 
 // factor -> (bool)
-func genFactorBool(p *Parser) error {
-	// 不需要生成中间代码，因为布尔表达式 bool 将单独处理
-	return nil
+func genFactorBool(p *Parser, args []Value) (Value, error) {
+	// 不需要生成中间代码，括号中 bool 的值就是 factor 的值
+	return args[1], nil
 }
 
 // factor -> loc
-func genFactorLoc(p *Parser) error {
+func genFactorLoc(p *Parser, args []Value) (Value, error) {
 	// 不需要生成中间代码，因为位置 loc 将单独处理
-	return nil
+	return args[0], nil
 }
 
 // factor -> num
-func genFactorNum(p *Parser) error {
-	// 不需要生成中间代码，因为数字 num 将单独处理
-	return nil
+func genFactorNum(p *Parser, args []Value) (Value, error) {
+	// 不需要生成中间代码，数字本身就是操作数
+	return args[0], nil
 }
 
 // factor -> real
-func genFactorReal(p *Parser) error {
-	// 不需要生成中间代码，因为实数 real 将单独处理
-	return nil
+func genFactorReal(p *Parser, args []Value) (Value, error) {
+	// 不需要生成中间代码，实数本身就是操作数
+	return args[0], nil
 }
 
 // factor -> true
-func genFactorTrue(p *Parser) error {
-	// 不需要生成中间代码，因为常量 true 将单独处理
-	return nil
+func genFactorTrue(p *Parser, args []Value) (Value, error) {
+	// 不需要生成中间代码，常量 true 本身就是操作数
+	return args[0], nil
 }
 
 // factor -> false
-func genFactorFalse(p *Parser) error {
-	// 不需要生成中间代码，因为常量 false 将单独处理
-	return nil
+func genFactorFalse(p *Parser, args []Value) (Value, error) {
+	// 不需要生成中间代码，常量 false 本身就是操作数
+	return args[0], nil
+}
+
+// stmt → error ;
+func genStmtError(p *Parser, args []Value) (Value, error) {
+	// 出错的语句不生成中间代码，只记录错误节点
+	p.recordErrorNode("stmt")
+	return Value{}, nil
+}
+
+// block → { decls stmts error }
+func genBlockError(p *Parser, args []Value) (Value, error) {
+	// 块中剩余的部分被丢弃，只记录错误节点
+	p.recordErrorNode("block")
+	return Value{}, nil
+}
+
+// 以下是 TYPE_ATTRIBUTES 中属性规则的计算函数
+
+// attrCopy 直接复制唯一依赖的属性
+func attrCopy(p *Parser, args []any) (any, error) {
+	return args[0], nil
+}
+
+// attrBasicWidth 返回基本类型占用的字节数
+func attrBasicWidth(p *Parser, args []any) (any, error) {
+	switch args[0].(string) {
+	case "float":
+		return 8, nil
+	case "bool", "char", "byte":
+		return 1, nil
+	default:
+		return 4, nil
+	}
+}
+
+// attrArrayType 返回数组类型的表示，例如 int[3][4] 是 array(3, array(4, int))
+// args 是基本类型、前面的维度和最后一个维度，从最右边的维度开始向左构造
+func attrArrayType(p *Parser, args []any) (any, error) {
+	dims := append(slices.Clone(args[1].([]string)), args[2].(string))
+	arrayType := args[0].(string)
+	for i := len(dims) - 1; i >= 0; i-- {
+		arrayType = fmt.Sprintf("array(%s, %s)", dims[i], arrayType)
+	}
+	return arrayType, nil
+}
+
+// attrAppendDim 在维度列表的末尾加上一个维度
+func attrAppendDim(p *Parser, args []any) (any, error) {
+	return append(slices.Clone(args[0].([]string)), args[1].(string)), nil
+}
+
+// attrNoDims 返回基本类型的维度列表，即空列表
+func attrNoDims(p *Parser, args []any) (any, error) {
+	return []string{}, nil
+}
+
+// attrArrayWidth 返回数组占用的字节数，即元素个数乘以元素的字节数
+func attrArrayWidth(p *Parser, args []any) (any, error) {
+	size, err := strconv.Atoi(args[0].(string))
+	if err != nil {
+		return nil, fmt.Errorf("数组大小 %s 不是整数", args[0])
+	}
+	return size * args[1].(int), nil
+}
+
+// attrDefineType 记录变量声明时的类型，返回这个类型
+func attrDefineType(p *Parser, args []any) (any, error) {
+	name, varType := args[0].(string), args[1].(string)
+	p.Attributes.types[name] = varType
+	return varType, nil
+}
+
+// attrLookupType 查出变量声明时的类型，与声明语句中 decl.type 的值相同
+// 符号表只记录了基本类型和最后一个维度，无法还原多维数组的类型，所以使用 attrDefineType 记录的类型
+func attrLookupType(p *Parser, args []any) (any, error) {
+	name := args[0].(string)
+	varType, ok := p.Attributes.types[name]
+	if !ok {
+		return nil, fmt.Errorf("变量 %s 没有声明", name)
+	}
+	return varType, nil
 }
@@ -0,0 +1,530 @@
+// simplify.go
+// 文法化简：消除空产生式和单位产生式，内联只使用一次的非终结符，并组合被合并的语义动作
+
+package parser
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// Composition 记录化简后的产生式由原文法中的哪些产生式组合而成
+/*
+	每个节点是原文法中的一个产生式，Children 与产生式体中的符号（去掉 EPSILON）一一对应：
+	子节点为 nil 表示这个符号保留在化简后的产生式体中，否则表示这个符号被它的推导替换掉了。
+	例如消除单位产生式 factor → loc、loc → id 后得到 factor → id，它的推导是 factor → loc (loc → id)。
+	化简后的产生式规约时，按照原文法的规约顺序（后序遍历）依次调用每个节点的处理函数，
+	子节点的返回值作为被替换的符号的值传给父节点，调用之前把符号栈和语义值栈恢复成原文法规约到这个节点时的样子，
+	所以处理函数（包括读取栈的中间动作）不需要修改。
+*/
+type Composition struct {
+	Production Production     // 原文法中的产生式
+	Children   []*Composition // 产生式体中每个符号的推导，nil 表示保留在新的产生式体中
+}
+
+// String 以处理函数的嵌套调用形式返回组合后的语义动作，例如 genFactorLoc(genLoc(id))
+func (d *Composition) String() string {
+	body := rhs(d.Production.Body)
+	args := make([]string, len(body))
+	for i, child := range d.Children {
+		if child == nil {
+			args[i] = string(body[i])
+		} else {
+			args[i] = child.String()
+		}
+	}
+	return fmt.Sprintf("%s(%s)", handlerName(d.Production.Handler), strings.Join(args, ", "))
+}
+
+// leaves 返回保留在新的产生式体中的符号个数
+func (d *Composition) leaves() int {
+	count := 0
+	for _, child := range d.Children {
+		if child == nil {
+			count++
+		} else {
+			count += child.leaves()
+		}
+	}
+	return count
+}
+
+// plug 按照从左到右的顺序，用 subs 替换推导中保留的符号，subs[i] 为 nil 的符号继续保留
+// 没有需要替换的符号时直接返回 d
+func (d *Composition) plug(subs []*Composition) *Composition {
+	if !slices.ContainsFunc(subs, func(sub *Composition) bool { return sub != nil }) {
+		return d
+	}
+	next := 0
+	return d.plugFrom(subs, &next)
+}
+
+func (d *Composition) plugFrom(subs []*Composition, next *int) *Composition {
+	plugged := &Composition{Production: d.Production, Children: make([]*Composition, len(d.Children))}
+	for i, child := range d.Children {
+		if child == nil {
+			plugged.Children[i] = subs[*next]
+			*next++
+		} else {
+			plugged.Children[i] = child.plugFrom(subs, next)
+		}
+	}
+	return plugged
+}
+
+// handler 返回组合后的处理函数
+// 规约时两个栈的栈顶是新产生式体中各个符号和它们的值，先把它们取出来，按照原文法的顺序重放规约，最后恢复两个栈，交给 applyReduction 弹出
+func (d *Composition) handler() func(*Parser, []Value) (Value, error) {
+	leaves := d.leaves()
+	return func(p *Parser, _ []Value) (Value, error) {
+		base := len(p.TokenStack) - leaves
+		symbols, values := slices.Clone(p.TokenStack[base:]), slices.Clone(p.ValueStack[base:])
+		p.popSymbols(leaves)
+		next := 0
+		value, err := d.replay(p, symbols, values, &next)
+		p.TokenStack = append(p.TokenStack[:base], symbols...)
+		p.ValueStack = append(p.ValueStack[:base], values...)
+		return value, err
+	}
+}
+
+// replay 重放以 d 为根的规约，返回 d 的头部的值，返回时两个栈恢复到调用之前的样子
+func (d *Composition) replay(p *Parser, symbols []consts.Symbol, values []Value, next *int) (Value, error) {
+	for _, child := range d.Children {
+		if child == nil {
+			p.pushSymbol(symbols[*next], values[*next])
+			*next++
+			continue
+		}
+		value, err := child.replay(p, symbols, values, next)
+		if err != nil {
+			return Value{}, err
+		}
+		p.pushSymbol(child.Production.Head, value)
+	}
+	n := len(d.Children)
+	value, err := p.callHandler(d.Production, handlerArgs(d.Production.Body, p.ValueStack[len(p.ValueStack)-n:]))
+	p.popSymbols(n)
+	return value, err
+}
+
+// origin 返回文法中第 index 个产生式的推导，没有经过化简的产生式就是它自己
+func (g *Grammar) origin(index int) *Composition {
+	if d, ok := g.Compositions[index]; ok {
+		return d
+	}
+	prod := g.Productions[index]
+	return &Composition{Production: prod, Children: make([]*Composition, len(rhs(prod.Body)))}
+}
+
+// SimplifyOptions 表示需要进行的化简
+type SimplifyOptions struct {
+	Epsilon bool // 消除空产生式
+	Unit    bool // 消除单位产生式
+	Inline  bool // 内联只使用一次的非终结符
+}
+
+// Simplify 按照消除空产生式、消除单位产生式、内联的顺序化简文法
+// 消除空产生式可能产生新的单位产生式（例如 A → B C 且 C 可空），所以它最先进行
+func (g *Grammar) Simplify(options SimplifyOptions) (*Grammar, []TransformNote) {
+	grammar := g
+	var notes []TransformNote
+	steps := []struct {
+		enabled bool
+		apply   func(*Grammar) (*Grammar, []TransformNote)
+	}{
+		{options.Epsilon, (*Grammar).EliminateEpsilon},
+		{options.Unit, (*Grammar).EliminateUnitProductions},
+		{options.Inline, (*Grammar).InlineSingleUse},
+	}
+	for _, step := range steps {
+		if !step.enabled {
+			continue
+		}
+		var stepNotes []TransformNote
+		grammar, stepNotes = step.apply(grammar)
+		notes = append(notes, stepNotes...)
+	}
+	return grammar, notes
+}
+
+// simplifier 收集化简得到的产生式，相同的产生式只保留第一个
+type simplifier struct {
+	from         *Grammar
+	productions  []Production
+	compositions map[int]*Composition
+	prec         map[int]consts.Terminal
+	keys         map[string]bool
+	notes        []TransformNote
+}
+
+func newSimplifier(g *Grammar) *simplifier {
+	return &simplifier{from: g, compositions: make(map[int]*Composition), prec: make(map[int]consts.Terminal), keys: make(map[string]bool)}
+}
+
+// add 加入产生式 head → body，它的推导是 d，优先级沿用 from 中第 precFrom 个产生式的 %prec
+func (s *simplifier) add(head consts.Symbol, body []consts.Symbol, d *Composition, precFrom int) {
+	key := fmt.Sprintf("%s|%v", head, body)
+	if s.keys[key] {
+		if d.Production.Handler != nil {
+			s.notes = append(s.notes, TransformNote{
+				Production: d.Production,
+				Message:    fmt.Sprintf("化简后与已有的产生式 %s → %s 相同，语义动作 %s 被丢弃", head, formatBody(body), d),
+			})
+		}
+		return
+	}
+	s.keys[key] = true
+
+	handler := d.Production.Handler
+	if slices.ContainsFunc(d.Children, func(child *Composition) bool { return child != nil }) {
+		handler = d.handler()
+		s.compositions[len(s.productions)] = d
+	}
+	if terminal, ok := s.from.ProductionPrec[precFrom]; ok {
+		s.prec[len(s.productions)] = terminal
+	}
+	s.productions = append(s.productions, Production{head, makeBody(slices.Clone(body)), handler})
+}
+
+// grammar 返回化简后的文法，删除从开始符号无法到达的产生式
+func (s *simplifier) grammar() *Grammar {
+	reachable := map[consts.Symbol]bool{s.from.Start: true}
+	for changed := true; changed; {
+		changed = false
+		for _, prod := range s.productions {
+			if !reachable[prod.Head] {
+				continue
+			}
+			for _, sym := range rhs(prod.Body) {
+				if !s.from.IsTerminal(sym) && !reachable[sym] {
+					reachable[sym] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	grammar := s.from.derive(nil)
+	grammar.Precedence = s.from.Precedence
+	grammar.ProductionPrec = make(map[int]consts.Terminal)
+	grammar.Compositions = make(map[int]*Composition)
+	for index, prod := range s.productions {
+		if !reachable[prod.Head] {
+			continue
+		}
+		newIndex := len(grammar.Productions)
+		if terminal, ok := s.prec[index]; ok {
+			grammar.ProductionPrec[newIndex] = terminal
+		}
+		if d, ok := s.compositions[index]; ok {
+			grammar.Compositions[newIndex] = d
+		}
+		grammar.Productions = append(grammar.Productions, prod)
+	}
+	return grammar
+}
+
+// result 返回化简后的文法和变换记录，变换记录包括 from 中没有的每个组合推导
+func (s *simplifier) result() (*Grammar, []TransformNote) {
+	grammar := s.grammar()
+	return grammar, append(s.notes, compositionNotes(s.from, grammar)...)
+}
+
+// compositionNotes 为 to 中新组合出的推导生成变换记录，from 中已有的推导不再记录
+func compositionNotes(from, to *Grammar) []TransformNote {
+	existing := make(map[*Composition]bool)
+	for _, d := range from.Compositions {
+		existing[d] = true
+	}
+	var notes []TransformNote
+	for index, prod := range to.Productions {
+		d, ok := to.Compositions[index]
+		if !ok || existing[d] {
+			continue
+		}
+		notes = append(notes, TransformNote{
+			Production: d.Production,
+			Message:    fmt.Sprintf("改写为 %s → %s，语义动作组合为 %s", prod.Head, formatBody(prod.Body), d),
+		})
+	}
+	return notes
+}
+
+// erasable 返回可以消除的可空非终结符
+// 中间动作的标记非终结符虽然可空，但消除之后中间动作就只能等到整个产生式规约时执行，所以保留它们，包含标记的非终结符也因此不可空
+func (g *Grammar) erasable() map[consts.Symbol]bool {
+	nullable := make(map[consts.Symbol]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, prod := range g.Productions {
+			if nullable[prod.Head] || IsMarker(prod.Head) || g.IsTerminal(prod.Head) {
+				continue
+			}
+			if !slices.ContainsFunc(rhs(prod.Body), func(sym consts.Symbol) bool { return !nullable[sym] }) {
+				nullable[prod.Head] = true
+				changed = true
+			}
+		}
+	}
+	return nullable
+}
+
+// emptyCompositions 为每个可消除的非终结符选择一个推导出空串的推导，优先使用编号小的产生式
+func (g *Grammar) emptyCompositions(erasable map[consts.Symbol]bool) map[consts.Symbol]*Composition {
+	empty := make(map[consts.Symbol]*Composition)
+	for changed := true; changed; {
+		changed = false
+		for index, prod := range g.Productions {
+			if !erasable[prod.Head] || empty[prod.Head] != nil {
+				continue
+			}
+			body := rhs(prod.Body)
+			subs := make([]*Composition, len(body))
+			complete := true
+			for i, sym := range body {
+				if subs[i] = empty[sym]; subs[i] == nil {
+					complete = false
+					break
+				}
+			}
+			if complete {
+				empty[prod.Head] = g.origin(index).plug(subs)
+				changed = true
+			}
+		}
+	}
+	return empty
+}
+
+// EliminateEpsilon 消除空产生式，返回新的文法
+/*
+	对于每个产生式 A → X1 X2 ... Xn，其中有 k 个可空的非终结符，生成省略其中任意几个的 2^k 个产生式（不包括空串），
+	然后删除所有空产生式。如果开始符号可空，保留一个开始符号的空产生式。
+	省略的符号 X 使用它推导出空串的推导，X 的处理函数在 A 规约时执行，而不是在读到 X 的位置执行。
+	对于只依赖符号栈的处理函数结果相同，但它们与 X 右边的符号的处理函数之间的先后顺序会改变。
+	中间动作的标记不会被消除，见 erasable。
+*/
+func (g *Grammar) EliminateEpsilon() (*Grammar, []TransformNote) {
+	erasable := g.erasable()
+	empty := g.emptyCompositions(erasable)
+	s := newSimplifier(g)
+	for index, prod := range g.Productions {
+		body := rhs(prod.Body)
+		if len(body) == 0 && !IsMarker(prod.Head) {
+			if prod.Handler != nil && prod.Head != g.Start {
+				s.notes = append(s.notes, TransformNote{Production: prod, Message: fmt.Sprintf("删除空产生式，语义动作 %s 合并到使用 %s 的产生式中", handlerName(prod.Handler), prod.Head)})
+			}
+			continue
+		}
+
+		var optional []int
+		for i, sym := range body {
+			if !g.IsTerminal(sym) && erasable[sym] {
+				optional = append(optional, i)
+			}
+		}
+		for mask := 0; mask < 1<<len(optional); mask++ {
+			subs := make([]*Composition, len(body))
+			for bit, position := range optional {
+				if mask&(1<<bit) != 0 {
+					subs[position] = empty[body[position]]
+				}
+			}
+			var kept []consts.Symbol
+			for i, sym := range body {
+				if subs[i] == nil {
+					kept = append(kept, sym)
+				}
+			}
+			if len(kept) > 0 {
+				s.add(prod.Head, kept, g.origin(index).plug(subs), index)
+			}
+		}
+	}
+	if d, ok := empty[g.Start]; ok {
+		s.add(g.Start, nil, d, -1)
+	}
+	return s.result()
+}
+
+// isUnit 判断产生式是否是单位产生式 A → B，B 是非终结符且不是中间动作的标记
+func (g *Grammar) isUnit(prod Production) bool {
+	body := rhs(prod.Body)
+	return len(body) == 1 && !g.IsTerminal(body[0]) && !IsMarker(body[0])
+}
+
+// EliminateUnitProductions 消除单位产生式，返回新的文法
+/*
+	对于单位产生式 A → B，沿着单位产生式找到 B ⇒* C 的所有 C，用 C 的每个非单位产生式 C → γ 生成 A → γ。
+	例如 bool → join → equality → rel → expr → term → unary → factor → num 组合为 bool → num，
+	组合后的处理函数依次执行 genFactorNum、genUnary、genTerm、…、genBool，与原文法规约的顺序完全相同。
+	A ⇒+ A 的环直接忽略，不再被使用的非终结符（例如 type_array）会被删除。
+*/
+func (g *Grammar) EliminateUnitProductions() (*Grammar, []TransformNote) {
+	s := newSimplifier(g)
+	var expand func(head consts.Symbol, wrap func(*Composition) *Composition, sym consts.Symbol, visited map[consts.Symbol]bool)
+	expand = func(head consts.Symbol, wrap func(*Composition) *Composition, sym consts.Symbol, visited map[consts.Symbol]bool) {
+		if visited[sym] {
+			return
+		}
+		visited[sym] = true
+		for index, prod := range g.Productions {
+			if prod.Head != sym {
+				continue
+			}
+			if !g.isUnit(prod) {
+				s.add(head, rhs(prod.Body), wrap(g.origin(index)), index)
+				continue
+			}
+			inner := g.origin(index)
+			expand(head, func(d *Composition) *Composition { return wrap(inner.plug([]*Composition{d})) }, rhs(prod.Body)[0], visited)
+		}
+	}
+
+	for index, prod := range g.Productions {
+		if !g.isUnit(prod) {
+			s.add(prod.Head, rhs(prod.Body), g.origin(index), index)
+			continue
+		}
+		outer := g.origin(index)
+		expand(prod.Head, func(d *Composition) *Composition { return outer.plug([]*Composition{d}) }, rhs(prod.Body)[0], map[consts.Symbol]bool{prod.Head: true})
+	}
+	return s.result()
+}
+
+// InlineSingleUse 内联只在一个产生式体中出现一次的非终结符，返回新的文法
+/*
+	如果 B 只在 A → α B β 中出现一次，并且 B 不是开始符号、不是中间动作的标记、没有递归，
+	就用 B 的每个产生式 B → γ 生成 A → α γ β，并删除 B 的产生式。重复进行直到没有可以内联的非终结符。
+	B 的处理函数在 A 规约时执行，与 β 中符号的处理函数之间的先后顺序会改变。
+*/
+func (g *Grammar) InlineSingleUse() (*Grammar, []TransformNote) {
+	grammar := g
+	var notes []TransformNote
+	for {
+		sym, ok := grammar.inlineCandidate()
+		if !ok {
+			break
+		}
+		s := newSimplifier(grammar)
+		for index, prod := range grammar.Productions {
+			if prod.Head == sym {
+				continue
+			}
+			body := rhs(prod.Body)
+			position := slices.Index(body, sym)
+			if position < 0 {
+				s.add(prod.Head, body, grammar.origin(index), index)
+				continue
+			}
+			for inner, alternative := range grammar.Productions {
+				if alternative.Head != sym {
+					continue
+				}
+				subs := make([]*Composition, len(body))
+				subs[position] = grammar.origin(inner)
+				inlined := append(append(slices.Clone(body[:position]), rhs(alternative.Body)...), body[position+1:]...)
+				s.add(prod.Head, inlined, grammar.origin(index).plug(subs), index)
+			}
+		}
+		grammar = s.grammar()
+		notes = append(notes, s.notes...)
+	}
+	// 中间步骤组合出的推导可能在后面又被内联，所以只为最终文法中的推导生成记录
+	return grammar, append(notes, compositionNotes(g, grammar)...)
+}
+
+// inlineCandidate 返回第一个可以内联的非终结符
+func (g *Grammar) inlineCandidate() (consts.Symbol, bool) {
+	uses := make(map[consts.Symbol]int)
+	recursive := make(map[consts.Symbol]bool)
+	for _, prod := range g.Productions {
+		for _, sym := range rhs(prod.Body) {
+			if g.IsTerminal(sym) {
+				continue
+			}
+			uses[sym]++
+			if sym == prod.Head {
+				recursive[sym] = true
+			}
+		}
+	}
+	for _, head := range g.NonTerminals() {
+		if uses[head] == 1 && !recursive[head] && head != g.Start && !IsMarker(head) && !g.IsTerminal(head) {
+			return head, true
+		}
+	}
+	return "", false
+}
+
+// TableSize 表示文法和它的 LR(1) 分析表的规模
+type TableSize struct {
+	Productions   int // 产生式个数
+	NonTerminals  int // 非终结符个数
+	States        int // 状态个数
+	ActionEntries int // Action 表中非空的表项个数
+	GotoEntries   int // Goto 表中非空的表项个数
+	Conflicts     int // 构建分析表时发现的冲突个数
+}
+
+// TableSize 构建文法的 LR(1) 分析表并统计它的规模
+func (g *Grammar) TableSize() TableSize {
+	p := NewParserWithGrammar(g)
+	p.InitFirstSet()
+	p.BuildStateCollection()
+	p.BuildTables()
+	size := TableSize{
+		Productions:  len(g.Productions),
+		NonTerminals: len(g.NonTerminals()),
+		States:       len(p.StateCollection),
+		Conflicts:    len(p.Conflicts),
+	}
+	for _, row := range p.ActionTable {
+		size.ActionEntries += len(row)
+	}
+	for _, row := range p.GotoTable {
+		size.GotoEntries += len(row)
+	}
+	return size
+}
+
+// SimplifyReport 比较化简前后的文法和分析表的规模
+type SimplifyReport struct {
+	Before, After TableSize
+	Notes         []TransformNote
+}
+
+// NewSimplifyReport 按照 options 化简文法，返回化简后的文法和对比报告
+func (g *Grammar) NewSimplifyReport(options SimplifyOptions) (*Grammar, SimplifyReport) {
+	simplified, notes := g.Simplify(options)
+	return simplified, SimplifyReport{Before: g.TableSize(), After: simplified.TableSize(), Notes: notes}
+}
+
+// WriteText 以文本形式输出报告
+func (r SimplifyReport) WriteText(w io.Writer) {
+	fmt.Fprintln(w, "===============化简报告===============")
+	rows := []struct {
+		name          string
+		before, after int
+	}{
+		{"产生式", r.Before.Productions, r.After.Productions},
+		{"非终结符", r.Before.NonTerminals, r.After.NonTerminals},
+		{"状态", r.Before.States, r.After.States},
+		{"Action 表项", r.Before.ActionEntries, r.After.ActionEntries},
+		{"Goto 表项", r.Before.GotoEntries, r.After.GotoEntries},
+		{"冲突", r.Before.Conflicts, r.After.Conflicts},
+	}
+	fmt.Fprintf(w, "%-12s %8s %8s %8s\n", "", "化简前", "化简后", "变化")
+	for _, row := range rows {
+		fmt.Fprintf(w, "%-12s %8d %8d %+8d\n", row.name, row.before, row.after, row.after-row.before)
+	}
+	fmt.Fprintf(w, "\n语义动作的变化（%d 处）：\n", len(r.Notes))
+	for _, note := range r.Notes {
+		fmt.Fprintf(w, "  %s\n", note)
+	}
+}
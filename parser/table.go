@@ -5,28 +5,41 @@ package parser
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/ozline/CoursePractice-GoCompiler/consts"
 )
 
+// PrintGoToTable 按照状态编号和符号的顺序打印 Goto 表，每次输出的顺序相同
 func (p *Parser) PrintGoToTable() {
 	fmt.Println("GOTO 表")
-	for i, gotoMap := range p.GotoTable {
-		for sym, stateIndex := range gotoMap {
-			fmt.Printf("GOTO[%d, %s] = %d\n", i, sym, stateIndex)
+	for _, i := range sortedStates(p.GotoTable) {
+		for _, sym := range unionKeys(p.GotoTable[i], nil) {
+			fmt.Printf("GOTO[%d, %s] = %d\n", i, sym, p.GotoTable[i][sym])
 		}
 	}
 }
 
+// PrintActionTable 按照状态编号和终结符的顺序打印 Action 表，每次输出的顺序相同
 func (p *Parser) PrintActionTable() {
 	fmt.Println("ACTION 表")
-	for i, actionMap := range p.ActionTable {
-		for sym, actionEntry := range actionMap {
-			fmt.Printf("ACTION[%d, %s] = %v\n", i, sym, actionEntry)
+	for _, i := range sortedStates(p.ActionTable) {
+		for _, sym := range unionKeys(p.ActionTable[i], nil) {
+			fmt.Printf("ACTION[%d, %s] = %v\n", i, sym, p.ActionTable[i][sym])
 		}
 	}
 }
 
+// sortedStates 返回表中所有的状态编号，从小到大排列
+func sortedStates[V any](table map[int]V) []int {
+	states := make([]int, 0, len(table))
+	for state := range table {
+		states = append(states, state)
+	}
+	slices.Sort(states)
+	return states
+}
+
 func (p *Parser) buildGotoTable() {
 	// 遍历所有的 LR(1) 状态 state
 	for i, state := range p.StateCollection {
@@ -37,12 +50,10 @@ func (p *Parser) buildGotoTable() {
 			if item.Position < len(item.Production.Body) {
 				sym := item.Production.Body[item.Position]
 
-				// 如果 sym 是一个非终结符，计算 sym 的转移后的项集 nextState
+				// 如果 sym 是一个非终结符，查找构建状态集合时记录的转移
 				if !p.Grammar.IsTerminal(sym) {
-					nextState := p.gotoState(state.Items, sym)
-
-					// 如果 nextState 在状态集合 p.StateCollection 中，将动作表 p.GotoTable[i] 的对应项设置为 nextState 在 p.StateCollection 中的索引。
-					if nextStateIndex, exists := p.containsState(p.StateCollection, &State{Items: nextState}); exists {
+					// 如果转移存在，将动作表 p.GotoTable[i] 的对应项设置为转移后状态在 p.StateCollection 中的索引。
+					if nextStateIndex, exists := p.Transitions[i][sym]; exists {
 						if p.GotoTable[i] == nil {
 							p.GotoTable[i] = make(map[consts.Symbol]int)
 						}
@@ -73,7 +84,7 @@ func (p *Parser) buildActionTable() {
 
 			// 当没有未处理的符号时，执行规约动作或接受动作。
 			if item.Position == len(item.Production.Body) || (item.Position == len(item.Production.Body)-1 && item.Production.Body[item.Position] == EPSILON) {
-				if item.Production.Head == ARGUMENTED_PRODUCTION.Head && item.Lookahead == TERMINATE_SYMBOL {
+				if p.isAugmented(item.Production) && item.Lookahead == TERMINATE_SYMBOL {
 					// 接受动作
 
 					// 确保 p.ActionTable[i] 已经初始化，然后再进行赋值
@@ -105,7 +116,14 @@ func (p *Parser) buildActionTable() {
 				if existingAction, exists := p.ActionTable[i][item.Lookahead]; exists {
 					// 冲突检测：已有动作与当前动作不同
 					if existingAction.ActionType != REDUCE || existingAction.Number != prodIndex {
+						incoming := ActionEntry{ActionType: REDUCE, Number: prodIndex}
+						// 文法声明了优先级时，先尝试用优先级解决冲突
+						if chosen, ok := p.resolveConflict(i, item.Lookahead, existingAction, incoming); ok {
+							p.ActionTable[i][item.Lookahead] = chosen
+							continue
+						}
 						fmt.Printf("设置 REDUCE 发生冲突! 状态: %d 展望符: '%s'\n", i, item.Lookahead)
+						p.addConflict(i, item.Lookahead, existingAction, incoming)
 					}
 				} else {
 					p.ActionTable[i][item.Lookahead] = ActionEntry{
@@ -121,17 +139,10 @@ func (p *Parser) buildActionTable() {
 				// 如果产生式是增广产生式，且点的位置等于产生式体的长度，且展望符是终止符，执行接受动作。
 				sym := item.Production.Body[item.Position]
 
-				// 如果 sym 是一个终结符，计算 sym 的转移后的项集 nextState
-
+				// 如果 sym 是一个终结符，查找构建状态集合时记录的转移
 				if p.Grammar.IsTerminal(sym) {
-					nextState := p.gotoState(state.Items, sym)
-
-					if i == 25 {
-						fmt.Printf("")
-					}
-
-					// 如果 nextState 在状态集合 p.StateCollection 中，将动作表 p.ActionTable[i] 的对应项设置为移入动作，动作的参数是 nextState 在 p.StateCollection 中的索引。
-					if nextStateIndex, exists := p.containsState(p.StateCollection, &State{Items: nextState}); exists {
+					// 如果转移存在，将动作表 p.ActionTable[i] 的对应项设置为移入动作，动作的参数是转移后状态在 p.StateCollection 中的索引。
+					if nextStateIndex, exists := p.Transitions[i][consts.Symbol(sym)]; exists {
 						if p.ActionTable[i] == nil {
 							p.ActionTable[i] = make(map[consts.Terminal]ActionEntry)
 						}
@@ -139,7 +150,13 @@ func (p *Parser) buildActionTable() {
 						if existingAction, exists := p.ActionTable[i][consts.Terminal(sym)]; exists {
 							// 冲突检测：已有动作与当前动作不同
 							if existingAction.ActionType != SHIFT || existingAction.Number != nextStateIndex {
+								incoming := ActionEntry{ActionType: SHIFT, Number: nextStateIndex}
+								if chosen, ok := p.resolveConflict(i, consts.Terminal(sym), existingAction, incoming); ok {
+									p.ActionTable[i][consts.Terminal(sym)] = chosen
+									continue
+								}
 								fmt.Printf("设置 SHIFT 发生冲突! 状态: %d 符号: '%s', 期望数字：%+v, 现有内容为：%+v\n", i, sym, nextStateIndex, existingAction)
+								p.addConflict(i, consts.Terminal(sym), existingAction, incoming)
 							}
 						} else {
 							p.ActionTable[i][consts.Terminal(sym)] = ActionEntry{
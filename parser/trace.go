@@ -0,0 +1,193 @@
+// trace.go
+// 分析过程的跟踪：Parse 的每一步通过 Tracer 报告，可以不输出、输出为可读的文本或者 JSON Lines
+
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// TraceKind 表示跟踪事件的类型
+type TraceKind string
+
+const (
+	TRACE_BEGIN    TraceKind = "begin"    // 开始分析
+	TRACE_STEP     TraceKind = "step"     // 新的一步，记录此时的状态栈和符号栈
+	TRACE_TOKEN    TraceKind = "token"    // 读入了当前的 Token
+	TRACE_ACTION   TraceKind = "action"   // 查到了 Action 表中的动作
+	TRACE_SHIFT    TraceKind = "shift"    // 移入
+	TRACE_REDUCE   TraceKind = "reduce"   // 规约，处理函数在这个事件之后调用
+	TRACE_GOTO     TraceKind = "goto"     // 规约之后的 Goto 转移，恐慌模式恢复时压入同步非终结符之后的转移
+	TRACE_ACCEPT   TraceKind = "accept"   // 接受
+	TRACE_ERROR    TraceKind = "error"    // 语法错误
+	TRACE_POP      TraceKind = "pop"      // 错误恢复时弹出状态
+	TRACE_DISCARD  TraceKind = "discard"  // 错误恢复时丢弃 Token
+	TRACE_SEMANTIC TraceKind = "semantic" // 处理函数报告的语义信息，例如符号表的变化
+)
+
+// TraceEvent 表示分析过程中的一个事件，没有用到的字段为零值
+type TraceEvent struct {
+	Kind        TraceKind       `json:"kind"`
+	Step        int             `json:"step,omitempty"`        // 第几步
+	State       int             `json:"state,omitempty"`       // 栈顶状态，pop 事件中是被弹出的状态
+	StateStack  []int           `json:"stateStack,omitempty"`  // step 事件中的状态栈
+	SymbolStack []consts.Symbol `json:"symbolStack,omitempty"` // step 事件中的符号栈
+	Token       string          `json:"token,omitempty"`       // 当前 Token 的字面值，pop 事件中是被弹出的符号
+	Terminal    consts.Terminal `json:"terminal,omitempty"`    // 当前 Token 转换后的终结符
+	Action      ActionEntry     `json:"action,omitempty"`      // action 事件中的动作
+	Production  int             `json:"production,omitempty"`  // reduce 事件中的产生式编号
+	Head        consts.Symbol   `json:"head,omitempty"`        // reduce 事件中的产生式头部
+	Body        []consts.Symbol `json:"body,omitempty"`        // reduce 事件中的产生式体
+	Target      int             `json:"target,omitempty"`      // goto 事件中转移到的状态
+	Recovery    bool            `json:"recovery,omitempty"`    // 事件发生在错误恢复中：移入的是 error，或者错误已经报告并开始恢复
+	Message     string          `json:"message,omitempty"`     // error 事件中的错误信息，semantic 事件中处理函数报告的信息
+	Predictive  bool            `json:"predictive,omitempty"`  // 事件来自 ParseLL1：没有状态栈，token 事件的 Head 是栈顶符号，shift 事件是匹配终结符，action 事件是展开产生式
+}
+
+// Tracer 接收分析过程中的事件
+// Parse 只通过 Tracer 输出分析过程，处理函数通过 traceSemantic 报告符号表的变化等信息，同样经过 Tracer
+type Tracer interface {
+	Trace(event TraceEvent)
+}
+
+// SilentTracer 忽略所有事件，把编译器作为库使用时不产生任何分析过程的输出
+type SilentTracer struct{}
+
+// Trace 忽略事件
+func (SilentTracer) Trace(TraceEvent) {}
+
+// TextTracer 以可读的文本输出事件，这是 Parse 默认的输出格式
+type TextTracer struct {
+	W io.Writer // 为 nil 时输出到标准输出
+}
+
+// Trace 以文本输出事件
+func (t TextTracer) Trace(e TraceEvent) {
+	w := t.W
+	if w == nil {
+		w = os.Stdout
+	}
+	if e.Predictive {
+		traceLL1Text(w, e)
+		return
+	}
+	switch e.Kind {
+	case TRACE_BEGIN:
+		fmt.Fprintf(w, "\n\n===============开始解析===============")
+	case TRACE_STEP:
+		fmt.Fprintf(w, "\n\n=====================================\n")
+		fmt.Fprintf(w, "第 %d 步\n", e.Step)
+		fmt.Fprintf(w, "状态栈: %v\n", e.StateStack)
+		fmt.Fprintf(w, "符号栈: %v\n", e.SymbolStack)
+	case TRACE_TOKEN:
+		fmt.Fprintf(w, "当前状态: %d, 当前符号: %s 转换后: %s\n", e.State, e.Token, e.Terminal)
+	case TRACE_ACTION:
+		fmt.Fprintf(w, "动作类别: %s 期望下一步状态: %d\n", e.Action.ActionType, e.Action.Number)
+	case TRACE_SHIFT:
+		if e.Recovery {
+			fmt.Fprintf(w, "执行移入操作，移入 error\n")
+		} else {
+			fmt.Fprintf(w, "执行移入操作\n")
+		}
+	case TRACE_REDUCE:
+		fmt.Fprintf(w, "使用产生式 %v -> %v 规约\n", e.Head, e.Body)
+	case TRACE_GOTO:
+		if e.Recovery {
+			fmt.Fprintf(w, "[错误恢复] 压入同步符号 %s，转移状态到 %d\n", e.Head, e.Target)
+		} else {
+			fmt.Fprintf(w, "转移状态到 %d\n", e.Target)
+		}
+	case TRACE_ACCEPT:
+		fmt.Fprintln(w, "\n\n>>> 成功完成解析.")
+	case TRACE_ERROR:
+		// 无法恢复的错误由 Parse 返回，交给调用者输出
+		if e.Recovery {
+			fmt.Fprintf(w, "%s", e.Message)
+		}
+	case TRACE_POP:
+		fmt.Fprintf(w, "[错误恢复] 弹出状态 %d 和符号 %s\n", e.State, e.Token)
+	case TRACE_DISCARD:
+		fmt.Fprintf(w, "[错误恢复] 丢弃符号 %s\n", e.Token)
+	case TRACE_SEMANTIC:
+		fmt.Fprintln(w, e.Message)
+	}
+}
+
+// traceLL1Text 以文本输出 ParseLL1 的事件
+func traceLL1Text(w io.Writer, e TraceEvent) {
+	switch e.Kind {
+	case TRACE_BEGIN:
+		fmt.Fprintf(w, "\n\n===============开始预测分析===============")
+	case TRACE_STEP:
+		fmt.Fprintf(w, "\n\n=====================================\n")
+		fmt.Fprintf(w, "第 %d 步\n", e.Step)
+		fmt.Fprintf(w, "分析栈: %v\n", e.SymbolStack)
+	case TRACE_TOKEN:
+		fmt.Fprintf(w, "栈顶符号: %s, 当前符号: %s 转换后: %s\n", e.Head, e.Token, e.Terminal)
+	case TRACE_SHIFT:
+		fmt.Fprintf(w, "匹配终结符 %s\n", e.Terminal)
+	case TRACE_ACTION:
+		fmt.Fprintf(w, "使用产生式 %s → %s 展开\n", e.Head, formatBody(e.Body))
+	case TRACE_ACCEPT:
+		fmt.Fprintln(w, "\n\n>>> 成功完成预测分析.")
+	}
+}
+
+// JSONTracer 把每个事件输出为一行 JSON（JSON Lines），可以用 ReadTrace 读回并用 ReplayTrace 重放
+type JSONTracer struct {
+	W io.Writer
+}
+
+// Trace 以一行 JSON 输出事件
+func (t JSONTracer) Trace(e TraceEvent) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		panic(fmt.Sprintf("发生逻辑错误: 无法序列化跟踪事件 %v: %v", e, err))
+	}
+	t.W.Write(append(line, '\n'))
+}
+
+// ReadTrace 读取 JSONTracer 输出的事件
+func ReadTrace(r io.Reader) ([]TraceEvent, error) {
+	var events []TraceEvent
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // 符号栈很深时一行可能很长
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event TraceEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("跟踪记录第 %d 行格式错误：%v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// ReplayTrace 把读回的事件依次交给 tracer，交给 TextTracer 时得到与分析时完全相同的文本
+func ReplayTrace(events []TraceEvent, tracer Tracer) {
+	for _, event := range events {
+		tracer.Trace(event)
+	}
+}
+
+// trace 把事件交给 p.Tracer，没有设置时使用 TextTracer 输出到标准输出
+func (p *Parser) trace(event TraceEvent) {
+	if p.Tracer == nil {
+		TextTracer{}.Trace(event)
+		return
+	}
+	p.Tracer.Trace(event)
+}
+
+// traceSemantic 在处理函数中调用，以 semantic 事件报告一条信息，例如符号表的变化
+func (p *Parser) traceSemantic(format string, args ...any) {
+	p.trace(TraceEvent{Kind: TRACE_SEMANTIC, Message: fmt.Sprintf(format, args...)})
+}
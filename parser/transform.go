@@ -0,0 +1,394 @@
+// transform.go
+// 文法变换：消除左递归与提取左公因子
+
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// TransformNote 记录文法变换对语义动作的影响
+type TransformNote struct {
+	Production Production // 受影响的原始产生式
+	Message    string     // 描述信息
+}
+
+// String 返回变换记录的可读描述
+func (n TransformNote) String() string {
+	return fmt.Sprintf("%s → %s: %s", n.Production.Head, formatBody(n.Production.Body), n.Message)
+}
+
+// String 按照 "A → α | β" 的形式输出文法，非终结符按照第一次出现的顺序排列
+func (g *Grammar) String() string {
+	var sb strings.Builder
+	for _, head := range g.NonTerminals() {
+		var bodies []string
+		for _, prod := range g.Productions {
+			if prod.Head != head {
+				continue
+			}
+			bodies = append(bodies, formatBody(prod.Body))
+		}
+		fmt.Fprintf(&sb, "%s → %s\n", head, strings.Join(bodies, " | "))
+	}
+	return sb.String()
+}
+
+// EliminateLeftRecursion 消除文法中的直接左递归和间接左递归，返回新的文法
+/*
+	按照非终结符第一次出现的顺序 A1, A2, ..., An 处理：
+	1. 对于每个 Ai 和 j < i，如果存在 Ai → Aj γ 且 Aj 能通过最左符号推导回 Ai，就用 Aj 的所有产生式替换 Aj
+	2. 消除 Ai 的直接左递归：
+	   A → A α1 | ... | A αm | β1 | ... | βn
+	   变为
+	   A  → β1 A' | ... | βn A'
+	   A' → α1 A' | ... | αm A' | ε
+	替换只在会形成左递归时进行，这样没有参与左递归的产生式保持原样，语义动作也能保留下来。
+	被改写的产生式无法保留原有的语义动作（规约时机和符号栈的布局都变了），它们的处理函数会被置空并记录在返回的 TransformNote 中。
+	如果文法中存在 A ⇒+ A 的环，或者左递归被可空的前缀隐藏（A → B A 且 B 可空），这里不会处理，需要先用 Lint 检查。
+*/
+func (g *Grammar) EliminateLeftRecursion() (*Grammar, []TransformNote) {
+	var notes []TransformNote
+	order := g.NonTerminals()
+	names := g.symbolNames()
+	groups := make(map[consts.Symbol][]Production)
+	for _, prod := range g.Productions {
+		groups[prod.Head] = append(groups[prod.Head], prod)
+	}
+
+	// 记录每个原始产生式是否已经被改写，避免重复记录
+	noted := make(map[string]bool)
+	note := func(prod Production, message string) {
+		key := fmt.Sprintf("%s|%v", prod.Head, prod.Body)
+		if prod.Handler == nil || noted[key] {
+			return
+		}
+		noted[key] = true
+		notes = append(notes, TransformNote{Production: prod, Message: message})
+	}
+
+	tails := make(map[consts.Symbol]consts.Symbol) // 新增的 A' 需要插入在 A 之后
+	for i, ai := range order {
+		for j := 0; j < i; j++ {
+			aj := order[j]
+			// 只有 Aj 的最左推导能回到 Ai 时才替换
+			if !g.leftCorners(groups, aj)[ai] {
+				continue
+			}
+			var replaced []Production
+			for _, prod := range groups[ai] {
+				body := rhs(prod.Body)
+				if len(body) == 0 || body[0] != aj {
+					replaced = append(replaced, prod)
+					continue
+				}
+				note(prod, fmt.Sprintf("展开 %s 后语义动作 %s 无法保留", aj, handlerName(prod.Handler)))
+				for _, sub := range groups[aj] {
+					replaced = append(replaced, Production{ai, makeBody(append(rhs(sub.Body), body[1:]...)), nil})
+				}
+			}
+			groups[ai] = replaced
+		}
+
+		// 消除直接左递归
+		var recursive, others []Production
+		for _, prod := range groups[ai] {
+			body := rhs(prod.Body)
+			if len(body) > 0 && body[0] == ai {
+				recursive = append(recursive, prod)
+			} else {
+				others = append(others, prod)
+			}
+		}
+		if len(recursive) == 0 {
+			continue
+		}
+
+		tail := freshName(names, ai)
+		tails[ai] = tail
+		var rewritten []Production
+		for _, prod := range others {
+			note(prod, fmt.Sprintf("改写为 %s → %s 后语义动作 %s 无法保留", ai, formatSymbols(append(rhs(prod.Body), tail)), handlerName(prod.Handler)))
+			rewritten = append(rewritten, Production{ai, append(rhs(prod.Body), tail), nil})
+		}
+		var tailProductions []Production
+		for _, prod := range recursive {
+			note(prod, fmt.Sprintf("改写为 %s → %s 后语义动作 %s 无法保留", tail, formatSymbols(append(rhs(prod.Body)[1:], tail)), handlerName(prod.Handler)))
+			tailProductions = append(tailProductions, Production{tail, append(rhs(prod.Body)[1:], tail), nil})
+		}
+		tailProductions = append(tailProductions, Production{tail, []consts.Symbol{EPSILON}, nil})
+		groups[ai] = rewritten
+		groups[tail] = tailProductions
+	}
+
+	// 按照原有顺序输出，新的非终结符紧跟在原非终结符之后
+	var productions []Production
+	for _, head := range order {
+		productions = append(productions, groups[head]...)
+		if tail, ok := tails[head]; ok {
+			productions = append(productions, groups[tail]...)
+		}
+	}
+	return g.derive(productions), notes
+}
+
+// LEFT_FACTOR_ROUNDS 表示提取左公因子时最多展开几轮开头的非终结符
+const LEFT_FACTOR_ROUNDS = 32
+
+// LeftFactor 提取文法的左公因子，返回新的文法
+/*
+	对于 A → α β1 | α β2 | ... | γ，其中 α 是最长公共前缀，改写为
+	A  → α A' | γ
+	A' → β1 | β2 | ...
+	重复执行直到没有公共前缀为止。
+	公共前缀也可能藏在非终结符后面，例如 type → type_array | basic 中 type_array 以 basic 开头。
+	所以提取完字面上的公因子之后，如果同一个非终结符的两个候选式 First 集相交，就把这些候选式开头的非终结符展开成它的所有产生式，
+	然后再提取一次公因子，直到 First 集不再相交或者达到 LEFT_FACTOR_ROUNDS 轮。
+	左递归的非终结符不展开，否则展开永远不会结束，需要先调用 EliminateLeftRecursion。
+	展开之后完全相同的候选式只保留一个，例如 A → B c | d c、B → d 中展开 B 之后得到两个相同的候选式 d c，
+	这说明原文法是二义性的。最后去掉展开之后无法到达的非终结符，例如 type_array。
+	被改写的产生式的语义动作无法保留，会记录在返回的 TransformNote 中。
+*/
+func (g *Grammar) LeftFactor() (*Grammar, []TransformNote) {
+	var notes []TransformNote
+	noted := make(map[string]bool)
+	note := func(prod Production, message string) {
+		key := fmt.Sprintf("%s|%v", prod.Head, prod.Body)
+		if prod.Handler == nil || noted[key] {
+			return
+		}
+		noted[key] = true
+		notes = append(notes, TransformNote{Production: prod, Message: message})
+	}
+
+	names := g.symbolNames()
+	productions := factorPrefixes(g.Productions, names, note)
+	for round := 0; round < LEFT_FACTOR_ROUNDS; round++ {
+		expanded, changed := g.derive(productions).expandLeadingNonTerminals(note)
+		if !changed {
+			break
+		}
+		productions = factorPrefixes(expanded, names, note)
+	}
+	return g.derive(productions).withoutUnreachable(), notes
+}
+
+// factorPrefixes 反复提取字面上的最长公共前缀，直到同一个非终结符的候选式不再以相同的符号开头
+func factorPrefixes(productions []Production, names map[consts.Symbol]bool, note func(Production, string)) []Production {
+	productions = uniqueProductions(productions)
+	changed := true
+	for changed {
+		changed = false
+		var result []Production
+		done := make(map[int]bool) // 已经输出的产生式下标
+		for i, prod := range productions {
+			if done[i] {
+				continue
+			}
+			body := rhs(prod.Body)
+			// 找出同一头部、第一个符号相同的所有产生式
+			group := []int{i}
+			for j := i + 1; j < len(productions) && len(body) > 0; j++ {
+				other := rhs(productions[j].Body)
+				if !done[j] && productions[j].Head == prod.Head && len(other) > 0 && other[0] == body[0] {
+					group = append(group, j)
+				}
+			}
+			if len(group) == 1 {
+				done[i] = true
+				result = append(result, prod)
+				continue
+			}
+
+			// 计算最长公共前缀
+			prefix := body
+			for _, j := range group[1:] {
+				prefix = commonPrefix(prefix, rhs(productions[j].Body))
+			}
+
+			tail := freshName(names, prod.Head)
+			result = append(result, Production{prod.Head, append(append([]consts.Symbol{}, prefix...), tail), nil})
+			for _, j := range group {
+				done[j] = true
+				original := productions[j]
+				rest := rhs(original.Body)[len(prefix):]
+				note(original, fmt.Sprintf("提取公因子 %s 后语义动作 %s 无法保留", formatSymbols(prefix), handlerName(original.Handler)))
+				result = append(result, Production{tail, makeBody(append([]consts.Symbol{}, rest...)), nil})
+			}
+			changed = true
+		}
+		productions = uniqueProductions(result)
+	}
+	return productions
+}
+
+// expandLeadingNonTerminals 找出 First 集相交的候选式，把它们开头的非终结符展开为该非终结符的所有产生式
+// 返回新的产生式和是否发生了展开
+func (g *Grammar) expandLeadingNonTerminals(note func(Production, string)) ([]Production, bool) {
+	sets := NewParserWithGrammar(g)
+	sets.InitFirstSet()
+	groups := make(map[consts.Symbol][]Production)
+	for _, prod := range g.Productions {
+		groups[prod.Head] = append(groups[prod.Head], prod)
+	}
+
+	// conflicting 记录 First 集与同一头部的其他候选式相交的产生式
+	conflicting := make(map[int]bool)
+	for i, prod := range g.Productions {
+		first := sets.computeFirstSetOfSequence(prod.Body)
+		for j := i + 1; j < len(g.Productions); j++ {
+			if g.Productions[j].Head != prod.Head {
+				continue
+			}
+			for terminal := range sets.computeFirstSetOfSequence(g.Productions[j].Body) {
+				if first[terminal] {
+					conflicting[i], conflicting[j] = true, true
+					break
+				}
+			}
+		}
+	}
+
+	var result []Production
+	changed := false
+	for i, prod := range g.Productions {
+		body := rhs(prod.Body)
+		if !conflicting[i] || len(body) == 0 || g.IsTerminal(body[0]) || g.leftCorners(groups, body[0])[body[0]] {
+			result = append(result, prod)
+			continue
+		}
+		note(prod, fmt.Sprintf("展开 %s 后语义动作 %s 无法保留", body[0], handlerName(prod.Handler)))
+		for _, sub := range groups[body[0]] {
+			result = append(result, Production{prod.Head, makeBody(append(rhs(sub.Body), body[1:]...)), nil})
+		}
+		changed = true
+	}
+	return result, changed
+}
+
+// uniqueProductions 去掉头部和产生式体都相同的重复产生式，保留第一次出现的那个
+func uniqueProductions(productions []Production) []Production {
+	seen := make(map[string]bool)
+	var result []Production
+	for _, prod := range productions {
+		key := fmt.Sprintf("%s|%v", prod.Head, rhs(prod.Body))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, prod)
+	}
+	return result
+}
+
+// withoutUnreachable 去掉从开始符号出发无法到达的非终结符的产生式
+func (g *Grammar) withoutUnreachable() *Grammar {
+	reachable := g.reachableSymbols()
+	var productions []Production
+	for _, prod := range g.Productions {
+		if reachable[prod.Head] {
+			productions = append(productions, prod)
+		}
+	}
+	return g.derive(productions)
+}
+
+// derive 使用新的产生式创建文法，终结符和开始符号保持不变
+func (g *Grammar) derive(productions []Production) *Grammar {
+	grammar := NewGrammar(productions, g.Terminals)
+	grammar.Start = g.Start
+	return grammar
+}
+
+// leftCorners 返回从 sym 出发，通过产生式体的第一个符号能够到达的所有非终结符
+func (g *Grammar) leftCorners(groups map[consts.Symbol][]Production, sym consts.Symbol) map[consts.Symbol]bool {
+	corners := make(map[consts.Symbol]bool)
+	queue := []consts.Symbol{sym}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, prod := range groups[current] {
+			body := rhs(prod.Body)
+			if len(body) == 0 || g.IsTerminal(body[0]) || corners[body[0]] {
+				continue
+			}
+			corners[body[0]] = true
+			queue = append(queue, body[0])
+		}
+	}
+	return corners
+}
+
+// symbolNames 返回文法中已经使用的所有符号名和开始符号的增广符号，用于生成新的非终结符
+func (g *Grammar) symbolNames() map[consts.Symbol]bool {
+	names := g.usedNames()
+	names[g.AugmentedProduction().Head] = true
+	return names
+}
+
+// usedNames 返回终结符集合和产生式中出现的所有符号名
+func (g *Grammar) usedNames() map[consts.Symbol]bool {
+	names := map[consts.Symbol]bool{}
+	for _, t := range g.Terminals {
+		names[consts.Symbol(t)] = true
+	}
+	for _, prod := range g.Productions {
+		names[prod.Head] = true
+		for _, sym := range prod.Body {
+			names[sym] = true
+		}
+	}
+	return names
+}
+
+// freshName 为 base 生成一个未被使用的新名字，例如 stmt'，已被使用时继续追加 '
+func freshName(names map[consts.Symbol]bool, base consts.Symbol) consts.Symbol {
+	name := base + "'"
+	for names[name] {
+		name += "'"
+	}
+	names[name] = true
+	return name
+}
+
+// rhs 返回去掉 EPSILON 后的产生式体
+func rhs(body []consts.Symbol) []consts.Symbol {
+	symbols := make([]consts.Symbol, 0, len(body))
+	for _, sym := range body {
+		if sym != EPSILON {
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols
+}
+
+// makeBody 将符号串转换为产生式体，空串用 EPSILON 表示
+func makeBody(symbols []consts.Symbol) []consts.Symbol {
+	if len(symbols) == 0 {
+		return []consts.Symbol{EPSILON}
+	}
+	return symbols
+}
+
+// commonPrefix 返回两个符号串的最长公共前缀
+func commonPrefix(a, b []consts.Symbol) []consts.Symbol {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
+
+// handlerName 返回处理函数的名字，例如 genStmtIf
+func handlerName(handler func(*Parser, []Value) (Value, error)) string {
+	if handler == nil {
+		return "<nil>"
+	}
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -0,0 +1,76 @@
+// tree.go
+// 语法分析树的定义
+
+package parser
+
+import (
+	"strings"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// ParseTree 表示一棵语法分析树
+type ParseTree struct {
+	Symbol     consts.Symbol // 节点对应的文法符号
+	Production int           // 内部节点使用的产生式编号，叶子节点为 -1
+	Token      *lexer.Token  // 叶子节点对应的 Token，内部节点为 nil
+	Children   []*ParseTree  // 子节点，EPSILON 产生式的子节点为空
+}
+
+// String 以括号形式输出语法树，例如 (stmt break ;)
+func (t *ParseTree) String() string {
+	var sb strings.Builder
+	t.write(&sb)
+	return sb.String()
+}
+
+// write 将语法树写入 sb
+func (t *ParseTree) write(sb *strings.Builder) {
+	if t.Token != nil {
+		sb.WriteString(t.Token.Value)
+		return
+	}
+	if t.Production < 0 {
+		// 未展开的非终结符
+		sb.WriteString(string(t.Symbol))
+		return
+	}
+	sb.WriteString("(")
+	sb.WriteString(string(t.Symbol))
+	if len(t.Children) == 0 {
+		sb.WriteString(" ε")
+	}
+	for _, child := range t.Children {
+		sb.WriteString(" ")
+		child.write(sb)
+	}
+	sb.WriteString(")")
+}
+
+// Yield 返回语法树叶子节点组成的符号串
+func (t *ParseTree) Yield() []consts.Symbol {
+	if t.Token != nil || t.Production < 0 {
+		return []consts.Symbol{t.Symbol}
+	}
+	var symbols []consts.Symbol
+	for _, child := range t.Children {
+		symbols = append(symbols, child.Yield()...)
+	}
+	return symbols
+}
+
+// lexAll 读取全部 Token，最后一个 Token 是 EOF
+func lexAll(l *lexer.Lexer) ([]lexer.Token, error) {
+	var tokens []lexer.Token
+	for {
+		token, err := l.NextToken()
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, token)
+		if token.Type == lexer.EOF {
+			return tokens, nil
+		}
+	}
+}
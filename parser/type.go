@@ -7,9 +7,9 @@ import (
 
 // Production 结构体表示一个产生式
 type Production struct {
-	Head    consts.Symbol       // 产生式的头部
-	Body    []consts.Symbol     // 产生式的体部
-	Handler func(*Parser) error // 产生式的处理函数
+	Head    consts.Symbol                                // 产生式的头部
+	Body    []consts.Symbol                              // 产生式的体部
+	Handler func(p *Parser, args []Value) (Value, error) // 产生式的处理函数，args 是产生式体中各个符号的值（不含中间动作的标记），返回产生式头部的值
 
 	// 产生式是文法的基本组成部分，它由两部分组成：头部（Head）和体部（Body）。头部是一个非终结符，体部是一个符号序列，每个符号可以是终结符或非终结符。
 	// 例如，对于产生式 E -> E + T，E 是头部，E + T 是体部。
@@ -19,6 +19,11 @@ type Production struct {
 type Grammar struct {
 	Productions []Production      // 产生式集合
 	Terminals   []consts.Terminal // 终结符集合
+	Start       consts.Symbol     // 开始符号，默认为第一个产生式的头部
+
+	Precedence     map[consts.Terminal]Precedence // 终结符的优先级和结合性，用于解决移入-规约冲突，课程文法没有声明
+	ProductionPrec map[int]consts.Terminal        // 通过 %prec 为产生式指定的终结符，产生式使用这个终结符的优先级
+	Compositions   map[int]*Composition           // 化简后的产生式由原文法中哪些产生式组合而成，只有经过 Simplify 的文法才有
 }
 
 // FirstSet 表示First集
@@ -56,15 +61,29 @@ type Parser struct {
 	FirstSet        FirstSet               // First集
 	FollowSet       FollowSet              // Follow 集（后续发现在 LR（1）中并不需要）
 	StateCollection StateCollection        // 状态集合
+	EntryStates     map[consts.Symbol]int  // 每个入口非终结符的初始状态，开始符号的初始状态是 0
+	Transitions     Transitions            // 状态转移，在构建状态集合时顺带记录
+	Conflicts       []Conflict             // 构建分析表时发现的冲突
+	Resolved        []Conflict             // 构建分析表时通过优先级和结合性解决的冲突
 	ActionTable     ActionTable            // Action表，Action 表用来表示状态在某个输入符号下的动作，它是一个二维表，其中每个单元格包含了一个动作类型和一个状态编号。
 	GotoTable       GotoTable              // Goto表，Goto 表用来表示状态之间的转移关系，它是一个二维表，其中每个单元格包含了一个状态编号，表示在某个状态下通过某个符号转移到另一个状态。
+	LL1Table        LL1Table               // LL(1) 预测分析表，只有使用预测分析时才需要构建
+	GLRActionTable  GLRActionTable         // 保留所有冲突动作的 Action 表，只有使用 GLR 分析时才需要构建
+	LRkTable        *LRkTable              // 规范 LR(k) 分析表，只有使用 LR(k) 分析时才需要构建
+	Attributes      *AttrEvaluator         // 属性文法的计算器，调用 UseAttributes 之后才会在规约时计算属性
+	Coverage        *Coverage              // 覆盖率统计，调用 EnableCoverage 之后才会记录
+	Tracer          Tracer                 // 接收分析过程中的事件，为 nil 时以文本输出到标准输出
 	SymbolTable     intercoder.SymbolTable // 符号表
 	TokenStack      []consts.Symbol        // 符号栈
+	ValueStack      []Value                // 语义值栈，与符号栈一一对应
 	StateStack      []int                  // 状态栈
-	LastType        consts.Terminal        // 上一个终结符的类型
-	LastSize        int                    // 上一个终结符的大小
-	LastName        string                 // 上一个终结符的名字
 	ThreeAddress    []string               // 三地址码
+	SyntaxErrors    []error                // 错误恢复过程中报告的语法错误，Parse 报告的错误都是 *SyntaxError
+	ErrorNodes      []ErrorNode            // 通过错误产生式规约或者恐慌模式恢复得到的错误节点
+	Panic           *PanicMode             // 恐慌模式错误恢复的配置，调用 EnablePanicMode 之后才会使用
+	recovery        recovery               // 错误恢复的状态
+	augmented       map[consts.Symbol]bool // 构建状态集合时每个入口的增广符号
+	breakLabels     []pendingLabel         // 外层循环的 break 标签，栈顶是最内层循环
 
 	// Parser 结构体是整个文法分析器的核心，它包含了文法、First集和状态集合等重要信息。
 	// 在文法分析器中，我们需要用到文法的产生式集合、终结符集合、First集合、Follow集合等信息，这些信息都会被封装在 Parser 结构体中。
@@ -78,3 +97,4 @@ type ActionEntry struct {
 
 type ActionTable map[int]map[consts.Terminal]ActionEntry // Action表，int表示状态编号，Terminal表示终结符
 type GotoTable map[int]map[consts.Symbol]int             // Goto表，int表示状态编号，Symbol表示非终结符
+type Transitions map[int]map[consts.Symbol]int           // 状态转移，int表示状态编号，Symbol表示终结符或非终结符
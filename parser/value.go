@@ -0,0 +1,59 @@
+// value.go
+// 语义值栈：与状态栈、符号栈平行，规约时处理函数收到产生式体中各个符号的值，返回产生式头部的值
+
+package parser
+
+import (
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+	"github.com/ozline/CoursePractice-GoCompiler/lexer"
+)
+
+// Value 表示语义值栈中的一个值，不同的符号使用其中不同的字段，没有用到的字段为零值
+type Value struct {
+	Token  lexer.Token // 终结符的 Token
+	Addr   string      // 表达式的值所在的位置：变量名、常量、临时变量或者 a[i] 形式的数组元素
+	Type   string      // 类型，例如 int、float
+	Size   int         // 数组类型的元素个数，基本类型为 0
+	Labels []string    // 跳转标签，课程文法的中间动作通过标记的值把标签留给之后的动作和产生式
+	Node   *ParseTree  // 处理函数建立的语法树节点，课程文法没有用到
+}
+
+// TokenValue 返回终结符的语义值，它的地址就是 Token 的字面值
+func TokenValue(token lexer.Token) Value {
+	return Value{Token: token, Addr: token.Value}
+}
+
+// pushSymbol 把符号和它的值压入符号栈和语义值栈
+func (p *Parser) pushSymbol(sym consts.Symbol, value Value) {
+	p.TokenStack = append(p.TokenStack, sym)
+	p.ValueStack = append(p.ValueStack, value)
+}
+
+// popSymbols 从符号栈和语义值栈中弹出 n 个符号
+func (p *Parser) popSymbols(n int) {
+	p.TokenStack = p.TokenStack[:len(p.TokenStack)-n]
+	p.ValueStack = p.ValueStack[:len(p.ValueStack)-n]
+}
+
+// handlerArgs 返回交给处理函数的值：去掉中间动作的标记之后，与产生式在 PRODUCTIONS 中写出的产生式体一一对应
+func handlerArgs(body []consts.Symbol, values []Value) []Value {
+	args := make([]Value, 0, len(values))
+	for i, sym := range rhs(body) {
+		if !IsMarker(sym) {
+			args = append(args, values[i])
+		}
+	}
+	return args
+}
+
+// callHandler 调用产生式的处理函数，返回产生式头部的值
+// 没有处理函数时（例如经过变换的文法中的部分产生式）与 yacc 相同，头部的值是产生式体中第一个符号的值
+func (p *Parser) callHandler(production Production, args []Value) (Value, error) {
+	if production.Handler == nil {
+		if len(args) == 0 {
+			return Value{}, nil
+		}
+		return args[0], nil
+	}
+	return production.Handler(p, args)
+}
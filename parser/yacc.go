@@ -0,0 +1,495 @@
+// yacc.go
+// 从 Bison/Yacc 的 .y 文件导入文法
+
+package parser
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/ozline/CoursePractice-GoCompiler/consts"
+)
+
+// yaccToken 表示 .y 文件中的一个记号
+type yaccToken struct {
+	kind  string // ident、char、string、directive、mark（%%）、action、tag、number、punct
+	value string
+	line  int
+}
+
+// yaccScanner 把 .y 文件切分为记号，跳过注释和 C 代码
+type yaccScanner struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+// ImportYacc 读取 .y 文件，返回对应的文法
+/*
+	1. 声明部分：%token 声明终结符（可以带 <类型> 和 "别名"），%left、%right、%nonassoc、%precedence 声明终结符的优先级，
+	   越靠后的声明优先级越高，%start 指定开始符号；%union、%type、%{ %} 中的 C 代码等其他内容被忽略
+	2. 规则部分：A : α | β ; 中的每个候选式成为一个产生式，空候选式和 %empty 成为 A → ε，
+	   'x' 形式的字符字面量成为名为 x 的终结符，%prec 记录在 Grammar.ProductionPrec 中
+	3. 产生式末尾的 C 动作被忽略；中间的 C 动作与 yacc 一样会引入一个空的非终结符，
+	   这里通过 WithMidActions 展开为什么都不做的中间动作，保证得到的分析表与 Bison 的相同
+	4. 第二个 %% 之后的 C 代码被忽略
+*/
+func ImportYacc(r io.Reader) (*Grammar, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := (&yaccScanner{src: []rune(string(src)), line: 1}).scan()
+	if err != nil {
+		return nil, err
+	}
+
+	im := &yaccImporter{
+		tokens:     tokens,
+		terminals:  make(map[consts.Terminal]bool),
+		aliases:    make(map[string]consts.Terminal),
+		precedence: make(map[consts.Terminal]Precedence),
+		prodPrec:   make(map[int]consts.Terminal),
+		midActions: make(map[int][]MidAction),
+	}
+	if err := im.declarations(); err != nil {
+		return nil, err
+	}
+	if err := im.rules(); err != nil {
+		return nil, err
+	}
+	return im.grammar()
+}
+
+// yaccImporter 保存导入过程中的状态
+type yaccImporter struct {
+	tokens      []yaccToken
+	pos         int
+	order       []consts.Terminal // 按照声明顺序排列的终结符
+	terminals   map[consts.Terminal]bool
+	aliases     map[string]consts.Terminal // "<=" 这样的字符串别名 → 终结符
+	precedence  map[consts.Terminal]Precedence
+	level       int
+	start       consts.Symbol
+	productions []Production
+	prodPrec    map[int]consts.Terminal
+	midActions  map[int][]MidAction
+	actions     int // 已经生成的中间动作个数
+}
+
+// peek 返回当前记号，没有记号时返回 kind 为空的记号
+func (im *yaccImporter) peek() yaccToken {
+	if im.pos < len(im.tokens) {
+		return im.tokens[im.pos]
+	}
+	return yaccToken{}
+}
+
+// next 返回当前记号并前进
+func (im *yaccImporter) next() yaccToken {
+	token := im.peek()
+	im.pos++
+	return token
+}
+
+// declare 声明一个终结符
+func (im *yaccImporter) declare(terminal consts.Terminal) {
+	if !im.terminals[terminal] {
+		im.terminals[terminal] = true
+		im.order = append(im.order, terminal)
+	}
+}
+
+// symbol 把记号转换为文法符号，字符字面量和别名对应终结符
+func (im *yaccImporter) symbol(token yaccToken) consts.Symbol {
+	switch token.kind {
+	case "char":
+		im.declare(consts.Terminal(token.value))
+	case "string":
+		if terminal, ok := im.aliases[token.value]; ok {
+			return consts.Symbol(terminal)
+		}
+		im.declare(consts.Terminal(token.value))
+	}
+	return consts.Symbol(token.value)
+}
+
+// declarations 读取第一个 %% 之前的声明部分
+func (im *yaccImporter) declarations() error {
+	for {
+		token := im.next()
+		switch token.kind {
+		case "":
+			return fmt.Errorf("yacc 文件缺少 %%%% 分隔的规则部分")
+		case "mark":
+			return nil
+		case "directive":
+		default:
+			continue
+		}
+
+		switch token.value {
+		case "%token", "%left", "%right", "%nonassoc", "%precedence":
+			var prec *Precedence
+			if token.value != "%token" {
+				im.level++
+				prec = &Precedence{Level: im.level}
+				switch token.value {
+				case "%right":
+					prec.Assoc = AssocRight
+				case "%nonassoc":
+					prec.Assoc = AssocNonAssoc
+				case "%precedence":
+					prec.Assoc = AssocNone
+				}
+			}
+			var last consts.Terminal
+			aliasable := false // 字符串紧跟在 %token 声明的名字（和可选的编号）后面时才是别名
+		symbols:
+			for {
+				switch t := im.peek(); t.kind {
+				case "tag", "number":
+					im.next()
+					continue
+				case "string":
+					im.next()
+					// %token LE "<=" 为终结符声明别名，其他位置的字符串是终结符本身或者已经声明过的别名，例如 %left "<="
+					if aliasable {
+						im.aliases[t.value] = last
+						aliasable = false
+						continue
+					}
+					last = consts.Terminal(im.symbol(t))
+				case "ident", "char":
+					im.next()
+					last = consts.Terminal(im.symbol(t))
+					im.declare(last)
+					aliasable = prec == nil && t.kind == "ident"
+				default:
+					break symbols
+				}
+				if prec != nil {
+					im.precedence[last] = *prec
+				}
+			}
+		case "%start":
+			im.start = consts.Symbol(im.next().value)
+		}
+	}
+}
+
+// rules 读取规则部分，直到第二个 %% 或文件结束
+func (im *yaccImporter) rules() error {
+	for {
+		token := im.next()
+		switch token.kind {
+		case "", "mark":
+			if len(im.productions) == 0 {
+				return fmt.Errorf("yacc 文件中没有规则")
+			}
+			return nil
+		case "punct":
+			if token.value == ";" {
+				continue
+			}
+		case "ident":
+			if colon := im.next(); colon.kind == "punct" && colon.value == ":" {
+				if err := im.rule(consts.Symbol(token.value)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		return fmt.Errorf("yacc 文件第 %d 行：规则应该以 名字 : 开始，遇到了 %s", token.line, token.value)
+	}
+}
+
+// rule 读取一个非终结符的所有候选式
+func (im *yaccImporter) rule(head consts.Symbol) error {
+	var body []consts.Symbol
+	var mids []MidAction
+	var prec consts.Terminal
+	pendingAction := false // 上一个记号是 C 动作，如果后面还有符号，它就是中间动作
+
+	finish := func() {
+		index := len(im.productions)
+		if len(body) == 0 {
+			body = []consts.Symbol{EPSILON}
+		}
+		im.productions = append(im.productions, Production{head, body, nil})
+		if prec != "" {
+			im.prodPrec[index] = prec
+		}
+		if len(mids) > 0 {
+			im.midActions[index] = mids
+		}
+		body, mids, prec, pendingAction = nil, nil, "", false
+	}
+
+	for {
+		token := im.peek()
+		switch {
+		case token.kind == "" || token.kind == "mark":
+			finish()
+			return nil
+		case token.kind == "punct" && token.value == ";":
+			im.next()
+			finish()
+			return nil
+		case token.kind == "punct" && token.value == "|":
+			im.next()
+			finish()
+		case token.kind == "ident" && im.pos+1 < len(im.tokens) && im.tokens[im.pos+1].kind == "punct" && im.tokens[im.pos+1].value == ":":
+			// 省略了分号，下一条规则已经开始
+			finish()
+			return nil
+		case token.kind == "action":
+			im.next()
+			pendingAction = true
+		case token.kind == "directive" && token.value == "%prec":
+			im.next()
+			prec = consts.Terminal(im.symbol(im.next()))
+		case token.kind == "directive" && token.value == "%empty":
+			im.next()
+		case token.kind == "ident" || token.kind == "char" || token.kind == "string":
+			im.next()
+			if pendingAction {
+				im.actions++
+				mids = append(mids, MidAction{Position: len(body), Name: fmt.Sprintf("yacc%d", im.actions), Handler: yaccAction})
+				pendingAction = false
+			}
+			body = append(body, im.symbol(token))
+		default:
+			return fmt.Errorf("yacc 文件第 %d 行：规则 %s 中无法识别 %s", token.line, head, token.value)
+		}
+	}
+}
+
+// yaccAction 是导入的中间动作的处理函数，C 代码不会被执行
+func yaccAction(p *Parser, left []Value) (Value, error) { return Value{}, nil }
+
+// grammar 根据读取的声明和规则建立文法
+func (im *yaccImporter) grammar() (*Grammar, error) {
+	heads := make(map[consts.Symbol]bool)
+	for _, prod := range im.productions {
+		heads[prod.Head] = true
+	}
+	// yacc 预先定义了 error 终结符
+	for _, prod := range im.productions {
+		for _, sym := range prod.Body {
+			if sym == consts.Symbol(ERROR_TERMINAL) && !heads[sym] {
+				im.declare(ERROR_TERMINAL)
+			}
+			if sym != EPSILON && !heads[sym] && !im.terminals[consts.Terminal(sym)] {
+				return nil, fmt.Errorf("符号 %s 既没有声明为终结符，也没有产生式", sym)
+			}
+		}
+	}
+
+	terminals := append(im.order, EPSILON, TERMINATE_SYMBOL)
+	grammar := NewGrammar(im.productions, terminals)
+	if im.start != "" {
+		if !heads[im.start] {
+			return nil, fmt.Errorf("开始符号 %s 没有产生式", im.start)
+		}
+		grammar.Start = im.start
+	}
+	grammar.Precedence, grammar.ProductionPrec = im.precedence, im.prodPrec
+	if len(im.midActions) > 0 {
+		grammar = grammar.WithMidActions(im.midActions)
+	}
+	return grammar, nil
+}
+
+// scan 把整个文件切分为记号
+func (s *yaccScanner) scan() ([]yaccToken, error) {
+	var tokens []yaccToken
+	marks := 0
+	for s.pos < len(s.src) {
+		ch := s.src[s.pos]
+		switch {
+		case ch == '\n':
+			s.line++
+			s.pos++
+		case unicode.IsSpace(ch):
+			s.pos++
+		case s.hasPrefix("/*"):
+			if err := s.skipUntil("*/"); err != nil {
+				return nil, err
+			}
+		case s.hasPrefix("//"):
+			for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+				s.pos++
+			}
+		case s.hasPrefix("%{"):
+			if err := s.skipUntil("%}"); err != nil {
+				return nil, err
+			}
+		case s.hasPrefix("%%"):
+			s.pos += 2
+			tokens = append(tokens, yaccToken{kind: "mark", value: "%%", line: s.line})
+			// 第二个 %% 之后是 C 代码
+			if marks++; marks == 2 {
+				return tokens, nil
+			}
+		case ch == '%':
+			start := s.pos
+			s.pos++
+			for s.pos < len(s.src) && (isYaccIdent(s.src[s.pos]) || s.src[s.pos] == '-') {
+				s.pos++
+			}
+			value := string(s.src[start:s.pos])
+			// %union 和 %code 后面是一段 C 代码
+			if value == "%union" || value == "%code" {
+				for s.pos < len(s.src) && s.src[s.pos] != '{' {
+					s.pos++
+				}
+				if err := s.braces(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			tokens = append(tokens, yaccToken{kind: "directive", value: value, line: s.line})
+		case ch == '{':
+			line := s.line
+			if err := s.braces(); err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, yaccToken{kind: "action", line: line})
+		case ch == '\'' || ch == '"':
+			start := s.pos
+			value, err := s.literal(ch)
+			if err != nil {
+				return nil, err
+			}
+			kind := "string"
+			if ch == '\'' {
+				kind = "char"
+				// '\n' 这样不可见的字符保留原来的写法作为终结符的名字
+				if r := []rune(value); len(r) != 1 || !unicode.IsGraphic(r[0]) || unicode.IsSpace(r[0]) {
+					value = string(s.src[start:s.pos])
+				}
+			}
+			tokens = append(tokens, yaccToken{kind: kind, value: value, line: s.line})
+		case ch == '<':
+			start := s.pos
+			for s.pos < len(s.src) && s.src[s.pos] != '>' {
+				s.pos++
+			}
+			s.pos++
+			tokens = append(tokens, yaccToken{kind: "tag", value: string(s.src[start:min(s.pos, len(s.src))]), line: s.line})
+		case unicode.IsDigit(ch):
+			start := s.pos
+			for s.pos < len(s.src) && unicode.IsDigit(s.src[s.pos]) {
+				s.pos++
+			}
+			tokens = append(tokens, yaccToken{kind: "number", value: string(s.src[start:s.pos]), line: s.line})
+		case isYaccIdent(ch):
+			start := s.pos
+			for s.pos < len(s.src) && isYaccIdent(s.src[s.pos]) {
+				s.pos++
+			}
+			tokens = append(tokens, yaccToken{kind: "ident", value: string(s.src[start:s.pos]), line: s.line})
+		case ch == ':' || ch == '|' || ch == ';':
+			s.pos++
+			tokens = append(tokens, yaccToken{kind: "punct", value: string(ch), line: s.line})
+		default:
+			return nil, fmt.Errorf("yacc 文件第 %d 行：无法识别的字符 %q", s.line, ch)
+		}
+	}
+	return tokens, nil
+}
+
+// isYaccIdent 判断字符能否出现在 yacc 的名字中
+func isYaccIdent(ch rune) bool {
+	return unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '_' || ch == '.'
+}
+
+// hasPrefix 判断当前位置是否以 prefix 开始
+func (s *yaccScanner) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(string(s.src[s.pos:min(s.pos+len(prefix), len(s.src))]), prefix)
+}
+
+// skipUntil 跳过直到 end（包括 end），统计经过的行数
+func (s *yaccScanner) skipUntil(end string) error {
+	line := s.line
+	for s.pos < len(s.src) {
+		if s.hasPrefix(end) {
+			s.pos += len(end)
+			return nil
+		}
+		if s.src[s.pos] == '\n' {
+			s.line++
+		}
+		s.pos++
+	}
+	return fmt.Errorf("yacc 文件第 %d 行开始的内容没有以 %s 结束", line, end)
+}
+
+// literal 读取一个字符或字符串字面量，返回去掉引号和转义之后的内容
+func (s *yaccScanner) literal(quote rune) (string, error) {
+	line := s.line
+	var sb strings.Builder
+	for s.pos++; s.pos < len(s.src); s.pos++ {
+		ch := s.src[s.pos]
+		switch {
+		case ch == quote:
+			s.pos++
+			return sb.String(), nil
+		case ch == '\n':
+			return "", fmt.Errorf("yacc 文件第 %d 行的字面量没有结束", line)
+		case ch == '\\' && s.pos+1 < len(s.src):
+			s.pos++
+			switch s.src[s.pos] {
+			case 'n':
+				sb.WriteRune('\n')
+			case 't':
+				sb.WriteRune('\t')
+			default:
+				sb.WriteRune(s.src[s.pos])
+			}
+		default:
+			sb.WriteRune(ch)
+		}
+	}
+	return "", fmt.Errorf("yacc 文件第 %d 行的字面量没有结束", line)
+}
+
+// braces 跳过一段用花括号括起来的 C 代码，花括号可以嵌套，字符串、字符和注释中的花括号不计算在内
+func (s *yaccScanner) braces() error {
+	line := s.line
+	depth := 0
+	for s.pos < len(s.src) {
+		switch ch := s.src[s.pos]; {
+		case ch == '{':
+			depth++
+			s.pos++
+		case ch == '}':
+			depth--
+			s.pos++
+			if depth == 0 {
+				return nil
+			}
+		case ch == '"' || ch == '\'':
+			if _, err := s.literal(ch); err != nil {
+				return err
+			}
+		case s.hasPrefix("/*"):
+			if err := s.skipUntil("*/"); err != nil {
+				return err
+			}
+		case s.hasPrefix("//"):
+			for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+				s.pos++
+			}
+		default:
+			if ch == '\n' {
+				s.line++
+			}
+			s.pos++
+		}
+	}
+	return fmt.Errorf("yacc 文件第 %d 行开始的 C 代码没有以 } 结束", line)
+}
@@ -0,0 +1,23 @@
+/* 表达式文法：用优先级和结合性解决全部移入-规约冲突，< 不可结合 */
+/* 终结符使用词法分析器的名字 num 和 id，导入之后可以直接分析输入 */
+%token num id
+%nonassoc '<'
+%left '+' '-'
+%left '*' '/'
+%right UMINUS
+
+%%
+
+expr
+    : expr '<' expr
+    | expr '+' expr
+    | expr '-' expr
+    | expr '*' expr
+    | expr '/' expr
+    | '-' expr %prec UMINUS
+    | '(' expr ')'
+    | num
+    | id
+    ;
+
+%%